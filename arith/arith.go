@@ -0,0 +1,593 @@
+package arith
+
+import (
+	"strconv"
+	"strings"
+)
+
+// MAX_DEPTH is the maximum nesting level of the variables
+// whose value is evaluated as an expression
+const MAX_DEPTH = 1024
+
+// Variables hold the values of the variables named in an expression
+type Variables interface {
+	Get(name string) (string, bool)
+	Set(name, value string) error
+}
+
+// operators is sorted so that the longest operators are tried first
+var operators = []string{
+	"<<=", ">>=",
+	"**", "++", "--", "<<", ">>", "<=", ">=", "==", "!=", "&&", "||",
+	"*=", "/=", "%=", "+=", "-=", "&=", "^=", "|=",
+	"+", "-", "*", "/", "%", "<", ">", "&", "|", "^", "!", "~", "?", ":", "=", ",", "(", ")",
+}
+
+// binaryLevels are the binary operators from the
+// lowest to the highest precedence, after `||` and `&&`
+var binaryLevels = [][]string{
+	{"|"},
+	{"^"},
+	{"&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+type tokenKind int
+
+const (
+	tokEnd tokenKind = iota
+	tokNumber
+	tokName
+	tokOperator
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	offset int
+}
+
+type evaluator struct {
+	expr string
+	vars Variables
+	// pos is the offset following the current token
+	pos   int
+	tok   token
+	depth int
+	// noeval is not 0 in the operands which are parsed but not
+	// evaluated, like the right one of `0 && x++`
+	noeval int
+}
+
+// Eval evaluate the integer expression, reading
+// and assigning the variables it name in vars.
+// An empty expression is 0.
+func Eval(expr string, vars Variables) (int64, error) {
+	return eval(expr, vars, 0)
+}
+
+func eval(expr string, vars Variables, depth int) (int64, error) {
+	ev := &evaluator{expr: expr, vars: vars, depth: depth}
+
+	if err := ev.next(); err != nil {
+		return 0, err
+	}
+
+	if ev.tok.kind == tokEnd {
+		return 0, nil
+	}
+
+	value, err := ev.comma()
+	if err != nil {
+		return 0, err
+	}
+
+	if ev.tok.kind != tokEnd {
+		return 0, ev.errorf("syntax error in expression")
+	}
+
+	return value, nil
+}
+
+func (ev *evaluator) errorf(format string, a ...any) error {
+	return newError(ev.expr, ev.tok.offset, format, a...)
+}
+
+// next read the next token of the expression
+func (ev *evaluator) next() error {
+	for ev.pos < len(ev.expr) && strings.IndexByte(" \t\n", ev.expr[ev.pos]) >= 0 {
+		ev.pos++
+	}
+
+	ev.tok = token{offset: ev.pos}
+	if ev.pos == len(ev.expr) {
+		return nil
+	}
+
+	switch c := ev.expr[ev.pos]; {
+	case c >= '0' && c <= '9':
+		ev.tok.kind = tokNumber
+		ev.tok.text = ev.scan(func(c byte) bool { return isNameChar(c) || c == '#' || c == '@' })
+
+	case isNameStart(c):
+		ev.tok.kind = tokName
+		ev.tok.text = ev.scan(isNameChar)
+
+	default:
+		for _, op := range operators {
+			if strings.HasPrefix(ev.expr[ev.pos:], op) {
+				ev.tok.kind = tokOperator
+				ev.tok.text = op
+				ev.pos += len(op)
+				return nil
+			}
+		}
+		return ev.errorf("syntax error: invalid arithmetic operator")
+	}
+
+	return nil
+}
+
+// scan read the chars accepted by isPart
+func (ev *evaluator) scan(isPart func(byte) bool) string {
+	start := ev.pos
+
+	for ev.pos < len(ev.expr) && isPart(ev.expr[ev.pos]) {
+		ev.pos++
+	}
+
+	return ev.expr[start:ev.pos]
+}
+
+// is report whether the current token is one of the operators
+func (ev *evaluator) is(ops ...string) bool {
+	if ev.tok.kind != tokOperator {
+		return false
+	}
+
+	for _, op := range ops {
+		if ev.tok.text == op {
+			return true
+		}
+	}
+
+	return false
+}
+
+// split turn the `++` or `--` current token into
+// a single sign followed by another one
+func (ev *evaluator) split() {
+	ev.tok.text = ev.tok.text[:1]
+	ev.pos = ev.tok.offset + 1
+}
+
+// comma parse `expr, expr`, whose value is the last one
+func (ev *evaluator) comma() (int64, error) {
+	value, err := ev.assign()
+
+	for err == nil && ev.is(",") {
+		if err = ev.next(); err == nil {
+			value, err = ev.assign()
+		}
+	}
+
+	return value, err
+}
+
+// assign parse `name op= expr`, or a conditional expression
+func (ev *evaluator) assign() (int64, error) {
+	if ev.tok.kind != tokName {
+		return ev.conditional()
+	}
+
+	name, saved, pos := ev.tok.text, ev.tok, ev.pos
+	if err := ev.next(); err != nil {
+		return 0, err
+	}
+
+	if !ev.is("=", "*=", "/=", "%=", "+=", "-=", "<<=", ">>=", "&=", "^=", "|=") {
+		ev.tok, ev.pos = saved, pos
+		return ev.conditional()
+	}
+
+	op := ev.tok.text
+	if err := ev.next(); err != nil {
+		return 0, err
+	}
+
+	offset := ev.tok.offset
+	value, err := ev.assign()
+	if err != nil {
+		return 0, err
+	}
+
+	if op != "=" {
+		current, err := ev.variable(name, saved.offset)
+		if err != nil {
+			return 0, err
+		}
+
+		if value, err = ev.binary(strings.TrimSuffix(op, "="), current, value, offset); err != nil {
+			return 0, err
+		}
+	}
+
+	return value, ev.set(name, value)
+}
+
+// conditional parse `cond ? expr : expr`
+func (ev *evaluator) conditional() (int64, error) {
+	cond, err := ev.logical("||")
+	if err != nil || !ev.is("?") {
+		return cond, err
+	}
+
+	if err := ev.next(); err != nil {
+		return 0, err
+	}
+
+	then, err := ev.branch(cond != 0, ev.comma)
+	if err != nil {
+		return 0, err
+	}
+
+	if !ev.is(":") {
+		return 0, ev.errorf("`:' expected for conditional expression")
+	}
+	if err := ev.next(); err != nil {
+		return 0, err
+	}
+
+	otherwise, err := ev.branch(cond == 0, ev.conditional)
+
+	if cond != 0 {
+		return then, err
+	}
+
+	return otherwise, err
+}
+
+// branch parse an operand with parse, which is
+// only evaluated when taken is true
+func (ev *evaluator) branch(taken bool, parse func() (int64, error)) (int64, error) {
+	if !taken {
+		ev.noeval++
+		defer func() { ev.noeval-- }()
+	}
+
+	return parse()
+}
+
+// logical parse the `||` or `&&` operations,
+// which don't evaluate their right operand when
+// the left one decide the result.
+func (ev *evaluator) logical(op string) (int64, error) {
+	operand := func() (int64, error) {
+		if op == "||" {
+			return ev.logical("&&")
+		}
+		return ev.binaryLevel(0)
+	}
+
+	left, err := operand()
+
+	for err == nil && ev.is(op) {
+		if err = ev.next(); err != nil {
+			break
+		}
+
+		// the left operand decide the result of `1 || x` and `0 && x`
+		decided := (op == "||") == (left != 0)
+
+		var right int64
+		right, err = ev.branch(!decided, operand)
+
+		if decided {
+			left = bool64(left != 0)
+		} else {
+			left = bool64(right != 0)
+		}
+	}
+
+	return left, err
+}
+
+// binaryLevel parse the left associative binary
+// operations of binaryLevels[level]
+func (ev *evaluator) binaryLevel(level int) (int64, error) {
+	if level == len(binaryLevels) {
+		return ev.power()
+	}
+
+	left, err := ev.binaryLevel(level + 1)
+
+	// `1++2` is `1 + +2`
+	if ev.is("++", "--") {
+		ev.split()
+	}
+
+	for err == nil && ev.is(binaryLevels[level]...) {
+		op := ev.tok.text
+		if err = ev.next(); err != nil {
+			break
+		}
+
+		var right int64
+		offset := ev.tok.offset
+		if right, err = ev.binaryLevel(level + 1); err != nil {
+			break
+		}
+
+		left, err = ev.binary(op, left, right, offset)
+	}
+
+	return left, err
+}
+
+// power parse `a ** b`, which is right associative
+func (ev *evaluator) power() (int64, error) {
+	base, err := ev.unary()
+	if err != nil || !ev.is("**") {
+		return base, err
+	}
+
+	if err := ev.next(); err != nil {
+		return 0, err
+	}
+
+	offset := ev.tok.offset
+	exponent, err := ev.power()
+	if err != nil {
+		return 0, err
+	}
+
+	return ev.binary("**", base, exponent, offset)
+}
+
+// unary parse the unary operators and the prefix
+// increments, which must be followed by a name
+func (ev *evaluator) unary() (int64, error) {
+	if ev.is("++", "--") {
+		op := ev.tok
+		if err := ev.next(); err != nil {
+			return 0, err
+		}
+
+		if ev.tok.kind != tokName {
+			ev.tok, ev.pos = op, op.offset+len(op.text)
+			ev.split()
+			return ev.unary()
+		}
+
+		return ev.increment(op.text, true)
+	}
+
+	if !ev.is("+", "-", "!", "~") {
+		return ev.postfix()
+	}
+
+	op := ev.tok.text
+	if err := ev.next(); err != nil {
+		return 0, err
+	}
+
+	value, err := ev.unary()
+
+	switch op {
+	case "-":
+		value = -value
+	case "!":
+		value = bool64(value == 0)
+	case "~":
+		value = ^value
+	}
+
+	return value, err
+}
+
+// postfix parse an operand, followed by `++` or `--` when it's a name
+func (ev *evaluator) postfix() (int64, error) {
+	if ev.tok.kind != tokName {
+		return ev.primary()
+	}
+
+	name, offset := ev.tok.text, ev.tok.offset
+	if err := ev.next(); err != nil {
+		return 0, err
+	}
+
+	if ev.is("++", "--") {
+		ev.tok, ev.pos = token{kind: tokName, text: name, offset: offset}, ev.tok.offset
+		return ev.increment("", false)
+	}
+
+	return ev.variable(name, offset)
+}
+
+// increment add or remove 1 to the variable named by the current
+// token, after the `++` or `--` op when prefix is true, or before the
+// following one. It return the value before the change for a postfix.
+func (ev *evaluator) increment(op string, prefix bool) (int64, error) {
+	name, offset := ev.tok.text, ev.tok.offset
+	if err := ev.next(); err != nil {
+		return 0, err
+	}
+
+	if !prefix {
+		op = ev.tok.text
+		if err := ev.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	value, err := ev.variable(name, offset)
+	if err != nil {
+		return 0, err
+	}
+
+	updated := value + 1
+	if op == "--" {
+		updated = value - 1
+	}
+
+	if err := ev.set(name, updated); err != nil {
+		return 0, err
+	}
+
+	if prefix {
+		return updated, nil
+	}
+
+	return value, nil
+}
+
+// primary parse a number, a name or a parenthesized expression
+func (ev *evaluator) primary() (int64, error) {
+	switch {
+	case ev.tok.kind == tokNumber:
+		value, err := parseNumber(ev.tok.text)
+		if err != nil {
+			return 0, newError(ev.expr, ev.tok.offset, "%s", err.Error())
+		}
+		return value, ev.next()
+
+	case ev.tok.kind == tokName:
+		return ev.postfix()
+
+	case ev.is("("):
+		if err := ev.next(); err != nil {
+			return 0, err
+		}
+
+		value, err := ev.comma()
+		if err != nil {
+			return 0, err
+		}
+
+		if !ev.is(")") {
+			return 0, ev.errorf("missing `)'")
+		}
+		return value, ev.next()
+
+	}
+
+	return 0, ev.errorf("syntax error: operand expected")
+}
+
+// variable return the value of the variable, which is evaluated as an
+// expression when it's not a number. Unset or empty variables are 0.
+func (ev *evaluator) variable(name string, offset int) (int64, error) {
+	text, _ := ev.vars.Get(name)
+	text = strings.TrimSpace(text)
+
+	if text == "" {
+		return 0, nil
+	}
+
+	if value, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return value, nil
+	}
+
+	if ev.depth >= MAX_DEPTH {
+		return 0, newError(ev.expr, offset, "expression recursion level exceeded")
+	}
+
+	return eval(text, ev.vars, ev.depth+1)
+}
+
+// set assign the value to the variable, unless
+// the expression is not evaluated
+func (ev *evaluator) set(name string, value int64) error {
+	if ev.noeval > 0 {
+		return nil
+	}
+
+	return ev.vars.Set(name, strconv.FormatInt(value, 10))
+}
+
+// binary compute the binary operation. offset locate
+// the right operand, for the division by 0 errors.
+func (ev *evaluator) binary(op string, left, right int64, offset int) (int64, error) {
+	switch op {
+	case "+":
+		return left + right, nil
+	case "-":
+		return left - right, nil
+	case "*":
+		return left * right, nil
+
+	case "/", "%":
+		if right == 0 {
+			if ev.noeval > 0 {
+				return 0, nil
+			}
+			return 0, newError(ev.expr, offset, "division by 0")
+		}
+		if op == "/" {
+			return left / right, nil
+		}
+		return left % right, nil
+
+	case "**":
+		if right < 0 {
+			if ev.noeval > 0 {
+				return 0, nil
+			}
+			return 0, newError(ev.expr, offset, "exponent less than 0")
+		}
+		result := int64(1)
+		for ; right > 0; right >>= 1 {
+			if right&1 != 0 {
+				result *= left
+			}
+			left *= left
+		}
+		return result, nil
+
+	case "<<":
+		return left << (right & 63), nil
+	case ">>":
+		return left >> (right & 63), nil
+	case "&":
+		return left & right, nil
+	case "|":
+		return left | right, nil
+	case "^":
+		return left ^ right, nil
+
+	case "<":
+		return bool64(left < right), nil
+	case "<=":
+		return bool64(left <= right), nil
+	case ">":
+		return bool64(left > right), nil
+	case ">=":
+		return bool64(left >= right), nil
+	case "==":
+		return bool64(left == right), nil
+	case "!=":
+		return bool64(left != right), nil
+	}
+
+	return 0, newError(ev.expr, offset, "syntax error: invalid arithmetic operator")
+}
+
+func bool64(b bool) int64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,121 @@
+package arith
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testVariables map[string]string
+
+func (vars testVariables) Get(name string) (string, bool) {
+	value, ok := vars[name]
+	return value, ok
+}
+
+func (vars testVariables) Set(name, value string) error {
+	if name == "RO" {
+		return fmt.Errorf("%s: readonly variable", name)
+	}
+	vars[name] = value
+	return nil
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		expr  string
+		value int64
+	}{
+		{"", 0},
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"7 / 2, 7 % 2", 1},
+		{"-7 / 2", -3},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", 4},
+		{"1 << 4 | 1", 17},
+		{"256 >> 4 & 0xf ^ 3", 3},
+		{"~0", -1},
+		{"!5 + !0", 1},
+		{"3 > 2 && 2 >= 2 && 1 < 2 && 2 <= 1", 0},
+		{"0 || 5 == 5", 1},
+		{"3 != 3 ? 10 : 20", 20},
+		{"1 ? 2 : 3 ? 4 : 5", 2},
+		{"010 + 0x1F + 0XA", 49},
+		{"2#101 + 16#ff + 64#_ + 64#@ + 36#Z + 62#Z", 5 + 255 + 63 + 62 + 35 + 61},
+		{"1++2", 3},
+		{"1 - -1", 2},
+		{"x", 3},
+		{"y + 1", 1},
+		{"e * 2", 8},
+	}
+
+	for _, test := range tests {
+		vars := testVariables{"x": "3", "y": "", "e": "x + 1"}
+		value, err := Eval(test.expr, vars)
+
+		require.NoError(t, err, test.expr)
+		assert.Equal(t, test.value, value, test.expr)
+	}
+}
+
+func TestEvalAssignments(t *testing.T) {
+	vars := testVariables{"x": "3"}
+
+	value, err := Eval("y = x += 2, x++, ++x, x--, z -= 4, z <<= 1", vars)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(-8), value)
+	assert.Equal(t, testVariables{"x": "6", "y": "5", "z": "-8"}, vars)
+}
+
+func TestEvalShortCircuit(t *testing.T) {
+	vars := testVariables{}
+
+	value, err := Eval("0 && x++ || (1 ? y = 1 : (z = 1)) || (w = 1/0)", vars)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(1), value)
+	assert.Equal(t, testVariables{"y": "1"}, vars)
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{"1 / 0", `1 / 0: division by 0 (error token is "0")`},
+		{"5 % (2 - 2)", `5 % (2 - 2): division by 0 (error token is "(2 - 2)")`},
+		{"2 ** -1", `2 ** -1: exponent less than 0 (error token is "-1")`},
+		{"1 +", `1 +: syntax error: operand expected`},
+		{"(1", "(1: missing `)'"},
+		{"1 2", `1 2: syntax error in expression (error token is "2")`},
+		{"09", `09: value too great for base (error token is "09")`},
+		{"65#1", `65#1: invalid arithmetic base (error token is "65#1")`},
+		{"1 ? 2", "1 ? 2: `:' expected for conditional expression"},
+		{"a = $b", `a = $b: syntax error: invalid arithmetic operator (error token is "$b")`},
+		{"x", "x: expression recursion level exceeded (error token is \"x\")"},
+	}
+
+	for _, test := range tests {
+		_, err := Eval(test.expr, testVariables{"x": "x"})
+
+		assert.EqualError(t, err, test.err, test.expr)
+	}
+
+	t.Run("it should locate the error", func(t *testing.T) {
+		_, err := Eval("1 + 4 / 0", testVariables{})
+
+		var arithErr *Error
+		require.ErrorAs(t, err, &arithErr)
+		assert.Equal(t, 8, arithErr.Offset)
+	})
+
+	t.Run("it should return the assignment errors", func(t *testing.T) {
+		_, err := Eval("RO = 1", testVariables{})
+
+		assert.EqualError(t, err, "RO: readonly variable")
+	})
+}
@@ -0,0 +1,29 @@
+package arith
+
+import (
+	"fmt"
+)
+
+// Error is returned when an expression can't be evaluated.
+// Offset locate the token where the error was found in Expr.
+type Error struct {
+	Expr   string
+	Offset int
+	Msg    string
+}
+
+func newError(expr string, offset int, format string, a ...any) *Error {
+	return &Error{
+		Expr:   expr,
+		Offset: offset,
+		Msg:    fmt.Sprintf(format, a...),
+	}
+}
+
+func (err *Error) Error() string {
+	if err.Offset >= len(err.Expr) {
+		return fmt.Sprintf("%s: %s", err.Expr, err.Msg)
+	}
+
+	return fmt.Sprintf("%s: %s (error token is \"%s\")", err.Expr, err.Msg, err.Expr[err.Offset:])
+}
@@ -0,0 +1,74 @@
+package arith
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// parseNumber parse an integer constant: a decimal number, an octal
+// one starting with 0, an hexadecimal one starting with 0x, or a
+// number in any base from 2 to 64 written `base#digits`.
+func parseNumber(text string) (int64, error) {
+	base := int64(10)
+	digits := text
+
+	switch {
+	case strings.Contains(text, "#"):
+		prefix, rest, _ := strings.Cut(text, "#")
+
+		n, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil || n < 2 || n > 64 {
+			return 0, errors.New("invalid arithmetic base")
+		}
+		base, digits = n, rest
+
+	case strings.HasPrefix(text, "0x"), strings.HasPrefix(text, "0X"):
+		base, digits = 16, text[2:]
+
+	case len(text) > 1 && text[0] == '0':
+		base, digits = 8, text[1:]
+	}
+
+	if digits == "" {
+		return 0, errors.New("invalid number")
+	}
+
+	var value int64
+
+	for i := 0; i < len(digits); i++ {
+		digit := digitValue(digits[i], base)
+		if digit < 0 {
+			return 0, errors.New("invalid number")
+		}
+		if digit >= base {
+			return 0, errors.New("value too great for base")
+		}
+
+		value = value*base + digit
+	}
+
+	return value, nil
+}
+
+// digitValue return the value of a digit in the base, or -1 if the
+// char is not a digit. The letters are the digits from 10, lowercase
+// first when the base is above 36, followed by `@` and `_`.
+func digitValue(c byte, base int64) int64 {
+	switch {
+	case c >= '0' && c <= '9':
+		return int64(c - '0')
+	case c >= 'a' && c <= 'z':
+		return int64(c-'a') + 10
+	case c >= 'A' && c <= 'Z' && base <= 36:
+		return int64(c-'A') + 10
+	case c >= 'A' && c <= 'Z':
+		return int64(c-'A') + 36
+	case c == '@':
+		return 62
+	case c == '_':
+		return 63
+	}
+
+	return -1
+}
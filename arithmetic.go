@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Aboubakary833/cish/arith"
+	"github.com/Aboubakary833/cish/parser"
+)
+
+// arithmetic expand the parameters, the command substitutions and
+// the arithmetic expansions of the expression, then evaluate it.
+// It's expanded like a double-quoted word, so a tilde is not
+// expanded but left to the bitwise negation.
+func (sh *Shell) arithmetic(expr string) (int64, error) {
+	segments, err := sh.expandSegments(expr, true, false)
+	if err != nil {
+		return 0, err
+	}
+	expanded := joinSegments(segments)
+
+	return arith.Eval(expanded, sh.vars)
+}
+
+// expandArithmetic expand the `$(( expression ))`
+// starting at text[0] and ending at text[end-1]
+func (sh *Shell) expandArithmetic(text string, end int, quoted bool) ([]segment, int, error) {
+	value, err := sh.arithmetic(text[3 : end-2])
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return valueSegments(strconv.FormatInt(value, 10), quoted), end, nil
+}
+
+// runArith run a `(( expression ))` command,
+// which succeed when the expression is not 0
+func (sh *Shell) runArith(cmd *parser.ArithCommand, io streams) {
+	value, err := sh.arithmetic(cmd.Expr.Text)
+	if err != nil {
+		sh.fail(io, err, STATUS_FAILURE)
+		return
+	}
+
+	sh.status.Set(statusOfValue(value))
+}
+
+// let evaluate each argument as an arithmetic expression.
+// It succeed when the last one is not 0.
+func (sh *Shell) let(args []string, io streams) int {
+	if len(args) == 1 {
+		printError(io, fmt.Errorf("let: expression expected"))
+		return STATUS_FAILURE
+	}
+
+	var value int64
+
+	for _, arg := range args[1:] {
+		var err error
+
+		if value, err = arith.Eval(arg, sh.vars); err != nil {
+			printError(io, fmt.Errorf("let: %s", err.Error()))
+			return STATUS_FAILURE
+		}
+	}
+
+	return statusOfValue(value)
+}
+
+// statusOfValue return the status of an arithmetic command
+func statusOfValue(value int64) int {
+	if value == 0 {
+		return STATUS_FAILURE
+	}
+
+	return STATUS_SUCCESS
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Aboubakary833/cish/scanner"
+)
+
+// builtinFunc run a builtin with its arguments,
+// args[0] being its name, and return its status
+type builtinFunc func(sh *Shell, args []string, io streams) int
+
+// builtins are the commands run by the shell itself
+var builtins map[string]builtinFunc
+
+// builtins is filled by init, as some builtins look it up
+func init() {
+	builtins = map[string]builtinFunc{
+		":":        (*Shell).trueBuiltin,
+		"true":     (*Shell).trueBuiltin,
+		"false":    (*Shell).falseBuiltin,
+		"set":      (*Shell).set,
+		"export":   (*Shell).export,
+		"readonly": (*Shell).readonly,
+		"unset":    (*Shell).unset,
+		"break":    (*Shell).loopControl,
+		"continue": (*Shell).loopControl,
+		"local":    (*Shell).local,
+		"return":   (*Shell).returnBuiltin,
+		"declare":  (*Shell).declare,
+		"let":      (*Shell).let,
+		"cd":       (*Shell).cd,
+		"pwd":      (*Shell).pwdBuiltin,
+		"pushd":    (*Shell).pushd,
+		"popd":     (*Shell).popd,
+		"dirs":     (*Shell).dirs,
+		"exit":     (*Shell).exit,
+		"quit":     (*Shell).exit,
+		"type":     (*Shell).typeBuiltin,
+		"command":  (*Shell).command,
+		"builtin":  (*Shell).builtin,
+		"hash":     (*Shell).hashBuiltin,
+		"echo":     (*Shell).echo,
+		"printf":   (*Shell).printf,
+		"read":     (*Shell).read,
+		"test":     (*Shell).test,
+		"[":        (*Shell).test,
+		"jobs":     (*Shell).jobsBuiltin,
+		"fg":       (*Shell).fg,
+		"bg":       (*Shell).bg,
+		"wait":     (*Shell).waitBuiltin,
+		"disown":   (*Shell).disown,
+		"trap":     (*Shell).trap,
+	}
+}
+
+// runBuiltin run the builtin named args[0].
+// It report false if there's no such builtin.
+func (sh *Shell) runBuiltin(args []string, io streams) (int, bool) {
+	run, ok := builtins[args[0]]
+	if !ok {
+		return STATUS_FAILURE, false
+	}
+
+	return run(sh, args, io), true
+}
+
+// exit leave the shell with the status n, or with the status
+// of the last command. In a subshell, only the subshell is left.
+func (sh *Shell) exit(args []string, io streams) int {
+	status := sh.status.Code()
+
+	if len(args) > 2 {
+		printError(io, fmt.Errorf("%s: too many arguments", args[0]))
+		return STATUS_FAILURE
+	}
+
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			printError(io, fmt.Errorf("%s: %s: numeric argument required", args[0], args[1]))
+			n = STATUS_MISUSE
+		}
+		status = n & 0xff
+	}
+
+	sh.exiting = true
+
+	return status
+}
+
+// trueBuiltin do nothing and succeed, as `true` and `:`, the null
+// command whose arguments are still expanded, like in `: ${x:=1}`
+func (sh *Shell) trueBuiltin(args []string, io streams) int {
+	return STATUS_SUCCESS
+}
+
+// falseBuiltin do nothing and fail
+func (sh *Shell) falseBuiltin(args []string, io streams) int {
+	return STATUS_FAILURE
+}
+
+// set change the shell options and the positional parameters.
+// Without arguments, it print the shell variables.
+// `set -o` and `set +o` without option name print the options.
+func (sh *Shell) set(args []string, io streams) int {
+	if len(args) == 1 {
+		for _, name := range sh.vars.Names() {
+			if variable := sh.vars.Variable(name); variable.Set {
+				fmt.Fprintf(io.stdout, "%s=%s\n", name, formatValue(variable))
+			}
+		}
+		return STATUS_SUCCESS
+	}
+
+	i := 1
+	for ; i < len(args); i++ {
+		arg := args[i]
+
+		if arg == "--" {
+			i++
+			sh.params = slices.Clone(args[i:])
+			return STATUS_SUCCESS
+		}
+
+		if len(arg) < 2 || (arg[0] != '-' && arg[0] != '+') {
+			break
+		}
+
+		enable := arg[0] == '-'
+
+		for _, letter := range []byte(arg[1:]) {
+			if letter != 'o' {
+				name, ok := optionByLetter[letter]
+				if !ok {
+					fmt.Fprintf(io.stderr, "cish: set: %c%c: invalid option\n", arg[0], letter)
+					return STATUS_MISUSE
+				}
+				sh.options[name] = enable
+				continue
+			}
+
+			if i+1 == len(args) {
+				sh.printOptions(enable, io)
+				continue
+			}
+
+			i++
+			if !slices.Contains(optionNames, args[i]) {
+				fmt.Fprintf(io.stderr, "cish: set: %s: invalid option name\n", args[i])
+				return STATUS_FAILURE
+			}
+			sh.options[args[i]] = enable
+		}
+	}
+
+	if i < len(args) {
+		sh.params = slices.Clone(args[i:])
+	}
+
+	return STATUS_SUCCESS
+}
+
+// printOptions print the options state, in a human readable form
+// or as the `set` commands restoring them.
+func (sh *Shell) printOptions(readable bool, io streams) {
+	for _, name := range optionNames {
+		switch {
+		case readable && sh.options[name]:
+			fmt.Fprintf(io.stdout, "%-15s\ton\n", name)
+		case readable:
+			fmt.Fprintf(io.stdout, "%-15s\toff\n", name)
+		case sh.options[name]:
+			fmt.Fprintf(io.stdout, "set -o %s\n", name)
+		default:
+			fmt.Fprintf(io.stdout, "set +o %s\n", name)
+		}
+	}
+}
+
+// export mark the variables to be passed to the programs environment.
+// `export -n` remove the mark and `export -p` print the exported variables.
+func (sh *Shell) export(args []string, io streams) int {
+	flags, names, err := parseFlags(args, "np")
+	if err != nil {
+		fmt.Fprintf(io.stderr, "cish: export: %s\n", err.Error())
+		return STATUS_MISUSE
+	}
+
+	if len(names) == 0 {
+		sh.printVariables("export", func(v *Variable) bool { return v.Exported }, io)
+		return STATUS_SUCCESS
+	}
+
+	return sh.declareVariables("export", names, io, func(variable *Variable) {
+		variable.Exported = !flags['n']
+	})
+}
+
+// readonly prevent the variables to be changed or unset.
+// `readonly -p` print the readonly variables.
+func (sh *Shell) readonly(args []string, io streams) int {
+	_, names, err := parseFlags(args, "p")
+	if err != nil {
+		fmt.Fprintf(io.stderr, "cish: readonly: %s\n", err.Error())
+		return STATUS_MISUSE
+	}
+
+	if len(names) == 0 {
+		sh.printVariables("readonly", func(v *Variable) bool { return v.ReadOnly }, io)
+		return STATUS_SUCCESS
+	}
+
+	return sh.declareVariables("readonly", names, io, func(variable *Variable) {
+		variable.ReadOnly = true
+	})
+}
+
+// declareVariables assign the `NAME=value` arguments and call
+// mark on the variables named by all the arguments.
+func (sh *Shell) declareVariables(builtin string, args []string, io streams, mark func(*Variable)) int {
+	status := STATUS_SUCCESS
+
+	for _, arg := range args {
+		name, value, hasValue := strings.Cut(arg, "=")
+
+		if !scanner.IsName(name) {
+			fmt.Fprintf(io.stderr, "cish: %s: `%s': not a valid identifier\n", builtin, arg)
+			status = STATUS_FAILURE
+			continue
+		}
+
+		if hasValue {
+			if err := sh.vars.Set(name, value); err != nil {
+				fmt.Fprintf(io.stderr, "cish: %s: %s\n", builtin, err.Error())
+				status = STATUS_FAILURE
+				continue
+			}
+		}
+
+		mark(sh.vars.Declare(name))
+	}
+
+	return status
+}
+
+// printVariables print the variables matching filter
+// as the builtin commands declaring them.
+func (sh *Shell) printVariables(builtin string, filter func(*Variable) bool, io streams) {
+	for _, name := range sh.vars.Names() {
+		variable := sh.vars.Variable(name)
+
+		if !filter(variable) {
+			continue
+		}
+
+		if variable.Set {
+			fmt.Fprintf(io.stdout, "%s %s=%s\n", builtin, name, formatValue(variable))
+		} else {
+			fmt.Fprintf(io.stdout, "%s %s\n", builtin, name)
+		}
+	}
+}
+
+// unset remove the variables, or the functions with `-f`.
+// Without flag, a name which is not a variable remove the function.
+func (sh *Shell) unset(args []string, io streams) int {
+	flags, names, err := parseFlags(args, "fv")
+	if err != nil {
+		fmt.Fprintf(io.stderr, "cish: unset: %s\n", err.Error())
+		return STATUS_MISUSE
+	}
+
+	status := STATUS_SUCCESS
+
+	for _, name := range names {
+		if flags['f'] || (!flags['v'] && sh.vars.Variable(name) == nil) {
+			delete(sh.funcs, name)
+			continue
+		}
+
+		if err := sh.vars.Unset(name); err != nil {
+			fmt.Fprintf(io.stderr, "cish: unset: %s\n", err.Error())
+			status = STATUS_FAILURE
+		}
+	}
+
+	return status
+}
+
+// loopControl leave the n (1 by default) enclosing loops with
+// `break`, or resume their next iteration with `continue`.
+func (sh *Shell) loopControl(args []string, io streams) int {
+	n := 1
+
+	if len(args) > 1 {
+		count, err := strconv.Atoi(args[1])
+		if err != nil || count < 1 {
+			printError(io, fmt.Errorf("%s: %s: loop count out of range", args[0], args[1]))
+			return STATUS_FAILURE
+		}
+		n = count
+	}
+
+	if sh.loops == 0 {
+		printError(io, fmt.Errorf("%s: only meaningful in a `for', `while', or `until' loop", args[0]))
+		return STATUS_SUCCESS
+	}
+
+	n = min(n, sh.loops)
+	if args[0] == "break" {
+		sh.breaks = n
+	} else {
+		sh.continues = n
+	}
+
+	return STATUS_SUCCESS
+}
+
+// parseFlags split the arguments of a builtin into its flags, which
+// must be part of allowed, and its operands. The flags end at the
+// first operand or at `--`.
+func parseFlags(args []string, allowed string) (map[byte]bool, []string, error) {
+	flags := make(map[byte]bool)
+	i := 1
+
+	for ; i < len(args); i++ {
+		arg := args[i]
+
+		if arg == "--" {
+			i++
+			break
+		}
+
+		if len(arg) < 2 || arg[0] != '-' {
+			break
+		}
+
+		for _, flag := range []byte(arg[1:]) {
+			if strings.IndexByte(allowed, flag) < 0 {
+				return nil, nil, fmt.Errorf("-%c: invalid option", flag)
+			}
+			flags[flag] = true
+		}
+	}
+
+	return flags, args[i:], nil
+}
+
+// shellQuote quote the text so the shell read it back as is
+func shellQuote(text string) string {
+	if text == "" {
+		return "''"
+	}
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+
+		if !isNameChar(c) && strings.IndexByte("@%+=:,./-", c) < 0 {
+			return "'" + strings.ReplaceAll(text, "'", `'\''`) + "'"
+		}
+	}
+
+	return text
+}
+
+// formatValue return the value of the variable as it's assigned
+// in the shell, like `(a 'b c')` for an array
+func formatValue(variable *Variable) string {
+	if variable.Array == nil {
+		return shellQuote(variable.Value)
+	}
+
+	elements := make([]string, len(variable.Array))
+	for i, element := range variable.Array {
+		elements[i] = shellQuote(element)
+	}
+
+	return "(" + strings.Join(elements, " ") + ")"
+}
+
+// printError print an error on the command standard error
+func printError(io streams, err error) {
+	fmt.Fprintf(io.stderr, "cish: %s\n", err.Error())
+}
+
+// fatalError is an error which make a non-interactive shell exit with
+// status, like an unset parameter expanded with `${name?}` or the
+// assignment of a readonly variable
+type fatalError struct {
+	err    error
+	status int
+}
+
+func (e *fatalError) Error() string {
+	return e.err.Error()
+}
+
+func (e *fatalError) Unwrap() error {
+	return e.err
+}
+
+// fail print the error making a command fail, and set its status.
+// A fatal error make a non-interactive shell exit instead.
+func (sh *Shell) fail(io streams, err error, status int) {
+	printError(io, err)
+
+	var fatal *fatalError
+	if errors.As(err, &fatal) && !sh.interactive {
+		status = fatal.status
+		sh.exiting = true
+	}
+
+	sh.status.Set(status)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"github.com/Aboubakary833/cish/parser"
+	"github.com/Aboubakary833/cish/pattern"
+)
+
+// runIf run the list of the first branch whose condition
+// succeed. The status is 0 when no branch is run.
+func (sh *Shell) runIf(clause *parser.IfClause, io streams) {
+	sh.runCondition(clause.Cond, io)
+	if sh.jumping() {
+		return
+	}
+
+	if sh.status.Code() == STATUS_SUCCESS {
+		sh.runList(clause.Then, io)
+		return
+	}
+
+	for _, elif := range clause.Elifs {
+		sh.runCondition(elif.Cond, io)
+		if sh.jumping() {
+			return
+		}
+
+		if sh.status.Code() == STATUS_SUCCESS {
+			sh.runList(elif.Then, io)
+			return
+		}
+	}
+
+	if clause.Else != nil {
+		sh.runList(clause.Else, io)
+		return
+	}
+
+	sh.status.Set(STATUS_SUCCESS)
+}
+
+// runCondition run the condition of an `if`, `while` or `until`,
+// whose failure don't run the ERR trap
+func (sh *Shell) runCondition(cond *parser.List, io streams) {
+	sh.conditions++
+	defer func() { sh.conditions-- }()
+
+	sh.runList(cond, io)
+}
+
+// runWhile run the body as long as the condition succeed,
+// or fail for an until loop. The status is the one
+// of the last body run, or 0 if it's never run.
+func (sh *Shell) runWhile(clause *parser.WhileClause, io streams) {
+	sh.loops++
+	defer func() { sh.loops-- }()
+
+	status := STATUS_SUCCESS
+
+	for {
+		sh.runCondition(clause.Cond, io)
+		if sh.stopLoop() {
+			break
+		}
+
+		if (sh.status.Code() == STATUS_SUCCESS) == clause.Until {
+			break
+		}
+
+		sh.runList(clause.Body, io)
+		status = sh.status.Code()
+
+		if sh.stopLoop() {
+			break
+		}
+	}
+
+	sh.status.Set(status)
+}
+
+// runFor run the body once for each field of the words,
+// or each positional parameter without `in`.
+func (sh *Shell) runFor(clause *parser.ForClause, io streams) {
+	values := sh.params
+
+	if clause.In {
+		values = nil
+
+		for _, word := range clause.Words {
+			fields, err := sh.expandFields(word.Text)
+			if err != nil {
+				sh.fail(io, err, STATUS_FAILURE)
+				return
+			}
+			values = append(values, fields...)
+		}
+	}
+
+	sh.loops++
+	defer func() { sh.loops-- }()
+
+	status := STATUS_SUCCESS
+
+	for _, value := range values {
+		if err := sh.vars.Set(clause.Name, value); err != nil {
+			printError(io, err)
+			status = STATUS_FAILURE
+			break
+		}
+
+		sh.runList(clause.Body, io)
+		status = sh.status.Code()
+
+		if sh.stopLoop() {
+			break
+		}
+	}
+
+	sh.status.Set(status)
+}
+
+// runCase run the body of the first item with a pattern
+// matching the word. The status is 0 when no item match.
+func (sh *Shell) runCase(clause *parser.CaseClause, io streams) {
+	word, err := sh.expand(clause.Word.Text)
+	if err != nil {
+		sh.fail(io, err, STATUS_FAILURE)
+		return
+	}
+
+	for _, item := range clause.Items {
+		for _, patternWord := range item.Patterns {
+			pat, err := sh.expandPattern(patternWord.Text)
+			if err != nil {
+				sh.fail(io, err, STATUS_FAILURE)
+				return
+			}
+
+			if pattern.Match(pat, word) {
+				sh.status.Set(STATUS_SUCCESS)
+				sh.runList(item.Body, io)
+				return
+			}
+		}
+	}
+
+	sh.status.Set(STATUS_SUCCESS)
+}
+
+// jumping report whether a `break`, a `continue`, a `return`, an
+// `exit` or an interrupt is leaving the commands, so the next ones
+// are not run.
+func (sh *Shell) jumping() bool {
+	return sh.breaks != 0 || sh.continues != 0 || sh.returning || sh.exiting || sh.interrupted.Load()
+}
+
+// stopLoop is called by a loop after each list it run.
+// It consume the `break` or `continue` that target the
+// loop, and report whether the loop must stop, which is
+// also the case while returning from a function, exiting or
+// being interrupted.
+func (sh *Shell) stopLoop() bool {
+	if sh.returning || sh.exiting || sh.interrupted.Load() {
+		return true
+	}
+
+	if sh.breaks != 0 {
+		sh.breaks--
+		return true
+	}
+
+	if sh.continues != 0 {
+		sh.continues--
+		return sh.continues != 0
+	}
+
+	return false
+}
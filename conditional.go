@@ -0,0 +1,490 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/Aboubakary833/cish/parser"
+	"github.com/Aboubakary833/cish/pattern"
+	"golang.org/x/term"
+)
+
+// Access modes checked by the `-r`, `-w` and `-x` tests
+const (
+	ACCESS_READ    = 4
+	ACCESS_WRITE   = 2
+	ACCESS_EXECUTE = 1
+)
+
+// test evaluate the expression of its arguments, which end with `]`
+// when it's run as `[`. The status is 0 when the expression is true,
+// 1 when it's false and 2 when it's not valid.
+func (sh *Shell) test(args []string, io streams) int {
+	operands := args[1:]
+
+	if args[0] == "[" {
+		if len(operands) == 0 || operands[len(operands)-1] != "]" {
+			printError(io, fmt.Errorf("[: missing `]'"))
+			return STATUS_MISUSE
+		}
+		operands = operands[:len(operands)-1]
+	}
+
+	result, err := sh.testArgs(operands)
+	if err != nil {
+		printError(io, fmt.Errorf("%s: %s", args[0], err.Error()))
+		return STATUS_MISUSE
+	}
+
+	return statusOfTest(result)
+}
+
+// testArgs evaluate the arguments of `test`. Up to 4 arguments,
+// they are read following the POSIX rules based on their number,
+// so `test -n` or `test ! =` are not ambiguous.
+func (sh *Shell) testArgs(args []string) (bool, error) {
+	switch len(args) {
+	case 0:
+		return false, nil
+
+	case 1:
+		return args[0] != "", nil
+
+	case 2:
+		if args[0] == "!" {
+			return args[1] == "", nil
+		}
+		if parser.IsUnaryTestOp(args[0]) {
+			return sh.unaryTest(args[0], args[1])
+		}
+		return false, fmt.Errorf("%s: unary operator expected", args[0])
+
+	case 3:
+		if parser.IsBinaryTestOp(args[1]) && args[1] != "=~" {
+			return sh.binaryTest(args[1], args[0], args[2])
+		}
+		if args[1] == "-a" || args[1] == "-o" {
+			break
+		}
+		if args[0] == "!" {
+			result, err := sh.testArgs(args[1:])
+			return !result, err
+		}
+		if args[0] == "(" && args[2] == ")" {
+			return args[1] != "", nil
+		}
+		return false, fmt.Errorf("%s: binary operator expected", args[1])
+
+	case 4:
+		if args[0] == "!" {
+			result, err := sh.testArgs(args[1:])
+			return !result, err
+		}
+		if args[0] == "(" && args[3] == ")" {
+			return sh.testArgs(args[1:3])
+		}
+	}
+
+	t := &testParser{sh: sh, args: args}
+
+	result, err := t.or()
+	if err == nil && t.pos < len(args) {
+		err = fmt.Errorf("%s: too many arguments", args[t.pos])
+	}
+
+	return result, err
+}
+
+// testParser evaluate the arguments of `test` when they are more
+// than 4, combining the tests with `!`, `-a`, `-o` and parentheses.
+type testParser struct {
+	sh   *Shell
+	args []string
+	pos  int
+}
+
+func (t *testParser) peek(n int) (string, bool) {
+	if t.pos+n >= len(t.args) {
+		return "", false
+	}
+
+	return t.args[t.pos+n], true
+}
+
+func (t *testParser) or() (bool, error) {
+	result, err := t.and()
+
+	for arg, _ := t.peek(0); err == nil && arg == "-o"; arg, _ = t.peek(0) {
+		t.pos++
+
+		var right bool
+		right, err = t.and()
+		result = result || right
+	}
+
+	return result, err
+}
+
+func (t *testParser) and() (bool, error) {
+	result, err := t.not()
+
+	for arg, _ := t.peek(0); err == nil && arg == "-a"; arg, _ = t.peek(0) {
+		t.pos++
+
+		var right bool
+		right, err = t.not()
+		result = result && right
+	}
+
+	return result, err
+}
+
+func (t *testParser) not() (bool, error) {
+	if arg, _ := t.peek(0); arg == "!" {
+		t.pos++
+		result, err := t.not()
+		return !result, err
+	}
+
+	return t.primary()
+}
+
+func (t *testParser) primary() (bool, error) {
+	arg, ok := t.peek(0)
+	if !ok {
+		return false, fmt.Errorf("argument expected")
+	}
+
+	if arg == "(" {
+		t.pos++
+
+		result, err := t.or()
+		if err != nil {
+			return false, err
+		}
+
+		if closing, _ := t.peek(0); closing != ")" {
+			return false, fmt.Errorf("`)' expected")
+		}
+		t.pos++
+
+		return result, nil
+	}
+
+	if op, ok := t.peek(1); ok && parser.IsBinaryTestOp(op) && op != "=~" {
+		right, ok := t.peek(2)
+		if !ok {
+			return false, fmt.Errorf("%s: argument expected", op)
+		}
+		t.pos += 3
+
+		return t.sh.binaryTest(op, arg, right)
+	}
+
+	if operand, ok := t.peek(1); ok && parser.IsUnaryTestOp(arg) {
+		t.pos += 2
+		return t.sh.unaryTest(arg, operand)
+	}
+
+	t.pos++
+
+	return arg != "", nil
+}
+
+// runCond run a `[[ expression ]]` command. The status is
+// 0 when the expression is true, 1 when it's false and 2
+// when it can't be evaluated.
+func (sh *Shell) runCond(cmd *parser.CondCommand, io streams) {
+	result, err := sh.evalCond(cmd.Expr)
+	if err != nil {
+		sh.fail(io, err, STATUS_MISUSE)
+		return
+	}
+
+	sh.status.Set(statusOfTest(result))
+}
+
+// evalCond evaluate a conditional expression. The operands are
+// expanded without being split into fields. The right operand of
+// `==` and `!=` is a pattern, and the one of `=~` a regular expression,
+// whose quoted parts match themselves. The integer comparisons
+// evaluate their operands as arithmetic expressions.
+func (sh *Shell) evalCond(expr parser.CondExpr) (bool, error) {
+	switch expr := expr.(type) {
+	case *parser.CondParen:
+		return sh.evalCond(expr.Expr)
+
+	case *parser.CondWord:
+		text, err := sh.expand(expr.Word.Text)
+		return text != "", err
+
+	case *parser.CondUnary:
+		if expr.Op == "!" {
+			result, err := sh.evalCond(expr.Operand)
+			return !result, err
+		}
+
+		operand, err := sh.expand(expr.Operand.(*parser.CondWord).Word.Text)
+		if err != nil {
+			return false, err
+		}
+		return sh.unaryTest(expr.Op, operand)
+	}
+
+	binary := expr.(*parser.CondBinary)
+
+	switch binary.Op {
+	case "&&", "||":
+		left, err := sh.evalCond(binary.Left)
+		if err != nil || left == (binary.Op == "||") {
+			return left, err
+		}
+		return sh.evalCond(binary.Right)
+	}
+
+	leftWord := binary.Left.(*parser.CondWord).Word.Text
+	rightWord := binary.Right.(*parser.CondWord).Word.Text
+
+	switch binary.Op {
+	case "-eq", "-ne", "-lt", "-le", "-gt", "-ge":
+		left, err := sh.arithmetic(leftWord)
+		if err != nil {
+			return false, err
+		}
+
+		right, err := sh.arithmetic(rightWord)
+		if err != nil {
+			return false, err
+		}
+
+		return compareIntegers(binary.Op, left, right), nil
+	}
+
+	left, err := sh.expand(leftWord)
+	if err != nil {
+		return false, err
+	}
+
+	switch binary.Op {
+	case "=", "==", "!=":
+		pat, err := sh.expandPattern(rightWord)
+		if err != nil {
+			return false, err
+		}
+		return pattern.Match(pat, left) == (binary.Op != "!="), nil
+
+	case "=~":
+		return sh.matchRegex(left, rightWord)
+	}
+
+	right, err := sh.expand(rightWord)
+	if err != nil {
+		return false, err
+	}
+
+	return sh.binaryTest(binary.Op, left, right)
+}
+
+// matchRegex match the text against the regular expression of the
+// word. BASH_REMATCH is set to the matched text and sub-expressions.
+func (sh *Shell) matchRegex(text, word string) (bool, error) {
+	segments, err := sh.expandSegments(word, false, false)
+	if err != nil {
+		return false, err
+	}
+
+	var builder strings.Builder
+	for _, segment := range segments {
+		if segment.quoted {
+			builder.WriteString(regexp.QuoteMeta(segment.text))
+		} else {
+			builder.WriteString(segment.text)
+		}
+	}
+
+	re, err := regexp.Compile(builder.String())
+	if err != nil {
+		return false, fmt.Errorf("%s: invalid regular expression", builder.String())
+	}
+
+	match := re.FindStringSubmatch(text)
+	if err := sh.vars.SetArray("BASH_REMATCH", match); err != nil {
+		return false, err
+	}
+
+	return match != nil, nil
+}
+
+// unaryTest evaluate a unary test like `-f file` or `-z string`
+func (sh *Shell) unaryTest(op, operand string) (bool, error) {
+	switch op {
+	case "-n":
+		return operand != "", nil
+
+	case "-z":
+		return operand == "", nil
+
+	case "-o":
+		return sh.options[operand], nil
+
+	case "-v":
+		_, set := sh.vars.Get(operand)
+		return set, nil
+
+	case "-t":
+		fd, err := strconv.Atoi(operand)
+		if err != nil {
+			return false, fmt.Errorf("%s: integer expression expected", operand)
+		}
+		return term.IsTerminal(fd), nil
+
+	case "-h", "-L":
+		info, err := os.Lstat(sh.path(operand))
+		return err == nil && info.Mode()&os.ModeSymlink != 0, nil
+
+	case "-r":
+		return syscall.Access(sh.path(operand), ACCESS_READ) == nil, nil
+
+	case "-w":
+		return syscall.Access(sh.path(operand), ACCESS_WRITE) == nil, nil
+
+	case "-x":
+		return syscall.Access(sh.path(operand), ACCESS_EXECUTE) == nil, nil
+	}
+
+	info, err := os.Stat(sh.path(operand))
+	if err != nil {
+		return false, nil
+	}
+	mode := info.Mode()
+
+	switch op {
+	case "-a", "-e":
+		return true, nil
+	case "-f":
+		return mode.IsRegular(), nil
+	case "-d":
+		return mode.IsDir(), nil
+	case "-b":
+		return mode&os.ModeDevice != 0 && mode&os.ModeCharDevice == 0, nil
+	case "-c":
+		return mode&os.ModeCharDevice != 0, nil
+	case "-p":
+		return mode&os.ModeNamedPipe != 0, nil
+	case "-S":
+		return mode&os.ModeSocket != 0, nil
+	case "-s":
+		return info.Size() > 0, nil
+	case "-g":
+		return mode&os.ModeSetgid != 0, nil
+	case "-u":
+		return mode&os.ModeSetuid != 0, nil
+	case "-k":
+		return mode&os.ModeSticky != 0, nil
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false, nil
+	}
+
+	switch op {
+	case "-O":
+		return int(stat.Uid) == os.Geteuid(), nil
+	case "-G":
+		return int(stat.Gid) == os.Getegid(), nil
+	case "-N":
+		return stat.Mtim.Nano() > stat.Atim.Nano(), nil
+	}
+
+	return false, fmt.Errorf("%s: unary operator expected", op)
+}
+
+// binaryTest evaluate a binary test like `a = b` or `1 -lt 2`
+func (sh *Shell) binaryTest(op, left, right string) (bool, error) {
+	switch op {
+	case "=", "==":
+		return left == right, nil
+	case "!=":
+		return left != right, nil
+	case "<":
+		return left < right, nil
+	case ">":
+		return left > right, nil
+
+	case "-eq", "-ne", "-lt", "-le", "-gt", "-ge":
+		a, err := parseInteger(left)
+		if err != nil {
+			return false, err
+		}
+
+		b, err := parseInteger(right)
+		if err != nil {
+			return false, err
+		}
+
+		return compareIntegers(op, a, b), nil
+
+	case "-ef":
+		return sameFile(sh.path(left), sh.path(right)), nil
+
+	case "-nt", "-ot":
+		leftInfo, leftErr := os.Stat(sh.path(left))
+		rightInfo, rightErr := os.Stat(sh.path(right))
+
+		if op == "-ot" {
+			leftInfo, leftErr, rightInfo, rightErr = rightInfo, rightErr, leftInfo, leftErr
+		}
+
+		switch {
+		case leftErr != nil:
+			return false, nil
+		case rightErr != nil:
+			return true, nil
+		}
+		return leftInfo.ModTime().After(rightInfo.ModTime()), nil
+	}
+
+	return false, fmt.Errorf("%s: binary operator expected", op)
+}
+
+// parseInteger parse an operand of the integer comparisons
+func parseInteger(text string) (int64, error) {
+	n, err := strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: integer expression expected", text)
+	}
+
+	return n, nil
+}
+
+// compareIntegers evaluate an integer comparison like `-lt`
+func compareIntegers(op string, a, b int64) bool {
+	switch op {
+	case "-eq":
+		return a == b
+	case "-ne":
+		return a != b
+	case "-lt":
+		return a < b
+	case "-le":
+		return a <= b
+	case "-gt":
+		return a > b
+	}
+
+	return a >= b
+}
+
+// statusOfTest return the status of a test
+func statusOfTest(result bool) int {
+	if result {
+		return STATUS_SUCCESS
+	}
+
+	return STATUS_FAILURE
+}
@@ -0,0 +1,508 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// initPwd set PWD to the working directory, unless it already
+// name it through symbolic links, and export it.
+func (sh *Shell) initPwd() {
+	pwd, _ := sh.vars.Get("PWD")
+
+	if !filepath.IsAbs(pwd) || !sameFile(pwd, ".") {
+		if dir, err := os.Getwd(); err == nil {
+			pwd = dir
+		}
+	}
+
+	sh.vars.Set("PWD", pwd)
+	sh.vars.Declare("PWD").Exported = true
+}
+
+// sameFile report whether the two paths name the same file
+func sameFile(a, b string) bool {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+
+	return errA == nil && errB == nil && os.SameFile(infoA, infoB)
+}
+
+// path return the path of the file name refer to. Relative names
+// are relative to the working directory of the subshell, if it's one.
+func (sh *Shell) path(name string) string {
+	if sh.dir == "" || name == "" || filepath.IsAbs(name) {
+		return name
+	}
+
+	return sh.dir + "/" + name
+}
+
+// pwd return the logical working directory, which may contain
+// symbolic links, or the physical one when physical is true
+func (sh *Shell) pwd(physical bool) (string, error) {
+	if pwd, _ := sh.vars.Get("PWD"); !physical && filepath.IsAbs(pwd) && sameFile(pwd, sh.path(".")) {
+		return pwd, nil
+	}
+
+	dir := sh.dir
+	if dir == "" {
+		var err error
+		if dir, err = os.Getwd(); err != nil {
+			return "", err
+		}
+	}
+
+	return filepath.EvalSymlinks(dir)
+}
+
+// changeDir change the working directory to path. A subshell keep its
+// own, after checking it could change to it like the shell would.
+func (sh *Shell) changeDir(path string) error {
+	if sh.dir == "" {
+		return os.Chdir(path)
+	}
+
+	path = sh.path(path)
+	info, err := os.Stat(path)
+
+	switch {
+	case err != nil:
+		return err
+	case !info.IsDir():
+		err = syscall.ENOTDIR
+	default:
+		err = syscall.Access(path, ACCESS_EXECUTE)
+	}
+
+	if err != nil {
+		return &os.PathError{Op: "chdir", Path: path, Err: err}
+	}
+
+	sh.dir = path
+
+	return nil
+}
+
+// chdir change the working directory and update PWD and OLDPWD.
+// Without physical, `..` remove the last component of the logical
+// path, instead of going to the parent of the directory it link to.
+func (sh *Shell) chdir(dir string, physical bool) error {
+	oldPwd, err := sh.pwd(false)
+	if err != nil {
+		return err
+	}
+
+	path := dir
+	if !physical {
+		if !filepath.IsAbs(path) {
+			path = oldPwd + "/" + path
+		}
+		path = filepath.Clean(path)
+	}
+
+	if err := sh.changeDir(path); err != nil {
+		var pathErr *os.PathError
+		if errors.As(err, &pathErr) {
+			err = fmt.Errorf("%s: %s", dir, errorMessage(pathErr.Err))
+		}
+		return err
+	}
+
+	if physical {
+		if path, err = sh.pwd(true); err != nil {
+			return err
+		}
+	}
+
+	if err := sh.vars.Set("OLDPWD", oldPwd); err != nil {
+		return err
+	}
+
+	return sh.vars.Set("PWD", path)
+}
+
+// cd change the working directory to the operand, HOME without
+// operand or OLDPWD with `-`. Relative operands are searched in the
+// CDPATH directories. `-P` resolve the symbolic links, `-L` don't.
+func (sh *Shell) cd(args []string, io streams) int {
+	physical := false
+	i := 1
+
+	for ; i < len(args) && len(args[i]) > 1 && args[i][0] == '-'; i++ {
+		if args[i] == "--" {
+			i++
+			break
+		}
+
+		for _, flag := range []byte(args[i][1:]) {
+			switch flag {
+			case 'L':
+				physical = false
+			case 'P':
+				physical = true
+			default:
+				printError(io, fmt.Errorf("cd: -%c: invalid option", flag))
+				return STATUS_MISUSE
+			}
+		}
+	}
+
+	operands := args[i:]
+	print := false
+	var dir string
+
+	switch {
+	case len(operands) > 1:
+		printError(io, fmt.Errorf("cd: too many arguments"))
+		return STATUS_FAILURE
+
+	case len(operands) == 0:
+		home, set := sh.vars.Get("HOME")
+		if !set {
+			printError(io, fmt.Errorf("cd: HOME not set"))
+			return STATUS_FAILURE
+		}
+		dir = home
+
+	case operands[0] == "-":
+		oldPwd, set := sh.vars.Get("OLDPWD")
+		if !set {
+			printError(io, fmt.Errorf("cd: OLDPWD not set"))
+			return STATUS_FAILURE
+		}
+		dir, print = oldPwd, true
+
+	default:
+		dir = operands[0]
+		if found, ok := sh.searchCdPath(dir); ok {
+			dir, print = found, true
+		}
+	}
+
+	if dir == "" {
+		return STATUS_SUCCESS
+	}
+
+	if err := sh.chdir(dir, physical); err != nil {
+		printError(io, fmt.Errorf("cd: %s", err.Error()))
+		return STATUS_FAILURE
+	}
+
+	if print {
+		pwd, _ := sh.vars.Get("PWD")
+		fmt.Fprintln(io.stdout, pwd)
+	}
+
+	return STATUS_SUCCESS
+}
+
+// searchCdPath return the first directory named dir in the CDPATH
+// directories. Absolute paths and paths starting with `.` or `..`
+// are not searched, and so is a directory found from an
+// empty entry, which is the working directory.
+func (sh *Shell) searchCdPath(dir string) (string, bool) {
+	cdPath, _ := sh.vars.Get("CDPATH")
+	first, _, _ := strings.Cut(dir, "/")
+
+	if cdPath == "" || filepath.IsAbs(dir) || first == "." || first == ".." {
+		return "", false
+	}
+
+	for _, entry := range filepath.SplitList(cdPath) {
+		if entry == "" {
+			if info, err := os.Stat(sh.path(dir)); err == nil && info.IsDir() {
+				return "", false
+			}
+			continue
+		}
+
+		path := strings.TrimSuffix(entry, "/") + "/" + dir
+		if info, err := os.Stat(sh.path(path)); err == nil && info.IsDir() {
+			return path, true
+		}
+	}
+
+	return "", false
+}
+
+// pwdBuiltin print the logical working directory,
+// or the physical one with `-P`
+func (sh *Shell) pwdBuiltin(args []string, io streams) int {
+	flags, _, err := parseFlags(args, "LP")
+	if err != nil {
+		printError(io, fmt.Errorf("pwd: %s", err.Error()))
+		return STATUS_MISUSE
+	}
+
+	dir, err := sh.pwd(flags['P'])
+	if err != nil {
+		printError(io, fmt.Errorf("pwd: %s", err.Error()))
+		return STATUS_FAILURE
+	}
+
+	fmt.Fprintln(io.stdout, dir)
+
+	return STATUS_SUCCESS
+}
+
+// dirEntries return the directory stack, whose
+// first entry is always the working directory
+func (sh *Shell) dirEntries() []string {
+	pwd, _ := sh.vars.Get("PWD")
+
+	return append([]string{pwd}, sh.dirStack...)
+}
+
+// dirIndex return the index in the directory stack of the `+N`
+// entry, counted from the left, or `-N` entry, counted from the
+// right. It report false if the argument is not such an entry.
+func (sh *Shell) dirIndex(arg string) (int, bool, error) {
+	if len(arg) < 2 || (arg[0] != '+' && arg[0] != '-') {
+		return 0, false, nil
+	}
+
+	n, err := strconv.Atoi(arg[1:])
+	if err != nil || n < 0 {
+		return 0, false, nil
+	}
+
+	size := len(sh.dirStack) + 1
+	if n >= size {
+		return 0, true, fmt.Errorf("%s: directory stack index out of range", arg)
+	}
+
+	if arg[0] == '-' {
+		n = size - 1 - n
+	}
+
+	return n, true, nil
+}
+
+// dirEntry return the directory stack entry of a `~N`, `~+N`
+// or `~-N` tilde prefix, or false if there's no such entry
+func (sh *Shell) dirEntry(prefix string) (string, bool) {
+	if prefix != "" && prefix[0] >= '0' && prefix[0] <= '9' {
+		prefix = "+" + prefix
+	}
+
+	n, ok, err := sh.dirIndex(prefix)
+	if !ok || err != nil {
+		return "", false
+	}
+
+	return sh.dirEntries()[n], true
+}
+
+// pushd add the directory on top of the directory stack and
+// change to it. Without operand, the two top directories are
+// exchanged, and with `+N` or `-N`, the stack is rotated to put
+// this entry on top. `-n` add the directory below the top one,
+// without changing to it.
+func (sh *Shell) pushd(args []string, io streams) int {
+	flags, operands, err := parseDirFlags(args)
+	if err != nil {
+		printError(io, fmt.Errorf("pushd: %s", err.Error()))
+		return STATUS_MISUSE
+	}
+
+	entries := sh.dirEntries()
+	keep := false
+
+	switch {
+	case len(operands) > 1:
+		printError(io, fmt.Errorf("pushd: too many arguments"))
+		return STATUS_FAILURE
+
+	case len(operands) == 0:
+		if len(sh.dirStack) == 0 {
+			printError(io, fmt.Errorf("pushd: no other directory"))
+			return STATUS_FAILURE
+		}
+		entries[0], entries[1] = entries[1], entries[0]
+
+	default:
+		n, isIndex, err := sh.dirIndex(operands[0])
+		if err != nil {
+			printError(io, fmt.Errorf("pushd: %s", err.Error()))
+			return STATUS_FAILURE
+		}
+
+		if isIndex {
+			entries = append(entries[n:], entries[:n]...)
+			break
+		}
+
+		if flags['n'] {
+			entries = append(entries[:1], append([]string{operands[0]}, entries[1:]...)...)
+			keep = true
+			break
+		}
+
+		if err := sh.chdir(operands[0], false); err != nil {
+			printError(io, fmt.Errorf("pushd: %s", err.Error()))
+			return STATUS_FAILURE
+		}
+
+		pwd, _ := sh.vars.Get("PWD")
+		entries = append([]string{pwd}, entries...)
+	}
+
+	return sh.setDirEntries(entries, keep, "pushd", io)
+}
+
+// popd remove the top directory of the directory stack and
+// change to the new top one, or remove the `+N` or `-N` entry.
+// `-n` change the stack only.
+func (sh *Shell) popd(args []string, io streams) int {
+	flags, operands, err := parseDirFlags(args)
+	if err != nil {
+		printError(io, fmt.Errorf("popd: %s", err.Error()))
+		return STATUS_MISUSE
+	}
+
+	if len(operands) > 1 {
+		printError(io, fmt.Errorf("popd: too many arguments"))
+		return STATUS_FAILURE
+	}
+
+	if len(sh.dirStack) == 0 {
+		printError(io, fmt.Errorf("popd: directory stack empty"))
+		return STATUS_FAILURE
+	}
+
+	n := 0
+	if len(operands) == 1 {
+		index, isIndex, err := sh.dirIndex(operands[0])
+		if !isIndex {
+			err = fmt.Errorf("%s: invalid argument", operands[0])
+		}
+		if err != nil {
+			printError(io, fmt.Errorf("popd: %s", err.Error()))
+			return STATUS_FAILURE
+		}
+		n = index
+	}
+
+	entries := sh.dirEntries()
+
+	// `-n` keep the working directory, removing the next entry
+	if n == 0 && flags['n'] {
+		n = 1
+	}
+	entries = append(entries[:n], entries[n+1:]...)
+
+	return sh.setDirEntries(entries, flags['n'] || n != 0, "popd", io)
+}
+
+// setDirEntries replace the directory stack and change to its
+// top directory, unless keep is true. The new stack is printed.
+func (sh *Shell) setDirEntries(entries []string, keep bool, builtin string, io streams) int {
+	if pwd, _ := sh.vars.Get("PWD"); !keep && entries[0] != pwd {
+		if err := sh.chdir(entries[0], false); err != nil {
+			printError(io, fmt.Errorf("%s: %s", builtin, err.Error()))
+			return STATUS_FAILURE
+		}
+	}
+
+	sh.dirStack = entries[1:]
+	sh.printDirs(sh.dirEntries(), nil, io)
+
+	return STATUS_SUCCESS
+}
+
+// dirs print the directory stack, on a single line by default.
+// `-p` print an entry per line, `-v` with its index, `-l` don't
+// replace HOME with a tilde and `-c` clear the stack.
+// With `+N` or `-N`, only this entry is printed.
+func (sh *Shell) dirs(args []string, io streams) int {
+	flags := make(map[byte]bool)
+	entries := sh.dirEntries()
+
+	for _, arg := range args[1:] {
+		n, isIndex, err := sh.dirIndex(arg)
+		if err != nil {
+			printError(io, fmt.Errorf("dirs: %s", err.Error()))
+			return STATUS_FAILURE
+		}
+
+		if isIndex {
+			entries = entries[n : n+1]
+			continue
+		}
+
+		if len(arg) < 2 || arg[0] != '-' || strings.Trim(arg[1:], "clpv") != "" {
+			printError(io, fmt.Errorf("dirs: %s: invalid argument", arg))
+			return STATUS_MISUSE
+		}
+
+		for _, flag := range []byte(arg[1:]) {
+			flags[flag] = true
+		}
+	}
+
+	if flags['c'] {
+		sh.dirStack = nil
+		return STATUS_SUCCESS
+	}
+
+	sh.printDirs(entries, flags, io)
+
+	return STATUS_SUCCESS
+}
+
+// printDirs print the directory stack entries on a line, or one
+// per line with the `p` flag, numbered with `v`. HOME is replaced
+// by a tilde, unless the `l` flag is set.
+func (sh *Shell) printDirs(entries []string, flags map[byte]bool, io streams) {
+	home, _ := sh.vars.Get("HOME")
+	home = strings.TrimSuffix(home, "/")
+	names := make([]string, len(entries))
+
+	for i, entry := range entries {
+		names[i] = entry
+		if home != "" && !flags['l'] && (entry == home || strings.HasPrefix(entry, home+"/")) {
+			names[i] = "~" + entry[len(home):]
+		}
+	}
+
+	switch {
+	case flags['v']:
+		for i, name := range names {
+			fmt.Fprintf(io.stdout, "%2d  %s\n", i, name)
+		}
+	case flags['p']:
+		for _, name := range names {
+			fmt.Fprintln(io.stdout, name)
+		}
+	default:
+		fmt.Fprintln(io.stdout, strings.Join(names, " "))
+	}
+}
+
+// parseDirFlags split the arguments of pushd and popd into their
+// flags and operands. `+N` and `-N` are operands, not flags.
+func parseDirFlags(args []string) (map[byte]bool, []string, error) {
+	flags := make(map[byte]bool)
+	var operands []string
+
+	for i, arg := range args[1:] {
+		switch {
+		case arg == "--":
+			return flags, append(operands, args[i+2:]...), nil
+		case arg == "-n":
+			flags['n'] = true
+		case len(arg) > 1 && arg[0] == '-' && (arg[1] < '0' || arg[1] > '9'):
+			return nil, nil, fmt.Errorf("%s: invalid option", arg)
+		default:
+			operands = append(operands, arg)
+		}
+	}
+
+	return flags, operands, nil
+}
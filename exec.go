@@ -0,0 +1,474 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/Aboubakary833/cish/parser"
+)
+
+// streams are the files open on the descriptors of a command
+type streams struct {
+	stdin  *os.File
+	stdout *os.File
+	stderr *os.File
+	// extra are the descriptors above 2 opened by redirections
+	extra map[int]*os.File
+}
+
+// defaultStreams return the standard streams of the shell
+func defaultStreams() streams {
+	return streams{
+		stdin:  os.Stdin,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	}
+}
+
+// run parse the command line and execute it.
+// The terminal leave the raw mode while the command line is running,
+// so programs get the terminal as the user would expect it.
+func (sh *Shell) run(buffer string) {
+	quitRawMode(sh.sourceFd, sh.termState)
+	defer enterRawMode(sh.sourceFd)
+
+	fmt.Print("\n")
+
+	list, err := parser.Parse(buffer)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cish: %s\n", err.Error())
+		sh.status.Set(STATUS_MISUSE)
+		return
+	}
+
+	// the signals received while the line was typed
+	sh.runTraps(defaultStreams())
+
+	sh.interrupted.Store(false)
+	sh.runList(list, defaultStreams())
+
+	if sh.interrupted.Load() {
+		fmt.Print("\n")
+		sh.status.Set(STATUS_SIGNAL + int(syscall.SIGINT))
+	}
+	sh.notifyJobs(os.Stderr)
+}
+
+// runList run the AND-OR lists one after the other.
+// Asynchronous lists are not waited for. The traps of
+// the signals received are run after each list.
+func (sh *Shell) runList(list *parser.List, io streams) {
+	for _, item := range list.Items {
+		if item.Async {
+			sh.runBackground(item.AndOr, io)
+		} else {
+			sh.runAndOr(item.AndOr, io)
+		}
+
+		if sh.jumping() {
+			return
+		}
+
+		sh.runTraps(io)
+	}
+}
+
+// runAndOr run the first pipeline, then each of the following
+// pipelines whose operator match the last exit status. When the
+// list fail, the ERR trap is run and errexit leave the shell.
+func (sh *Shell) runAndOr(andOr *parser.AndOr, io streams) {
+	sh.runPipeline(andOr.Pipelines[0], io)
+	last := 0
+
+	for i, op := range andOr.Ops {
+		if sh.jumping() {
+			return
+		}
+
+		if (op == "&&") != (sh.status.Code() == STATUS_SUCCESS) {
+			continue
+		}
+
+		sh.runPipeline(andOr.Pipelines[i+1], io)
+		last = i + 1
+	}
+
+	if last == len(andOr.Ops) && sh.failed(andOr.Pipelines[last]) {
+		sh.runPseudoTrap("ERR", io)
+		sh.exiting = sh.exiting || sh.options[OPTION_ERREXIT]
+	}
+}
+
+// failed report whether the pipeline which was just run failed, and the
+// failure must run the ERR trap: it's not negated with `!`, nor a condition,
+// nor a compound command whose own commands already ran the trap.
+func (sh *Shell) failed(pipeline *parser.Pipeline) bool {
+	if sh.status.Code() == STATUS_SUCCESS || pipeline.Bang || sh.conditions != 0 || sh.jumping() {
+		return false
+	}
+
+	_, simple := pipeline.Commands[0].(*parser.SimpleCommand)
+
+	return simple || len(pipeline.Commands) > 1
+}
+
+// runCommand run a command of any kind in the current shell
+func (sh *Shell) runCommand(cmd parser.Command, io streams) {
+	defer func(saved streams) { sh.io = saved }(sh.io)
+	sh.io = io
+
+	switch cmd := cmd.(type) {
+	case *parser.SimpleCommand:
+		sh.runSimple(cmd, io)
+
+	case *parser.BraceGroup:
+		sh.withRedirects(cmd.Redirects, io, func(io streams) {
+			sh.runList(cmd.Body, io)
+		})
+
+	case *parser.Subshell:
+		sh.withRedirects(cmd.Redirects, io, func(io streams) {
+			sub := sh.subshell()
+			sub.runList(cmd.Body, io)
+			sub.runExitTrap(io)
+			sh.status.Set(sub.status.Code())
+		})
+
+	case *parser.IfClause:
+		sh.withRedirects(cmd.Redirects, io, func(io streams) {
+			sh.runIf(cmd, io)
+		})
+
+	case *parser.WhileClause:
+		sh.withRedirects(cmd.Redirects, io, func(io streams) {
+			sh.runWhile(cmd, io)
+		})
+
+	case *parser.ForClause:
+		sh.withRedirects(cmd.Redirects, io, func(io streams) {
+			sh.runFor(cmd, io)
+		})
+
+	case *parser.CaseClause:
+		sh.withRedirects(cmd.Redirects, io, func(io streams) {
+			sh.runCase(cmd, io)
+		})
+
+	case *parser.ArithCommand:
+		sh.withRedirects(cmd.Redirects, io, func(io streams) {
+			sh.runArith(cmd, io)
+		})
+
+	case *parser.CondCommand:
+		sh.withRedirects(cmd.Redirects, io, func(io streams) {
+			sh.runCond(cmd, io)
+		})
+
+	case *parser.FunctionDefinition:
+		sh.funcs[cmd.Name] = cmd
+		sh.status.Set(STATUS_SUCCESS)
+	}
+}
+
+// withRedirects call run with the redirections applied to io.
+// The command is not run if a redirection fail.
+func (sh *Shell) withRedirects(redirects []*parser.Redirect, io streams, run func(io streams)) {
+	io, opened, err := sh.redirect(redirects, io)
+	if err != nil {
+		sh.fail(io, err, STATUS_FAILURE)
+		return
+	}
+	defer closeFiles(opened)
+
+	run(io)
+}
+
+// expandArgs expand the words of a simple command
+func (sh *Shell) expandArgs(cmd *parser.SimpleCommand) ([]string, error) {
+	args := make([]string, 0, len(cmd.Args))
+
+	for _, word := range cmd.Args {
+		fields, err := sh.expandFields(word.Text)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, fields...)
+	}
+
+	return args, nil
+}
+
+// runSimple expand and run a simple command. Without command name,
+// the assignments are made in the shell. Otherwise they are only
+// visible to the command, and exported to it.
+func (sh *Shell) runSimple(cmd *parser.SimpleCommand, io streams) {
+	sh.runPseudoTrap("DEBUG", io)
+	sh.substituted = false
+
+	args, err := sh.expandArgs(cmd)
+	if err != nil {
+		sh.fail(io, err, STATUS_FAILURE)
+		return
+	}
+
+	sh.withRedirects(cmd.Redirects, io, func(io streams) {
+		if len(args) == 0 {
+			if err := sh.assign(cmd.Assigns, sh.vars.Set); err != nil {
+				sh.fail(io, err, STATUS_FAILURE)
+				return
+			}
+
+			// the status is the one of the last command substitution
+			sh.status.Set(STATUS_SUCCESS)
+			if sh.substituted {
+				sh.status.Set(sh.substStatus)
+			}
+			return
+		}
+
+		if len(cmd.Assigns) != 0 {
+			sh.vars.push()
+			defer sh.vars.pop()
+
+			if err := sh.assign(cmd.Assigns, sh.vars.setTemporary); err != nil {
+				sh.fail(io, err, STATUS_FAILURE)
+				return
+			}
+		}
+
+		if sh.options[OPTION_XTRACE] {
+			quoted := make([]string, len(args))
+			for i, arg := range args {
+				quoted[i] = shellQuote(arg)
+			}
+			sh.trace(strings.Join(quoted, " "))
+		}
+
+		if fn, ok := sh.funcs[args[0]]; ok {
+			sh.callFunction(fn, args, io)
+			return
+		}
+
+		if status, ok := sh.runBuiltin(args, io); ok {
+			sh.status.Set(status)
+			return
+		}
+
+		sh.status.Set(sh.runProgram(args, io))
+	})
+}
+
+// runProgram run the program named args[0] and return its status
+func (sh *Shell) runProgram(args []string, io streams) int {
+	program, err := sh.startProgram(args, io)
+	if err != nil {
+		return statusFromError(err)
+	}
+
+	return sh.waitProcess(program)
+}
+
+// assign expand the values of the assignments and give them to
+// the variables with set. Assigning a readonly variable is fatal.
+func (sh *Shell) assign(assigns []*parser.Assign, set func(name, value string) error) error {
+	for _, assign := range assigns {
+		value, err := sh.expandAssign(assign.Value.Text)
+		if err != nil {
+			return err
+		}
+
+		sh.trace(assign.Name + "=" + shellQuote(value))
+
+		if err := set(assign.Name, value); err != nil {
+			return &fatalError{err, STATUS_FAILURE}
+		}
+	}
+
+	return nil
+}
+
+// trace print the command about to be run, whose words are quoted
+// like in the source, on the standard error when xtrace is set
+func (sh *Shell) trace(quoted string) {
+	if sh.options[OPTION_XTRACE] {
+		fmt.Fprintf(sh.io.stderr, "+ %s\n", quoted)
+	}
+}
+
+// startProgram search the program named args[0] and start it.
+// Errors are reported on the command standard error.
+func (sh *Shell) startProgram(args []string, io streams) (*process, error) {
+	path, err := sh.lookPath(args[0])
+	if entry, ok := sh.hash[args[0]]; ok && err == nil {
+		entry.hits++
+		sh.hash[args[0]] = entry
+	}
+
+	return sh.startPath(path, err, args, io)
+}
+
+// startPath start the program at path, or report the error
+// of its lookup, on the command standard error.
+func (sh *Shell) startPath(path string, err error, args []string, io streams) (program *process, _ error) {
+	if sh.onStart != nil {
+		defer func() {
+			if program != nil {
+				sh.onStart(program.pid)
+			} else {
+				sh.onStart(0)
+			}
+		}()
+	}
+
+	if err != nil {
+		if statusFromError(err) == STATUS_NOT_FOUND {
+			fmt.Fprintf(io.stderr, "cish: %s: command not found\n", args[0])
+		} else {
+			fmt.Fprintf(io.stderr, "cish: %s: %s\n", args[0], err.Error())
+		}
+		return nil, err
+	}
+
+	cmd := exec.Command(path, args[1:]...)
+	cmd.Args = args
+	cmd.Stdin = io.stdin
+	cmd.Stdout = io.stdout
+	cmd.Stderr = io.stderr
+	cmd.ExtraFiles = io.extraFiles()
+	cmd.Env = sh.vars.Environ()
+	cmd.Dir = sh.dir
+
+	program, err = sh.startProcess(cmd)
+	if errors.Is(err, syscall.ENOEXEC) {
+		program, err = sh.startScript(cmd)
+	}
+	if err != nil {
+		fmt.Fprintf(io.stderr, "cish: %s: %s\n", args[0], err.Error())
+		return nil, err
+	}
+
+	return program, nil
+}
+
+// startScript start the script of the command, which is not a program
+// nor start with a shebang, as POSIX want it: it's run by cish itself.
+func (sh *Shell) startScript(cmd *exec.Cmd) (*process, error) {
+	shell, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	script := exec.Command(shell, append([]string{cmd.Path}, cmd.Args[1:]...)...)
+	script.Args[0] = "cish"
+	script.Stdin = cmd.Stdin
+	script.Stdout = cmd.Stdout
+	script.Stderr = cmd.Stderr
+	script.ExtraFiles = cmd.ExtraFiles
+	script.Env = cmd.Env
+	script.Dir = cmd.Dir
+
+	return sh.startProcess(script)
+}
+
+// hashEntry is a command of the hash table
+type hashEntry struct {
+	path string
+	// hits is the number of times the command was run
+	hits int
+}
+
+// lookPath search the program name in the PATH directories.
+// Names containing a slash are used as is.
+// Found paths are kept in the shell hash table,
+// which is cleared when PATH change.
+func (sh *Shell) lookPath(name string) (string, error) {
+	if strings.Contains(name, "/") {
+		return exec.LookPath(sh.path(name))
+	}
+
+	path := sh.syncHash()
+
+	if entry, ok := sh.hash[name]; ok {
+		if _, err := os.Stat(entry.path); err == nil {
+			return entry.path, nil
+		}
+		delete(sh.hash, name)
+	}
+
+	program, err := sh.findProgram(name, path)
+	if err != nil {
+		return "", err
+	}
+
+	sh.hash[name] = hashEntry{path: program}
+
+	return program, nil
+}
+
+// syncHash clear the hash table when PATH changed
+// since it was filled, and return PATH.
+func (sh *Shell) syncHash() string {
+	path, _ := sh.vars.Get("PATH")
+
+	if path != sh.hashPath {
+		clear(sh.hash)
+		sh.hashPath = path
+	}
+
+	return path
+}
+
+// findProgram return the first executable file named name
+// in the directories of path.
+func (sh *Shell) findProgram(name, path string) (string, error) {
+	programs, err := sh.findPrograms(name, path, false)
+	if err != nil {
+		return "", err
+	}
+
+	return programs[0], nil
+}
+
+// findPrograms return the executable files named name in the
+// directories of path, or only the first one unless all is true.
+func (sh *Shell) findPrograms(name, path string, all bool) ([]string, error) {
+	var programs []string
+	var notExecutable error
+
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			dir = "."
+		}
+
+		program := dir + "/" + name
+		info, err := os.Stat(sh.path(program))
+
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		if info.Mode()&0111 == 0 {
+			notExecutable = &os.PathError{Op: "exec", Path: program, Err: syscall.EACCES}
+			continue
+		}
+
+		programs = append(programs, program)
+		if !all {
+			break
+		}
+	}
+
+	switch {
+	case len(programs) != 0:
+		return programs, nil
+	case notExecutable != nil:
+		return nil, notExecutable
+	}
+
+	return nil, &exec.Error{Name: name, Err: exec.ErrNotFound}
+}
@@ -0,0 +1,860 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/Aboubakary833/cish/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// keepDir return a function restoring the working
+// directory, which the tests changing it call on cleanup
+func keepDir() func() {
+	dir, err := os.Getwd()
+
+	return func() {
+		if err == nil {
+			os.Chdir(dir)
+		}
+	}
+}
+
+// runScript run src in a new shell and return
+// its standard output and its last exit status.
+func runScript(t *testing.T, src string) (string, int) {
+	sh := newShell(-1, nil)
+	stdout, err := os.CreateTemp(t.TempDir(), "stdout")
+	require.NoError(t, err)
+	defer stdout.Close()
+
+	list, err := parser.Parse(src)
+	require.NoError(t, err)
+
+	sh.runList(list, streams{stdin: os.Stdin, stdout: stdout, stderr: stdout})
+
+	output, err := os.ReadFile(stdout.Name())
+	require.NoError(t, err)
+
+	return string(output), sh.status.Code()
+}
+
+func TestRunList(t *testing.T) {
+	t.Run("it should run the commands in order", func(t *testing.T) {
+		output, status := runScript(t, "echo a; echo b\necho c")
+
+		assert.Equal(t, "a\nb\nc\n", output)
+		assert.Equal(t, STATUS_SUCCESS, status)
+	})
+
+	t.Run("it should run the AND-OR lists depending on the status", func(t *testing.T) {
+		output, status := runScript(t, "false && echo a || echo b; true || echo c && echo d")
+
+		assert.Equal(t, "b\nd\n", output)
+		assert.Equal(t, STATUS_SUCCESS, status)
+	})
+
+	t.Run("it should report unknown commands", func(t *testing.T) {
+		output, status := runScript(t, "cish-command-that-does-not-exist")
+
+		assert.Equal(t, "cish: cish-command-that-does-not-exist: command not found\n", output)
+		assert.Equal(t, STATUS_NOT_FOUND, status)
+	})
+
+	t.Run("it should exit when a command fail with errexit", func(t *testing.T) {
+		output, status := runScript(t, "set -e; false || true; ! true; if false; then :; fi; f() { false; echo f; }; echo a; f; echo b")
+
+		assert.Equal(t, "a\n", output)
+		assert.Equal(t, STATUS_FAILURE, status)
+	})
+
+	t.Run("it should print the commands with xtrace", func(t *testing.T) {
+		output, _ := runScript(t, "set -x; x=1 y=\"a b\"; echo \"$y\" c; set +x; echo d")
+
+		assert.Equal(t, "+ x=1\n+ y='a b'\n+ echo 'a b' c\na b c\n+ set +x\nd\n", output)
+	})
+
+	t.Run("it should not keep the subshell changes", func(t *testing.T) {
+		output, _ := runScript(t, "(set -o pipefail); set -o")
+
+		assert.Contains(t, output, "pipefail       \toff")
+	})
+}
+
+func TestRunPipeline(t *testing.T) {
+	t.Run("it should connect the commands", func(t *testing.T) {
+		output, status := runScript(t, "printf 'a\\nb\\nc\\n' | grep -v b | wc -l")
+
+		assert.Equal(t, "2", strings.TrimSpace(output))
+		assert.Equal(t, STATUS_SUCCESS, status)
+	})
+
+	t.Run("it should connect builtins and compound commands", func(t *testing.T) {
+		output, _ := runScript(t, "set -o | { grep pipefail; echo done; } | wc -l")
+
+		assert.Equal(t, "2", strings.TrimSpace(output))
+	})
+
+	t.Run("it should return the last command status", func(t *testing.T) {
+		_, status := runScript(t, "false | true")
+		assert.Equal(t, STATUS_SUCCESS, status)
+
+		_, status = runScript(t, "true | false")
+		assert.Equal(t, STATUS_FAILURE, status)
+	})
+
+	t.Run("it should return the last failure with pipefail", func(t *testing.T) {
+		_, status := runScript(t, "set -o pipefail; sh -c exit\\ 3 | false | true")
+
+		assert.Equal(t, 1, status)
+	})
+
+	t.Run("it should wait for the started commands when a pipe can't be created", func(t *testing.T) {
+		sh := newShell(-1, nil)
+		stdout, err := os.CreateTemp(t.TempDir(), "stdout")
+		require.NoError(t, err)
+		defer stdout.Close()
+
+		list, err := parser.Parse("x=$(echo a) | x=b | x=c")
+		require.NoError(t, err)
+
+		// only leave the fds of a single pipe
+		fds, err := os.ReadDir("/proc/self/fd")
+		require.NoError(t, err)
+
+		var limit syscall.Rlimit
+		require.NoError(t, syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit))
+		lowered := limit
+		lowered.Cur = uint64(len(fds) + 1)
+		require.NoError(t, syscall.Setrlimit(syscall.RLIMIT_NOFILE, &lowered))
+
+		sh.runList(list, streams{stdin: os.Stdin, stdout: stdout, stderr: stdout})
+		require.NoError(t, syscall.Setrlimit(syscall.RLIMIT_NOFILE, &limit))
+
+		output, err := os.ReadFile(stdout.Name())
+		require.NoError(t, err)
+		// the first command is done, its substitution having failed too
+		assert.Equal(t, "cish: pipe2: too many open files\ncish: pipe2: too many open files\n", string(output))
+		assert.Equal(t, STATUS_FAILURE, sh.status.Code())
+	})
+
+	t.Run("it should negate the status", func(t *testing.T) {
+		_, status := runScript(t, "! true | true")
+		assert.Equal(t, STATUS_FAILURE, status)
+
+		_, status = runScript(t, "! false")
+		assert.Equal(t, STATUS_SUCCESS, status)
+	})
+}
+
+func TestRunAssignments(t *testing.T) {
+	t.Run("it should assign the variables", func(t *testing.T) {
+		output, _ := runScript(t, "x=1 y=\"a b\"; echo $x $y")
+
+		assert.Equal(t, "1 a b\n", output)
+	})
+
+	t.Run("it should only pass the prefix assignments to the command", func(t *testing.T) {
+		output, _ := runScript(t, "x=1; x=2 sh -c 'echo $x'; echo $x")
+
+		assert.Equal(t, "2\n1\n", output)
+	})
+
+	t.Run("it should export the variables", func(t *testing.T) {
+		output, _ := runScript(t, "x=1; export x y=2; sh -c 'echo $x $y'; export -n x; sh -c 'echo $x $y'")
+
+		assert.Equal(t, "1 2\n2\n", output)
+	})
+
+	t.Run("it should not change readonly variables", func(t *testing.T) {
+		output, status := runScript(t, "readonly x=1; unset x; echo $x; x=2; echo after")
+
+		assert.Equal(t, "cish: unset: x: cannot unset: readonly variable\n1\ncish: x: readonly variable\n", output)
+		assert.Equal(t, STATUS_FAILURE, status)
+	})
+
+	t.Run("it should set the positional parameters", func(t *testing.T) {
+		output, _ := runScript(t, "set -- a 'b c'; echo $# $1 $2 \"$@\"")
+
+		assert.Equal(t, "2 a b c a b c\n", output)
+	})
+
+	t.Run("it should exit when a parameter expansion fail", func(t *testing.T) {
+		output, status := runScript(t, "(echo ${x:?}; echo a); echo $?; echo ${x:?oops}; echo b")
+
+		assert.Equal(t, "cish: x: parameter null or not set\n127\ncish: x: oops\n", output)
+		assert.Equal(t, STATUS_NOT_FOUND, status)
+	})
+
+	t.Run("it should fail on the unset parameters with nounset", func(t *testing.T) {
+		output, status := runScript(t, "set -u; echo ${x-d} \"$@\"; echo $x; echo after")
+
+		assert.Equal(t, "d\ncish: x: unbound variable\n", output)
+		assert.Equal(t, STATUS_NOT_FOUND, status)
+	})
+
+	t.Run("it should expand $- to the option letters", func(t *testing.T) {
+		output, _ := runScript(t, "echo \"[$-]\"; set -fu; echo $-; set +u -xC; echo $-")
+
+		assert.Equal(t, "[]\nfu\n+ echo fxC\nfxC\n", output)
+	})
+
+	t.Run("it should set $! to the background process id", func(t *testing.T) {
+		output, _ := runScript(t, "sleep 0 & test -n \"$!\" && echo $$ pid")
+
+		assert.Regexp(t, "^[0-9]+ pid\n$", output)
+	})
+}
+
+func TestRunSubstitutions(t *testing.T) {
+	t.Run("it should substitute the output of the commands", func(t *testing.T) {
+		output, _ := runScript(t, "echo $(echo a) `echo b`; echo $(echo a)c")
+
+		assert.Equal(t, "a b\nac\n", output)
+	})
+
+	t.Run("it should remove the trailing newlines", func(t *testing.T) {
+		output, _ := runScript(t, "x=\"$(printf 'a\\n\\nb\\n\\n')\"; echo \"[$x]\"")
+
+		assert.Equal(t, "[a\n\nb]\n", output)
+	})
+
+	t.Run("it should nest the substitutions", func(t *testing.T) {
+		output, _ := runScript(t, "echo $(echo $(echo a) \"$(echo ')')\") `echo \\`echo b\\``")
+
+		assert.Equal(t, "a ) b\n", output)
+	})
+
+	t.Run("it should run the substitutions in a subshell", func(t *testing.T) {
+		output, _ := runScript(t, "x=1; y=$(x=2; echo $x); echo $x $y")
+
+		assert.Equal(t, "1 2\n", output)
+	})
+
+	t.Run("it should set the status of an assignment to the substitution one", func(t *testing.T) {
+		_, status := runScript(t, "x=$(false)")
+		assert.Equal(t, STATUS_FAILURE, status)
+
+		_, status = runScript(t, "false; x=1")
+		assert.Equal(t, STATUS_SUCCESS, status)
+	})
+
+	t.Run("it should substitute in the here-documents", func(t *testing.T) {
+		output, _ := runScript(t, "cat <<EOF\n$(echo a) `echo b`\nEOF\n")
+
+		assert.Equal(t, "a b\n", output)
+	})
+}
+
+func TestRunGlobbing(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.go", "a.go", "c.txt"} {
+		require.NoError(t, os.WriteFile(dir+"/"+name, nil, 0o644))
+	}
+	setDir := "dir=" + dir + "; "
+
+	t.Run("it should expand the patterns to the matching paths", func(t *testing.T) {
+		output, _ := runScript(t, setDir+"echo $dir/*.go; echo $dir/[[:alpha:]].t?t")
+
+		assert.Equal(t, dir+"/a.go "+dir+"/b.go\n"+dir+"/c.txt\n", output)
+	})
+
+	t.Run("it should not expand the quoted patterns", func(t *testing.T) {
+		output, _ := runScript(t, setDir+"echo \"$dir/*.go\" $dir/\\*.go '*'")
+
+		assert.Equal(t, dir+"/*.go "+dir+"/*.go *\n", output)
+	})
+
+	t.Run("it should keep the patterns without match", func(t *testing.T) {
+		output, _ := runScript(t, setDir+"echo $dir/*.rs; set -o nullglob; echo x $dir/*.rs")
+
+		assert.Equal(t, dir+"/*.rs\nx\n", output)
+	})
+
+	t.Run("it should fail without match when failglob is set", func(t *testing.T) {
+		output, status := runScript(t, setDir+"set -o failglob; echo $dir/*.rs")
+
+		assert.Equal(t, "cish: no match: "+dir+"/*.rs\n", output)
+		assert.Equal(t, STATUS_FAILURE, status)
+	})
+
+	t.Run("it should not expand the patterns when noglob is set", func(t *testing.T) {
+		output, _ := runScript(t, setDir+"set -f; echo $dir/*.go")
+
+		assert.Equal(t, dir+"/*.go\n", output)
+	})
+}
+
+func TestRunFieldSplitting(t *testing.T) {
+	t.Run("it should pass a field per argument", func(t *testing.T) {
+		output, _ := runScript(t, "set -- 'a  b' c; x='1 2'; printf '[%s]' \"$@\" $x \"$x\" ''")
+
+		assert.Equal(t, "[a  b][c][1][2][1 2][]", output)
+	})
+}
+
+func TestRunCompoundCommands(t *testing.T) {
+	t.Run("it should run the branch of the first true condition", func(t *testing.T) {
+		output, _ := runScript(t, "for x in 1 2 3 4; do if test $x = 1; then echo one; elif test $x = 2; then echo two; elif test $x = 3; then echo three; else echo many; fi; done")
+
+		assert.Equal(t, "one\ntwo\nthree\nmany\n", output)
+	})
+
+	t.Run("it should set the status of the if clause", func(t *testing.T) {
+		_, status := runScript(t, "if false; then true; fi")
+		assert.Equal(t, STATUS_SUCCESS, status)
+
+		_, status = runScript(t, "if true; then false; fi")
+		assert.Equal(t, STATUS_FAILURE, status)
+	})
+
+	t.Run("it should loop while or until the condition succeed", func(t *testing.T) {
+		output, _ := runScript(t, "x=; while test \"$x\" != ...; do x=$x.; echo $x; done; until test -z \"$x\"; do x=${x#.}; done; echo end$x")
+
+		assert.Equal(t, ".\n..\n...\nend\n", output)
+	})
+
+	t.Run("it should loop over the positional parameters", func(t *testing.T) {
+		output, _ := runScript(t, "set -- a 'b c'; for x; do echo \"[$x]\"; done")
+
+		assert.Equal(t, "[a]\n[b c]\n", output)
+	})
+
+	t.Run("it should break and continue the loops", func(t *testing.T) {
+		output, _ := runScript(t, "for x in 1 2 3; do for y in a b c; do test $y = b && continue; test $x = 2 && continue 2; test $x = 3 && break 2; echo $x$y; done; done; echo end")
+
+		assert.Equal(t, "1a\n1c\nend\n", output)
+	})
+
+	t.Run("it should complain about break outside of loops", func(t *testing.T) {
+		output, status := runScript(t, "break; echo next")
+
+		assert.Equal(t, "cish: break: only meaningful in a `for', `while', or `until' loop\nnext\n", output)
+		assert.Equal(t, STATUS_SUCCESS, status)
+	})
+
+	t.Run("it should run the item of the first matching pattern", func(t *testing.T) {
+		output, _ := runScript(t, "for f in main.go README x; do case $f in\n*.go) echo go;;\n[A-Z]*|'x') echo other ;;\nesac; done")
+
+		assert.Equal(t, "go\nother\nother\n", output)
+	})
+
+	t.Run("it should redirect the output of the compound commands", func(t *testing.T) {
+		output, _ := runScript(t, "for x in a b; do echo $x; done | tr a-z A-Z")
+
+		assert.Equal(t, "A\nB\n", output)
+	})
+}
+
+func TestRunFunctions(t *testing.T) {
+	t.Run("it should call the functions with their parameters", func(t *testing.T) {
+		output, _ := runScript(t, "set -- a b; f() { echo $# \"$1\"; }; f 'x y'; echo $1; function g { f \"$@\" z; }; g 1")
+
+		assert.Equal(t, "1 x y\na\n2 1\n", output)
+	})
+
+	t.Run("it should scope the local variables dynamically", func(t *testing.T) {
+		output, _ := runScript(t, "x=global; show() { echo $x; }; f() { local x=local; show; y=set; }; f; echo $x $y")
+
+		assert.Equal(t, "local\nglobal set\n", output)
+	})
+
+	t.Run("it should return from the function", func(t *testing.T) {
+		output, status := runScript(t, "f() { for x in 1 2; do echo $x; return 3; done; echo no; }; f; echo $?; f")
+
+		assert.Equal(t, "1\n3\n1\n", output)
+		assert.Equal(t, 3, status)
+	})
+
+	t.Run("it should recurse up to FUNCNEST", func(t *testing.T) {
+		output, status := runScript(t, "f() { if test -n \"$1\"; then f \"${1#x}\"; else echo done; fi; echo $1; }; FUNCNEST=3; "+
+			"f x; (f xxxxx); echo $?; f xxxxx; echo after")
+
+		assert.Equal(t, "done\n\nx\ncish: f: maximum function nesting level exceeded (3)\n1\n"+
+			"cish: f: maximum function nesting level exceeded (3)\n", output)
+		assert.Equal(t, STATUS_FAILURE, status)
+	})
+
+	t.Run("it should print and remove the functions", func(t *testing.T) {
+		output, status := runScript(t, "f() { echo f; }; g() (echo g); declare -F; declare -f g; unset -f f; f")
+
+		assert.Equal(t, "declare -f f\ndeclare -f g\ng() (\n    echo g\n)\ncish: f: command not found\n", output)
+		assert.Equal(t, STATUS_NOT_FOUND, status)
+	})
+
+	t.Run("it should only use local and return in functions", func(t *testing.T) {
+		output, _ := runScript(t, "local x; return")
+
+		assert.Equal(t, "cish: local: can only be used in a function\ncish: return: can only `return' from a function\n", output)
+	})
+}
+
+func TestRunArithmetic(t *testing.T) {
+	t.Run("it should expand the arithmetic expressions", func(t *testing.T) {
+		output, _ := runScript(t, "x=5; echo $((x * 2 + $x)) \"$(( 16#ff, x++ ))\" $x $(( $(echo 3) ** 2 ))")
+
+		assert.Equal(t, "15 5 6 9\n", output)
+	})
+
+	t.Run("it should not expand the tildes of the expressions", func(t *testing.T) {
+		output, status := runScript(t, "x=5; echo $((~0)) $((~x)) $(( \"$x\" + 1 )); (( ~x ))")
+
+		assert.Equal(t, "-1 -6 6\n", output)
+		assert.Equal(t, STATUS_SUCCESS, status)
+	})
+
+	t.Run("it should succeed when the expression is not 0", func(t *testing.T) {
+		output, status := runScript(t, "i=0; while (( i < 3 )); do (( i++ )); done; echo $i; (( i - 3 ))")
+
+		assert.Equal(t, "3\n", output)
+		assert.Equal(t, STATUS_FAILURE, status)
+	})
+
+	t.Run("it should evaluate the let arguments", func(t *testing.T) {
+		output, status := runScript(t, "let x=2 'y = x << 3'; echo $x $y; let x-=2")
+
+		assert.Equal(t, "2 16\n", output)
+		assert.Equal(t, STATUS_FAILURE, status)
+	})
+
+	t.Run("it should report the division by 0", func(t *testing.T) {
+		output, status := runScript(t, "echo $((1 / 0)); (( x = 2 % 0 ))")
+
+		assert.Equal(t, "cish: 1 / 0: division by 0 (error token is \"0\")\ncish:  x = 2 % 0 : division by 0 (error token is \"0 \")\n", output)
+		assert.Equal(t, STATUS_FAILURE, status)
+	})
+}
+
+func TestRunDirectories(t *testing.T) {
+	t.Cleanup(keepDir())
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(dir+"/a/b", 0o755))
+	require.NoError(t, os.Symlink(dir+"/a/b", dir+"/link"))
+	setDir := "HOME=" + dir + "; cd; "
+
+	t.Run("it should change the directory and update PWD and OLDPWD", func(t *testing.T) {
+		output, status := runScript(t, setDir+"cd a/b; pwd; cd ..; echo $PWD $OLDPWD; cd -; cd /cish-missing-dir")
+
+		assert.Equal(t, dir+"/a/b\n"+dir+"/a "+dir+"/a/b\n"+dir+"/a/b\ncish: cd: /cish-missing-dir: No such file or directory\n", output)
+		assert.Equal(t, STATUS_FAILURE, status)
+	})
+
+	t.Run("it should keep the symbolic links unless -P is given", func(t *testing.T) {
+		output, _ := runScript(t, setDir+"cd link; pwd; pwd -P; cd ..; pwd; cd -P link; pwd")
+
+		assert.Equal(t, dir+"/link\n"+dir+"/a/b\n"+dir+"\n"+dir+"/a/b\n", output)
+	})
+
+	t.Run("it should search the directory in CDPATH", func(t *testing.T) {
+		output, _ := runScript(t, setDir+"cd /; CDPATH=:"+dir+"/a; cd b; cd ./b")
+
+		assert.Equal(t, dir+"/a/b\ncish: cd: ./b: No such file or directory\n", output)
+	})
+
+	t.Run("it should not change the directory of the shell from a subshell", func(t *testing.T) {
+		output, _ := runScript(t, setDir+"(cd a); x=$(cd a); cd a | true; pwd")
+
+		assert.Equal(t, dir+"\n", output)
+	})
+
+	t.Run("it should run the commands of a subshell in its directory", func(t *testing.T) {
+		output, _ := runScript(t, setDir+"(cd a; echo *; test -d b && /bin/pwd; cd -P ../link; pwd; cat <missing)")
+
+		assert.Equal(t, "b\n"+dir+"/a\n"+dir+"/a/b\ncish: missing: No such file or directory\n", output)
+	})
+
+	t.Run("it should keep the directory of each pipeline member", func(t *testing.T) {
+		output, _ := runScript(t, setDir+"cd a | { sleep 0.2; /bin/pwd; echo *; }; /bin/pwd")
+
+		assert.Equal(t, dir+"\na link\n"+dir+"\n", output)
+	})
+
+	t.Run("it should not change the directory of the shell from a background list", func(t *testing.T) {
+		output, _ := runScript(t, setDir+"cd a & wait; pwd; /bin/pwd; (cd / && sleep 0.3) & sleep 0.1; /bin/pwd; wait")
+
+		assert.Equal(t, dir+"\n"+dir+"\n"+dir+"\n", output)
+	})
+
+	t.Run("it should push and pop the directories", func(t *testing.T) {
+		output, _ := runScript(t, setDir+"pushd a; pushd b; dirs -v; echo ~1 ~-0; pushd; pushd +2; popd; popd -n; dirs -l; popd")
+
+		assert.Equal(t, "~/a ~\n"+
+			"~/a/b ~/a ~\n"+
+			" 0  ~/a/b\n 1  ~/a\n 2  ~\n"+
+			dir+"/a "+dir+"\n"+
+			"~/a ~/a/b ~\n"+
+			"~ ~/a ~/a/b\n"+
+			"~/a ~/a/b\n"+
+			"~/a\n"+
+			dir+"/a\n"+
+			"cish: popd: directory stack empty\n", output)
+	})
+}
+
+func TestRunBuiltinCommands(t *testing.T) {
+	t.Cleanup(keepDir())
+
+	t.Run("it should exit with the status", func(t *testing.T) {
+		output, status := runScript(t, "(exit 3); echo $?; f() { exit 4; }; for x in 1 2; do f; done; echo no")
+
+		assert.Equal(t, "3\n", output)
+		assert.Equal(t, 4, status)
+	})
+
+	t.Run("it should tell the kind of the commands", func(t *testing.T) {
+		output, status := runScript(t, "f() { :; }; type if f cd; type -t f sh; type cish-command-that-does-not-exist")
+
+		shPath, err := exec.LookPath("sh")
+		require.NoError(t, err)
+		assert.Equal(t, "if is a shell keyword\nf is a function\nf() {\n    :\n}\ncd is a shell builtin\n"+
+			"function\nfile\ncish: type: cish-command-that-does-not-exist: not found\n", output)
+		assert.Equal(t, STATUS_FAILURE, status)
+
+		output, _ = runScript(t, "type -p sh; sh -c :; type sh; command -v cd sh; command -V cd")
+		assert.Equal(t, shPath+"\n"+"sh is hashed ("+shPath+")\ncd\n"+shPath+"\ncd is a shell builtin\n", output)
+	})
+
+	t.Run("it should skip the functions", func(t *testing.T) {
+		output, _ := runScript(t, "pwd() { echo f; }; cd() { builtin cd \"$@\"; echo cd; }; pwd; command pwd >/dev/null && cd / && command -p echo x")
+
+		assert.Equal(t, "f\ncd\nx\n", output)
+	})
+
+	t.Run("it should run the null command, true and false", func(t *testing.T) {
+		output, status := runScript(t, ": ${x:=v}; echo $x; true && : && false || echo false; hash; type : true false")
+
+		assert.Equal(t, "v\nfalse\nhash: hash table empty\n"+
+			": is a shell builtin\ntrue is a shell builtin\nfalse is a shell builtin\n", output)
+		assert.Equal(t, STATUS_SUCCESS, status)
+	})
+
+	t.Run("it should view and reset the hash table", func(t *testing.T) {
+		output, _ := runScript(t, "hash; sleep 0; hash sh; sh -c :; sh -c :; hash; hash -r; hash; hash cish-command-that-does-not-exist")
+
+		sleepPath, err := exec.LookPath("sleep")
+		require.NoError(t, err)
+		shPath, err := exec.LookPath("sh")
+		require.NoError(t, err)
+		assert.Equal(t, "hash: hash table empty\nhits\tcommand\n   2\t"+shPath+"\n   1\t"+sleepPath+"\n"+
+			"hash: hash table empty\ncish: hash: cish-command-that-does-not-exist: not found\n", output)
+	})
+}
+
+func TestRunTextBuiltins(t *testing.T) {
+	t.Run("it should echo the arguments", func(t *testing.T) {
+		output, _ := runScript(t, `echo -n a b; echo -e 'c\td\0101'; echo -e 'e\cf'; echo -x '\n'`)
+
+		assert.Equal(t, "a bc\tdA\ne-x \\n\n", output)
+	})
+
+	t.Run("it should reuse the printf format for the arguments", func(t *testing.T) {
+		output, status := runScript(t, `printf '%s=%-3d|\n' a 1 b 2 c; printf '%05.1f %x %o %c %%\n' 3.14159 255 8 word`)
+
+		assert.Equal(t, "a=1  |\nb=2  |\nc=0  |\n003.1 ff 10 w %\n", output)
+		assert.Equal(t, STATUS_SUCCESS, status)
+	})
+
+	t.Run("it should expand the escapes and quote the printf arguments", func(t *testing.T) {
+		output, _ := runScript(t, `printf '%b|%q|%*s|%d\n' 'a\tb' "it's" 3 x "'A"; printf -v v '%s-' x y; echo $v; printf '%b\n' 'a\cb'; echo`)
+
+		assert.Equal(t, "a\tb|'it'\\''s'|  x|65\nx-y-\na\n", output)
+	})
+
+	t.Run("it should report the invalid numbers", func(t *testing.T) {
+		output, status := runScript(t, `printf '%d\n' abc`)
+
+		assert.Equal(t, "cish: printf: abc: invalid number\n0\n", output)
+		assert.Equal(t, STATUS_FAILURE, status)
+	})
+
+	t.Run("it should read and split the lines", func(t *testing.T) {
+		output, _ := runScript(t, "printf ' a  b c \\n d\\\\ e f\\n' | { read x y; read -r z; echo \"$x|$y|$z\"; }")
+
+		assert.Equal(t, "a|b c|d\\ e f\n", output)
+	})
+
+	t.Run("it should read with the IFS and the read options", func(t *testing.T) {
+		output, status := runScript(t, "echo 'a:b\\:c::d' | { IFS=: read -a arr; echo ${#arr[@]} ${arr[1]} ${arr[3]}; }; "+
+			"echo '  x y  ' | { read; echo \"[$REPLY]\"; }; printf 'abc;def' | { read -d ';' a; read -n 2 b; echo $a $b; read c; }")
+
+		assert.Equal(t, "4 b:c d\n[  x y  ]\nabc de\n", output)
+		assert.Equal(t, STATUS_FAILURE, status)
+	})
+
+	t.Run("it should time out reading", func(t *testing.T) {
+		output, status := runScript(t, "sleep 1 | read -t 0.1 x")
+
+		assert.Equal(t, "", output)
+		assert.Equal(t, STATUS_SIGNAL+14, status)
+	})
+}
+
+func TestRunConditionals(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	require.NoError(t, os.WriteFile(file, []byte("text"), 0644))
+
+	t.Run("it should evaluate the test expressions", func(t *testing.T) {
+		output, _ := runScript(t, "for e in '' 'a' '-n ' '! a' 'a = a' 'a != a' '2 -lt 10' 'a -a b' '( a -o b ) -a ! -z c' '-f "+file+"' '-d "+file+"' '-s "+file+"' '"+file+" -nt "+dir+"/none'; do "+
+			"test $e; echo -n $?; done; echo; [ a = b ]; echo $?; [ 1 -eq x ]; echo $?; [ a")
+
+		assert.Equal(t, "1001010000100\n1\n"+
+			"cish: [: x: integer expression expected\n2\n"+
+			"cish: [: missing `]'\n", output)
+	})
+
+	t.Run("it should evaluate the conditional commands", func(t *testing.T) {
+		output, status := runScript(t, `x='a b'; [[ $x == a* && -n $x ]] && echo 1; [[ $x == "a*" ]] || echo 2; `+
+			`[[ ! ( 1+1 -eq 3 || b < a ) ]] && echo 3; [[ -e `+file+` && $x != *c ]]; echo $?`)
+
+		assert.Equal(t, "1\n2\n3\n0\n", output)
+		assert.Equal(t, STATUS_SUCCESS, status)
+	})
+
+	t.Run("it should match the regular expressions", func(t *testing.T) {
+		output, status := runScript(t, `v=ab12cd; [[ $v =~ ([a-z]+)([0-9]+) ]] && echo ${BASH_REMATCH[0]} ${BASH_REMATCH[2]}; `+
+			`[[ a.c =~ "a." ]] && echo 1; [[ abc =~ "a." ]] || echo 2; [[ x =~ *a ]]`)
+
+		assert.Equal(t, "ab12 12\n1\n2\ncish: *a: invalid regular expression\n", output)
+		assert.Equal(t, STATUS_MISUSE, status)
+	})
+}
+
+func TestRunJobs(t *testing.T) {
+	t.Run("it should list and wait for the background jobs", func(t *testing.T) {
+		output, status := runScript(t, "sleep 0.2 & sleep 0.2 && false & jobs; wait %2; echo $?; wait; jobs")
+
+		assert.Equal(t, "[1]-  Running                 sleep 0.2 &\n[2]+  Running                 sleep 0.2 && false &\n1\n", output)
+		assert.Equal(t, STATUS_SUCCESS, status)
+	})
+
+	t.Run("it should keep the stopped jobs and continue them", func(t *testing.T) {
+		output, status := runScript(t, "sh -c 'kill -STOP $$'; echo $?; jobs; fg; echo $?")
+
+		line := "[1]+  Stopped (signal)        sh -c 'kill -STOP $$'\n"
+		assert.Equal(t, "\n"+line+"147\n"+line+"sh -c 'kill -STOP $$'\n0\n", output)
+		assert.Equal(t, STATUS_SUCCESS, status)
+
+		output, _ = runScript(t, "sleep 0.2 & kill -STOP $!; sleep 0.1; jobs -l | grep -c \"^\\[1\\]+ $! Stopped\"; bg; wait; echo $?")
+		assert.Equal(t, "1\n[1]+ sleep 0.2 &\n0\n", output)
+	})
+
+	t.Run("it should report the killed jobs", func(t *testing.T) {
+		output, _ := runScript(t, "sleep 5 & kill $!; sleep 0.1; jobs; sleep 5 & kill $!; wait $!; echo $?; jobs")
+
+		assert.Equal(t, "[1]+  Terminated              sleep 5\n143\n", output)
+	})
+
+	t.Run("it should wait for the background lists which start no program", func(t *testing.T) {
+		output, status := runScript(t, "(exit 4) & wait $!; echo $?; f() { return 3; }; f & pid=$!; wait $pid; echo $?")
+
+		assert.Equal(t, "4\n3\n", output)
+		assert.Equal(t, STATUS_SUCCESS, status)
+	})
+
+	t.Run("it should not wait for the background lists to start a program", func(t *testing.T) {
+		output, status := runScript(t, "read x & echo after; x=0; until [ $x = 2 ]; do x=$((x+1)); sleep 0.1; done & echo after; "+
+			"nosuch && sleep 5 & echo after; wait")
+
+		assert.Equal(t, "after\nafter\ncish: nosuch: command not found\nafter\n", output)
+		assert.Equal(t, STATUS_SUCCESS, status)
+	})
+
+	t.Run("it should disown the jobs", func(t *testing.T) {
+		output, status := runScript(t, "sleep 0.1 & disown; jobs; fg; wait %3 1")
+
+		assert.Equal(t, "cish: fg: current: no such job\ncish: wait: %3: no such job\n"+
+			"cish: wait: pid 1 is not a child of this shell\n", output)
+		assert.Equal(t, STATUS_NOT_FOUND, status)
+	})
+}
+
+func TestRunTraps(t *testing.T) {
+	t.Run("it should run the trap of a signal between the commands", func(t *testing.T) {
+		output, status := runScript(t, "trap 'echo caught $?' USR1; kill -USR1 $$; sleep 0.2; echo after; trap - USR1")
+
+		assert.Equal(t, "caught 0\nafter\n", output)
+		assert.Equal(t, STATUS_SUCCESS, status)
+	})
+
+	t.Run("it should make wait return when a trapped signal is received", func(t *testing.T) {
+		output, _ := runScript(t, "trap 'echo caught' USR1; sleep 1 & (sleep 0.1; kill -USR1 $$) & wait %1; echo $?; trap - USR1")
+
+		assert.Equal(t, "caught\n138\n", output)
+	})
+
+	t.Run("it should ignore the signal with an empty command", func(t *testing.T) {
+		output, status := runScript(t, "trap '' USR1; kill -USR1 $$; sleep 0.1; trap -p USR1; trap - USR1; trap -p USR1")
+
+		assert.Equal(t, "trap -- '' SIGUSR1\n", output)
+		assert.Equal(t, STATUS_SUCCESS, status)
+	})
+
+	t.Run("it should print the traps", func(t *testing.T) {
+		output, _ := runScript(t, "trap 'echo it'\\''s' ERR; trap : 2 15 EXIT; trap 'x' sigterm; trap; trap -p EXIT; trap INT; trap 0; trap")
+
+		assert.Equal(t, "trap -- ':' EXIT\ntrap -- ':' SIGINT\ntrap -- 'x' SIGTERM\ntrap -- 'echo it'\\''s' ERR\n"+
+			"trap -- ':' EXIT\ntrap -- 'x' SIGTERM\ntrap -- 'echo it'\\''s' ERR\n", output)
+	})
+
+	t.Run("it should reject the invalid signals", func(t *testing.T) {
+		output, status := runScript(t, "trap : FOO 99; trap -p BAR")
+
+		assert.Equal(t, "cish: trap: FOO: invalid signal specification\ncish: trap: 99: invalid signal specification\n"+
+			"cish: trap: BAR: invalid signal specification\n", output)
+		assert.Equal(t, STATUS_FAILURE, status)
+	})
+
+	t.Run("it should run the EXIT trap when a subshell exit", func(t *testing.T) {
+		output, status := runScript(t, "(trap 'echo bye $?' EXIT; echo hi; exit 3); echo $?; echo $(trap 'echo out' EXIT; echo in)")
+
+		assert.Equal(t, "hi\nbye 3\n3\nin out\n", output)
+		assert.Equal(t, STATUS_SUCCESS, status)
+	})
+
+	t.Run("it should change the exit status when the EXIT trap exit", func(t *testing.T) {
+		output, _ := runScript(t, "(trap 'exit 5' EXIT; true); echo $?")
+
+		assert.Equal(t, "5\n", output)
+	})
+
+	t.Run("it should reset the traps in the subshells, except the ignored ones", func(t *testing.T) {
+		output, _ := runScript(t, "trap 'echo parent' EXIT; trap '' USR2; (trap); trap - EXIT USR2")
+
+		assert.Equal(t, "trap -- '' SIGUSR2\n", output)
+	})
+
+	t.Run("it should reject the signal traps changed in the subshells", func(t *testing.T) {
+		output, status := runScript(t, "(trap 'echo x' TERM; trap '' INT; trap - INT; trap 'echo bye' EXIT); "+
+			"trap '' USR2; (trap '' USR2; trap - USR2; echo $?); trap - USR2")
+
+		assert.Equal(t, "cish: trap: SIGTERM: cannot be changed in a subshell\n"+
+			"cish: trap: SIGINT: cannot be changed in a subshell\nbye\n"+
+			"cish: trap: SIGUSR2: cannot be changed in a subshell\n1\n", output)
+		assert.Equal(t, STATUS_SUCCESS, status)
+	})
+
+	t.Run("it should run the ERR trap when a command fail", func(t *testing.T) {
+		output, status := runScript(t, "trap 'echo err $?' ERR; false; true && false; false || true; false && true\n"+
+			"! true; if false; then :; fi; while false; do :; done; { false; }; f() { false; }; f; trap - ERR; false")
+
+		assert.Equal(t, "err 1\nerr 1\nerr 1\nerr 1\n", output)
+		assert.Equal(t, STATUS_FAILURE, status)
+	})
+
+	t.Run("it should run the DEBUG trap before each simple command", func(t *testing.T) {
+		output, _ := runScript(t, "trap 'echo debug' DEBUG; echo a; f() { echo b; }; f; trap - DEBUG")
+
+		assert.Equal(t, "debug\na\ndebug\nb\ndebug\n", output)
+	})
+
+	t.Run("it should run the RETURN trap when a function return", func(t *testing.T) {
+		output, status := runScript(t, "trap 'echo returned $?' RETURN; f() { return 4; echo no; }; f; echo $?")
+
+		assert.Equal(t, "returned 4\n4\n", output)
+		assert.Equal(t, STATUS_SUCCESS, status)
+	})
+
+	t.Run("it should report the trap syntax errors", func(t *testing.T) {
+		output, _ := runScript(t, "trap 'if' ERR; false; trap - ERR; trap 'echo \"' USR1; kill -USR1 $$; sleep 0.1; "+
+			"trap - USR1; (trap 'echo )' EXIT)")
+
+		assert.Equal(t, "cish: syntax error at line 1, column 3: unexpected end of file\n"+
+			"cish: syntax error at line 1, column 6: unexpected end of file while looking for matching `\"'\n"+
+			"cish: syntax error at line 1, column 6: unexpected token `)'\n", output)
+	})
+}
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected cliOptions
+	}{
+		{nil, cliOptions{name: "cish", stdin: true}},
+		{[]string{"-s", "a", "b"}, cliOptions{name: "cish", stdin: true, params: []string{"a", "b"}}},
+		{[]string{"script.sh", "-c", "b"}, cliOptions{name: "script.sh", script: "script.sh", params: []string{"-c", "b"}}},
+		{[]string{"-c", "echo $0", "name", "a"}, cliOptions{name: "name", command: "echo $0", params: []string{"a"}}},
+		{[]string{"-c", "ls"}, cliOptions{name: "cish", command: "ls", params: []string{}}},
+		{[]string{"--", "-script"}, cliOptions{name: "-script", script: "-script", params: []string{}}},
+	}
+
+	for _, test := range tests {
+		opts, err := parseArgs(test.args)
+
+		require.NoError(t, err, test.args)
+		assert.Equal(t, test.expected, opts, test.args)
+	}
+
+	_, err := parseArgs([]string{"-x"})
+	assert.EqualError(t, err, "-x: invalid option")
+
+	_, err = parseArgs([]string{"-c"})
+	assert.EqualError(t, err, "-c: option requires an argument")
+}
+
+func TestRunSource(t *testing.T) {
+	t.Run("it should run the commands until the end of the source", func(t *testing.T) {
+		sh := newShell(-1, nil)
+		status := sh.runSource(strings.NewReader("a=1\nif true\nthen b=2\nfi\nc=x\\\ny\ntrap 'd=3' EXIT\nfalse"))
+
+		assert.Equal(t, STATUS_FAILURE, status)
+		for name, value := range map[string]string{"a": "1", "b": "2", "c": "xy", "d": "3"} {
+			got, _ := sh.vars.Get(name)
+			assert.Equal(t, value, got, name)
+		}
+	})
+
+	t.Run("it should stop at exit", func(t *testing.T) {
+		sh := newShell(-1, nil)
+		status := sh.runSource(strings.NewReader("a=1\nexit 3\nb=2\n"))
+
+		assert.Equal(t, 3, status)
+		_, ok := sh.vars.Get("b")
+		assert.False(t, ok)
+	})
+
+	t.Run("it should stop at a syntax error", func(t *testing.T) {
+		sh := newShell(-1, nil)
+		status := sh.runSource(strings.NewReader("a=1\nfi\nb=2\n"))
+
+		assert.Equal(t, STATUS_MISUSE, status)
+		_, ok := sh.vars.Get("b")
+		assert.False(t, ok)
+		value, _ := sh.vars.Get("a")
+		assert.Equal(t, "1", value)
+	})
+
+	t.Run("it should end the here-documents at the end of the source", func(t *testing.T) {
+		sh := newShell(-1, nil)
+		status := sh.runSource(strings.NewReader("a=1\nread b <<EOF\n2\n"))
+
+		assert.Equal(t, STATUS_SUCCESS, status)
+		value, _ := sh.vars.Get("b")
+		assert.Equal(t, "2", value)
+	})
+
+	t.Run("it should run the command string with its name and parameters", func(t *testing.T) {
+		sh := newShell(-1, nil)
+		sh.name, sh.params = "name", []string{"a", "b"}
+		status := sh.runCommandString("x=\"$0 $# $2\"; exit 4")
+
+		assert.Equal(t, 4, status)
+		value, _ := sh.vars.Get("x")
+		assert.Equal(t, "name 2 b", value)
+	})
+
+	t.Run("it should run the lines of the command string before a syntax error", func(t *testing.T) {
+		sh := newShell(-1, nil)
+		status := sh.runCommandString("a=1\nfi\n")
+
+		assert.Equal(t, STATUS_MISUSE, status)
+		value, _ := sh.vars.Get("a")
+		assert.Equal(t, "1", value)
+	})
+}
@@ -0,0 +1,766 @@
+package main
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Aboubakary833/cish/pattern"
+	"github.com/Aboubakary833/cish/scanner"
+)
+
+// segment is a piece of an expanded word
+type segment struct {
+	text string
+	// quoted is true when the text was quoted,
+	// so its special chars are taken literally
+	quoted bool
+	// split is true when the text is the result of an unquoted
+	// expansion, so it's split into fields on the IFS chars
+	split bool
+	// field is true for the break between two fields of
+	// the word, like between the parameters of "$@"
+	field bool
+}
+
+// DEFAULT_IFS is the value of IFS when it's not set
+const DEFAULT_IFS = " \t\n"
+
+// paramOperators are the operators of the parameter expansions,
+// sorted so that the longest ones are tried first.
+var paramOperators = []string{":-", ":=", ":?", ":+", "%%", "##", "-", "=", "?", "+", "%", "#"}
+
+// expand expand the parameters of the word and remove its quotes,
+// without splitting it into fields
+func (sh *Shell) expand(word string) (string, error) {
+	segments, err := sh.expandSegments(word, false, false)
+
+	return joinSegments(segments), err
+}
+
+// expandAssign expand the value of an assignment, where a tilde
+// is also expanded after each colon, like in PATH=~/bin:~/go/bin.
+func (sh *Shell) expandAssign(word string) (string, error) {
+	segments, err := sh.expandSegments(word, false, true)
+
+	return joinSegments(segments), err
+}
+
+// expandFields expand a command argument into fields: the results of
+// the unquoted expansions are split on the IFS chars, then each field
+// is replaced by the paths matched by its unquoted special chars.
+func (sh *Shell) expandFields(word string) ([]string, error) {
+	segments, err := sh.expandSegments(word, false, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []string
+
+	for _, field := range sh.split(segments) {
+		paths, err := sh.glob(field)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, paths...)
+	}
+
+	return fields, nil
+}
+
+// ifs return the field separators
+func (sh *Shell) ifs() string {
+	if ifs, set := sh.vars.Get("IFS"); set {
+		return ifs
+	}
+
+	return DEFAULT_IFS
+}
+
+// split split the segments into fields. The IFS whitespaces around a
+// field are ignored, while each other IFS char delimit a field, which
+// can be empty. A quoted empty text is a field of its own.
+func (sh *Shell) split(segments []segment) [][]segment {
+	ifs := sh.ifs()
+	var fields [][]segment
+	var current []segment
+	// started is true when the current field exists, even empty
+	started := false
+	// delimited is true after a field ended with IFS whitespaces,
+	// which are a single delimiter with the next IFS char
+	delimited := false
+
+	end := func() {
+		fields = append(fields, current)
+		current, started = nil, false
+	}
+
+	for _, seg := range segments {
+		switch {
+		case seg.field:
+			if started {
+				end()
+			}
+			delimited = false
+
+		case !seg.split || ifs == "":
+			current = appendSegment(current, seg.text, seg.quoted)
+			started = started || seg.quoted || seg.text != ""
+			delimited = delimited && !started
+
+		default:
+			for i := 0; i < len(seg.text); i++ {
+				char := seg.text[i]
+
+				switch {
+				case strings.IndexByte(ifs, char) < 0:
+					current = appendSegment(current, seg.text[i:i+1], false)
+					started, delimited = true, false
+
+				case strings.IndexByte(DEFAULT_IFS, char) >= 0:
+					if started {
+						end()
+						delimited = true
+					}
+
+				default:
+					if started || !delimited {
+						end()
+					}
+					delimited = false
+				}
+			}
+		}
+	}
+
+	if started {
+		end()
+	}
+
+	return fields
+}
+
+// glob run the pathname expansion of an expanded word.
+// Without match the word is kept, unless the nullglob
+// or failglob option is set.
+func (sh *Shell) glob(segments []segment) ([]string, error) {
+	text := joinSegments(segments)
+	pat := patternOf(segments)
+
+	if sh.options[OPTION_NOGLOB] || !pattern.HasMeta(pat) {
+		return []string{text}, nil
+	}
+
+	if paths := pattern.GlobIn(sh.dir, pat); len(paths) != 0 {
+		return paths, nil
+	}
+
+	if sh.options[OPTION_FAILGLOB] {
+		return nil, fmt.Errorf("no match: %s", text)
+	}
+
+	if sh.options[OPTION_NULLGLOB] {
+		return nil, nil
+	}
+
+	return []string{text}, nil
+}
+
+// expandPattern expand a word used as a pattern.
+// The quoted chars are escaped so they match themselves.
+func (sh *Shell) expandPattern(word string) (string, error) {
+	segments, err := sh.expandSegments(word, false, false)
+
+	return patternOf(segments), err
+}
+
+// patternOf join the segments, escaping the quoted ones
+// so their special chars match themselves.
+func patternOf(segments []segment) string {
+	var builder strings.Builder
+
+	for _, segment := range segments {
+		if segment.quoted {
+			builder.WriteString(pattern.Escape(segment.text))
+		} else {
+			builder.WriteString(segment.text)
+		}
+	}
+
+	return builder.String()
+}
+
+func joinSegments(segments []segment) string {
+	var builder strings.Builder
+
+	for _, segment := range segments {
+		if segment.field {
+			builder.WriteByte(' ')
+		}
+		builder.WriteString(segment.text)
+	}
+
+	return builder.String()
+}
+
+// appendSegment add a literal text, merging it with the
+// last segment when they are both quoted or unquoted.
+func appendSegment(segments []segment, text string, quoted bool) []segment {
+	return appendExpanded(segments, segment{text: text, quoted: quoted})
+}
+
+// appendExpanded add the segment of an expansion, merging
+// it with the last one when they are of the same kind.
+func appendExpanded(segments []segment, seg segment) []segment {
+	if n := len(segments); n != 0 && !seg.field && !segments[n-1].field &&
+		segments[n-1].quoted == seg.quoted && segments[n-1].split == seg.split {
+		segments[n-1].text += seg.text
+		return segments
+	}
+
+	return append(segments, seg)
+}
+
+// valueSegments return the segments of an expanded value,
+// which is split into fields unless it's quoted
+func valueSegments(value string, quoted bool) []segment {
+	return []segment{{text: value, quoted: quoted, split: !quoted}}
+}
+
+// expandSegments expand the parameters of the word and remove its quotes.
+// When quoted is true the word is inside double quotes. The tildes
+// starting the word are expanded, and when assign is true the ones
+// following a colon too.
+func (sh *Shell) expandSegments(word string, quoted, assign bool) ([]segment, error) {
+	var segments []segment
+
+	for i := 0; i < len(word); {
+		char := word[i]
+
+		switch {
+		case char == '~' && !quoted && (i == 0 || (assign && word[i-1] == ':')):
+			home, n := sh.expandTilde(word[i:], assign)
+			if n == 0 {
+				segments = appendSegment(segments, "~", false)
+				i++
+				continue
+			}
+
+			segments = appendSegment(segments, home, true)
+			i += n
+
+		case char == '\'' && !quoted:
+			end := strings.IndexByte(word[i+1:], '\'')
+			if end < 0 {
+				end = len(word) - i - 1
+			}
+			segments = appendSegment(segments, word[i+1:i+1+end], true)
+			i += end + 2
+
+		// inside double quotes, the double quotes of the words of the
+		// parameter expansions and of the arithmetic expressions are
+		// only removed
+		case char == '"' && quoted:
+			i++
+
+		case char == '"':
+			end := scanner.QuotedEnd(word, i)
+			if end < 0 {
+				end = len(word) + 1
+			}
+
+			inner, err := sh.expandSegments(word[i+1:end-1], true, false)
+			if err != nil {
+				return nil, err
+			}
+
+			// an empty quoted string is still a word,
+			// unless it's "$@" without parameters
+			if len(inner) != 0 || !isAllParams(word[i+1:end-1]) {
+				segments = appendSegment(segments, "", true)
+			}
+			for _, segment := range inner {
+				segments = appendExpanded(segments, segment)
+			}
+			i = end
+
+		case char == '\\':
+			if i+1 == len(word) || (quoted && strings.IndexByte("$`\"\\\n", word[i+1]) < 0) {
+				segments = appendSegment(segments, "\\", true)
+				i++
+				continue
+			}
+
+			if word[i+1] != KeyNewLine {
+				segments = appendSegment(segments, word[i+1:i+2], true)
+			}
+			i += 2
+
+		case char == '$' || char == '`':
+			expanded, n, err := sh.expandDollar(word[i:], quoted)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, segment := range expanded {
+				segments = appendExpanded(segments, segment)
+			}
+			i += n
+
+		default:
+			segments = appendSegment(segments, word[i:i+1], quoted)
+			i++
+		}
+	}
+
+	return segments, nil
+}
+
+// expandDollar expand the parameter, the arithmetic expansion or the
+// command substitution starting at text[0], which is a `$` or a backquote.
+// It also return the length of the expanded text.
+func (sh *Shell) expandDollar(text string, quoted bool) ([]segment, int, error) {
+	if strings.HasPrefix(text, "$((") {
+		if end := scanner.ArithmeticEnd(text, 1); end > 0 {
+			return sh.expandArithmetic(text, end, quoted)
+		}
+	}
+
+	if text[0] == '`' || strings.HasPrefix(text, "$(") {
+		end := scanner.ExpansionEnd(text, 0)
+		if end < 0 {
+			return nil, 0, fmt.Errorf("%s: unterminated command substitution", text)
+		}
+
+		src := text[2 : end-1]
+		if text[0] == '`' {
+			src = unescapeBackquoted(text[1:end-1], quoted)
+		}
+
+		output, err := sh.substitute(src)
+		return valueSegments(output, quoted), end, err
+	}
+
+	if len(text) < 2 {
+		return []segment{{text: "$", quoted: quoted}}, 1, nil
+	}
+
+	switch next := text[1]; {
+	case next == '{':
+		end := scanner.ExpansionEnd(text, 0)
+		if end < 0 {
+			return nil, 0, fmt.Errorf("%s: bad substitution", text)
+		}
+
+		segments, err := sh.expandBraced(text[2:end-1], quoted)
+		return segments, end, err
+
+	case isNameStart(next):
+		n := 1
+		for n < len(text) && isNameChar(text[n]) {
+			n++
+		}
+
+		segments, err := sh.paramSegments(text[1:n], quoted)
+		return segments, n, err
+
+	case next >= '0' && next <= '9', strings.IndexByte(SPECIAL_PARAMS, next) >= 0:
+		segments, err := sh.paramSegments(text[1:2], quoted)
+		return segments, 2, err
+	}
+
+	return []segment{{text: "$", quoted: quoted}}, 1, nil
+}
+
+// expandBraced expand the content of a `${...}` expansion
+func (sh *Shell) expandBraced(content string, quoted bool) ([]segment, error) {
+	badSubstitution := fmt.Errorf("${%s}: bad substitution", content)
+
+	// ${#name} is the length of the value,
+	// and ${#name[@]} the number of elements
+	if len(content) > 1 && content[0] == '#' && isParam(content[1:]) {
+		value, set := sh.param(content[1:])
+		if err := sh.checkSet(content[1:], set); err != nil {
+			return nil, err
+		}
+		return valueSegments(strconv.Itoa(utf8.RuneCountInString(value)), quoted), nil
+	}
+
+	if name, subscript, ok := arrayElement(strings.TrimPrefix(content, "#")); strings.HasPrefix(content, "#") && ok {
+		if subscript == "@" || subscript == "*" {
+			values, _ := sh.vars.Array(name)
+			return valueSegments(strconv.Itoa(len(values)), quoted), nil
+		}
+
+		value, _, err := sh.element(name, subscript)
+		return valueSegments(strconv.Itoa(utf8.RuneCountInString(value)), quoted), err
+	}
+
+	name := paramName(content)
+	if name == "" {
+		return nil, badSubstitution
+	}
+
+	value, set := sh.param(name)
+	rest := content[len(name):]
+
+	// ${name[@]} are the elements of an array, like "$@"
+	// are the positional parameters, and ${name[n]} one of them
+	if strings.HasPrefix(rest, "[") {
+		arrayName, subscript, ok := arrayElement(content[:strings.IndexByte(content, ']')+1])
+		if !ok || arrayName != name {
+			return nil, badSubstitution
+		}
+		rest = content[len(name)+len(subscript)+2:]
+
+		if subscript == "@" || subscript == "*" {
+			if rest != "" {
+				return nil, badSubstitution
+			}
+			values, _ := sh.vars.Array(name)
+			return sh.listSegments(values, subscript == "*", quoted), nil
+		}
+
+		var err error
+		if value, set, err = sh.element(name, subscript); err != nil {
+			return nil, err
+		}
+
+		if rest == "" {
+			return valueSegments(value, quoted), sh.checkSet(name, set)
+		}
+	}
+
+	if rest == "" {
+		return sh.paramSegments(name, quoted)
+	}
+
+	op := ""
+	for _, operator := range paramOperators {
+		if strings.HasPrefix(rest, operator) {
+			op = operator
+			break
+		}
+	}
+
+	if op == "" {
+		return nil, badSubstitution
+	}
+
+	word := rest[len(op):]
+	// with a colon, a null value is handled as an unset one
+	useWord := !set || (op[0] == ':' && value == "")
+
+	switch strings.TrimPrefix(op, ":") {
+	case "-":
+		if useWord {
+			return sh.expandAlternative(word, quoted)
+		}
+
+	case "=":
+		if useWord {
+			if !scanner.IsName(name) {
+				return nil, fmt.Errorf("$%s: cannot assign in this way", name)
+			}
+
+			segments, err := sh.expandSegments(word, quoted, false)
+			if err != nil {
+				return nil, err
+			}
+
+			value = joinSegments(segments)
+			if err := sh.vars.Set(name, value); err != nil {
+				return nil, &fatalError{err, STATUS_FAILURE}
+			}
+		}
+
+	case "?":
+		if useWord {
+			message, err := sh.expand(word)
+			if err != nil {
+				return nil, err
+			}
+
+			if message == "" {
+				message = "parameter null or not set"
+			}
+			return nil, &fatalError{fmt.Errorf("%s: %s", name, message), STATUS_NOT_FOUND}
+		}
+
+	case "+":
+		if useWord {
+			return nil, nil
+		}
+		return sh.expandAlternative(word, quoted)
+
+	case "%", "%%", "#", "##":
+		pat, err := sh.expandPattern(word)
+		if err != nil {
+			return nil, err
+		}
+
+		value = removePattern(value, pat, op)
+	}
+
+	return valueSegments(value, quoted), nil
+}
+
+// expandAlternative expand the word of a `${name-word}` or
+// `${name+word}` expansion, which is split into fields when the
+// expansion is unquoted, except for the quoted parts of the word.
+func (sh *Shell) expandAlternative(word string, quoted bool) ([]segment, error) {
+	segments, err := sh.expandSegments(word, quoted, false)
+
+	for i := range segments {
+		segments[i].split = !segments[i].quoted
+	}
+
+	return segments, err
+}
+
+// paramSegments return the segments of a parameter,
+// which are the positional parameters for `$@` and `$*`.
+func (sh *Shell) paramSegments(name string, quoted bool) ([]segment, error) {
+	if name != "@" && name != "*" {
+		value, set := sh.param(name)
+		return valueSegments(value, quoted), sh.checkSet(name, set)
+	}
+
+	return sh.listSegments(sh.params, name == "*", quoted), nil
+}
+
+// checkSet return the fatal error of an unset parameter
+// expanded without default value when nounset is set
+func (sh *Shell) checkSet(name string, set bool) error {
+	if set || !sh.options[OPTION_NOUNSET] {
+		return nil
+	}
+
+	return &fatalError{fmt.Errorf("%s: unbound variable", name), STATUS_NOT_FOUND}
+}
+
+// listSegments return the segments of a list of values. Each value
+// is a field of "$@", while they are joined with the first IFS char
+// in "$*", when star is true. Unquoted, both are a field per
+// value, split.
+func (sh *Shell) listSegments(values []string, star, quoted bool) []segment {
+	if quoted && star {
+		separator := ""
+		if ifs := sh.ifs(); ifs != "" {
+			separator = ifs[:1]
+		}
+		return valueSegments(strings.Join(values, separator), quoted)
+	}
+
+	var segments []segment
+	for i, param := range values {
+		if i != 0 {
+			segments = append(segments, segment{field: true})
+		}
+		segments = append(segments, valueSegments(param, quoted)...)
+	}
+
+	return segments
+}
+
+// element return an element of an array and whether it's set.
+// The subscript is an arithmetic expression, and a negative
+// index count from the end of the array.
+func (sh *Shell) element(name, subscript string) (string, bool, error) {
+	index, err := sh.arithmetic(subscript)
+	if err != nil {
+		return "", false, err
+	}
+
+	values, _ := sh.vars.Array(name)
+	if index < 0 {
+		index += int64(len(values))
+	}
+
+	if index < 0 || index >= int64(len(values)) {
+		return "", false, nil
+	}
+
+	return values[index], true, nil
+}
+
+// arrayElement split a `name[subscript]` text into
+// its name and subscript, or report false
+func arrayElement(text string) (string, string, bool) {
+	name, subscript, found := strings.Cut(text, "[")
+
+	if !found || !scanner.IsName(name) || !strings.HasSuffix(subscript, "]") || len(subscript) < 2 {
+		return "", "", false
+	}
+
+	return name, subscript[:len(subscript)-1], true
+}
+
+// isAllParams report whether the text is only `$@` or `${@}`
+func isAllParams(text string) bool {
+	return text == "$@" || text == "${@}"
+}
+
+// expandTilde expand the tilde prefix at the start of the text, up to
+// the first slash, or colon in an assignment, to the home directory of
+// the user it name, or of the current user when it's empty, or to
+// an entry of the directory stack.
+// It also return the length of the prefix, which is 0 if the
+// tilde is not expanded.
+func (sh *Shell) expandTilde(text string, assign bool) (string, int) {
+	n := 1
+	for n < len(text) && text[n] != '/' && !(assign && text[n] == ':') {
+		n++
+	}
+
+	switch prefix := text[1:n]; prefix {
+	case "":
+		if home, set := sh.vars.Get("HOME"); set {
+			return home, n
+		}
+		if current, err := user.Current(); err == nil {
+			return current.HomeDir, n
+		}
+
+	case "+", "-":
+		name := map[string]string{"+": "PWD", "-": "OLDPWD"}[prefix]
+		if dir, set := sh.vars.Get(name); set {
+			return dir, n
+		}
+
+	default:
+		// ~N, ~+N and ~-N are the directory stack entries
+		if dir, ok := sh.dirEntry(prefix); ok {
+			return dir, n
+		}
+
+		if !scanner.IsName(strings.ReplaceAll(prefix, ".", "_")) {
+			return "", 0
+		}
+		if account, err := user.Lookup(prefix); err == nil {
+			return account.HomeDir, n
+		}
+	}
+
+	return "", 0
+}
+
+// removePattern remove the smallest (% and #) or largest (%% and ##)
+// suffix (% and %%) or prefix (# and ##) matching the pattern.
+// The value is only cut between its UTF-8 chars.
+func removePattern(value, pat, op string) string {
+	// cut report whether the value can be cut at i
+	cut := func(i int) bool {
+		return i == len(value) || utf8.RuneStart(value[i])
+	}
+
+	switch op {
+	case "%":
+		for i := len(value); i >= 0; i-- {
+			if cut(i) && pattern.Match(pat, value[i:]) {
+				return value[:i]
+			}
+		}
+
+	case "%%":
+		for i := 0; i <= len(value); i++ {
+			if cut(i) && pattern.Match(pat, value[i:]) {
+				return value[:i]
+			}
+		}
+
+	case "#":
+		for i := 0; i <= len(value); i++ {
+			if cut(i) && pattern.Match(pat, value[:i]) {
+				return value[i:]
+			}
+		}
+
+	case "##":
+		for i := len(value); i >= 0; i-- {
+			if cut(i) && pattern.Match(pat, value[:i]) {
+				return value[i:]
+			}
+		}
+	}
+
+	return value
+}
+
+// paramName return the parameter name at the start of
+// the content of a `${...}` expansion.
+func paramName(content string) string {
+	switch {
+	case content == "":
+		return ""
+
+	case isNameStart(content[0]):
+		n := 1
+		for n < len(content) && isNameChar(content[n]) {
+			n++
+		}
+		return content[:n]
+
+	case content[0] >= '0' && content[0] <= '9':
+		n := 1
+		for n < len(content) && content[n] >= '0' && content[n] <= '9' {
+			n++
+		}
+		return content[:n]
+
+	case strings.IndexByte(SPECIAL_PARAMS, content[0]) >= 0:
+		return content[:1]
+	}
+
+	return ""
+}
+
+// isParam report whether text is a parameter name
+func isParam(text string) bool {
+	return text != "" && paramName(text) == text
+}
+
+func isNameStart(char byte) bool {
+	return char == '_' || (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z')
+}
+
+func isNameChar(char byte) bool {
+	return isNameStart(char) || (char >= '0' && char <= '9')
+}
+
+// expandHeredoc expand the body of a here-document whose delimiter
+// is not quoted. Quotes are not special there, and a backslash
+// only escape the dollar sign, the backquote, the backslash and
+// the newline.
+func (sh *Shell) expandHeredoc(body string) (string, error) {
+	var builder strings.Builder
+
+	for i := 0; i < len(body); i++ {
+		char := body[i]
+
+		switch {
+		case char == '\\' && i+1 < len(body) && strings.IndexByte("$`\\\n", body[i+1]) >= 0:
+			i++
+			if body[i] != KeyNewLine {
+				builder.WriteByte(body[i])
+			}
+			continue
+
+		case char == '$' || char == '`':
+			segments, n, err := sh.expandDollar(body[i:], true)
+			if err != nil {
+				return "", err
+			}
+
+			builder.WriteString(joinSegments(segments))
+			i += n - 1
+			continue
+		}
+
+		builder.WriteByte(char)
+	}
+
+	return builder.String(), nil
+}
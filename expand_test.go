@@ -0,0 +1,166 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpand(t *testing.T) {
+	sh := newShell(-1, nil)
+	sh.status.Set(42)
+	sh.params = []string{"one", "two"}
+	sh.vars.Set("x", "hello world")
+	sh.vars.Set("path", "/usr/local/lib/libcish.so.1")
+	sh.vars.Set("empty", "")
+	sh.vars.Set("accented", "été")
+
+	tests := []struct {
+		word     string
+		expanded string
+	}{
+		{"$?", "42"},
+		{"\"status: $?\"", "status: 42"},
+		{"'$?'", "$?"},
+		{"\\$?", "$?"},
+		{"a\\ b", "a b"},
+		{"\"a\\b\\$\"", "a\\b$"},
+		{"$x", "hello world"},
+		{"${x}!", "hello world!"},
+		{"$x_", ""},
+		{"$1-$2-$3-$#", "one-two--2"},
+		{"$0", "cish"},
+		{"$", "$"},
+		{"a$", "a$"},
+		{"${#x}", "11"},
+		{"${#}", "2"},
+		{"${unset:-default}", "default"},
+		{"${empty:-default}", "default"},
+		{"${empty-default}", ""},
+		{"${x:-default}", "hello world"},
+		{"${x:+alt}", "alt"},
+		{"${empty:+alt}", ""},
+		{"${empty+alt}", "alt"},
+		{"${unset+alt}", ""},
+		{"${unset:-'$x'}", "$x"},
+		{"${unset:-$x}", "hello world"},
+		{"${path%.*}", "/usr/local/lib/libcish.so"},
+		{"${path%%.*}", "/usr/local/lib/libcish"},
+		{"${path#*/}", "usr/local/lib/libcish.so.1"},
+		{"${path##*/}", "libcish.so.1"},
+		{"${path%'.*'}", "/usr/local/lib/libcish.so.1"},
+		{"${x#\"hello \"}", "world"},
+		{"${accented%?}", "ét"},
+		{"${accented#?}", "té"},
+		{"${accented%%t*}", "é"},
+		{"${accented##*t}", "é"},
+	}
+
+	for _, test := range tests {
+		expanded, err := sh.expand(test.word)
+
+		require.NoError(t, err, test.word)
+		assert.Equal(t, test.expanded, expanded, test.word)
+	}
+}
+
+func TestExpandAssign(t *testing.T) {
+	sh := newShell(-1, nil)
+
+	expanded, err := sh.expand("${name:=cish}")
+	require.NoError(t, err)
+	assert.Equal(t, "cish", expanded)
+
+	value, _ := sh.vars.Get("name")
+	assert.Equal(t, "cish", value)
+
+	_, err = sh.expand("${1:=cish}")
+	assert.EqualError(t, err, "$1: cannot assign in this way")
+}
+
+func TestExpandErrors(t *testing.T) {
+	sh := newShell(-1, nil)
+
+	_, err := sh.expand("${unset:?}")
+	assert.EqualError(t, err, "unset: parameter null or not set")
+
+	_, err = sh.expand("${unset?is required}")
+	assert.EqualError(t, err, "unset: is required")
+
+	_, err = sh.expand("${x!y}")
+	assert.EqualError(t, err, "${x!y}: bad substitution")
+}
+
+func TestExpandHeredoc(t *testing.T) {
+	sh := newShell(-1, nil)
+	sh.vars.Set("user", "cish")
+
+	body, err := sh.expandHeredoc("'$user' \"${user}\" \\$user \\n\\\nend\n")
+
+	require.NoError(t, err)
+	assert.Equal(t, "'cish' \"cish\" $user \\nend\n", body)
+}
+
+func TestExpandFields(t *testing.T) {
+	sh := newShell(-1, nil)
+	sh.params = []string{"a b", "", "c"}
+	sh.vars.Set("HOME", "/home/cish")
+	sh.vars.Set("x", "  one  two\tthree\n")
+	sh.vars.Set("csv", "a,b,,c,")
+	sh.vars.Set("empty", "")
+
+	tests := []struct {
+		word   string
+		ifs    string
+		fields []string
+	}{
+		{"$x", "", []string{"one", "two", "three"}},
+		{"\"$x\"", "", []string{"  one  two\tthree\n"}},
+		{"a$x'b'", "", []string{"a", "one", "two", "three", "b"}},
+		{"a${csv}b", ",", []string{"aa", "b", "", "c", "b"}},
+		{"$empty", "", nil},
+		{"\"$empty\"", "", []string{""}},
+		{"''", "", []string{""}},
+		{"\"$@\"", "", []string{"a b", "", "c"}},
+		{"x\"$@\"y", "", []string{"xa b", "", "cy"}},
+		{"$@", "", []string{"a", "b", "c"}},
+		{"\"$*\"", "", []string{"a b  c"}},
+		{"$csv", ",", []string{"a", "b", "", "c"}},
+		{"\"$*\"", ",", []string{"a b,,c"}},
+		{"$x", " ,", []string{"one", "two\tthree\n"}},
+		{"${empty:-a b}", "", []string{"a", "b"}},
+		{"${empty:-\"a b\"}", "", []string{"a b"}},
+		{"$(echo 'a  b')", "", []string{"a", "b"}},
+		{"~/bin", "", []string{"/home/cish/bin"}},
+		{"'~'/bin", "", []string{"~/bin"}},
+		{"a~", "", []string{"a~"}},
+	}
+
+	for _, test := range tests {
+		sh.vars.Unset("IFS")
+		if test.ifs != "" {
+			sh.vars.Set("IFS", test.ifs)
+		}
+
+		fields, err := sh.expandFields(test.word)
+
+		require.NoError(t, err, test.word)
+		assert.Equal(t, test.fields, fields, "%s with IFS=%q", test.word, test.ifs)
+	}
+
+	t.Run("it should expand \"$@\" to nothing without parameters", func(t *testing.T) {
+		sh.params = nil
+		fields, err := sh.expandFields("\"$@\"")
+
+		require.NoError(t, err)
+		assert.Empty(t, fields)
+	})
+
+	t.Run("it should expand the tildes after the colons of assignments", func(t *testing.T) {
+		value, err := sh.expandAssign("~/bin:~/go/bin:a~")
+
+		require.NoError(t, err)
+		assert.Equal(t, "/home/cish/bin:/home/cish/go/bin:a~", value)
+	})
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Aboubakary833/cish/parser"
+	"github.com/Aboubakary833/cish/scanner"
+)
+
+// DEFAULT_FUNCNEST is the maximum nesting level of
+// the function calls when FUNCNEST is not set
+const DEFAULT_FUNCNEST = 1000
+
+// callFunction run the function with args as positional parameters,
+// in a new scope for its local variables. Exceeding FUNCNEST is a
+// fatal error, leaving a non-interactive shell.
+func (sh *Shell) callFunction(fn *parser.FunctionDefinition, args []string, io streams) {
+	if limit := sh.funcNest(); sh.funcDepth >= limit {
+		err := fmt.Errorf("%s: maximum function nesting level exceeded (%d)", fn.Name, limit)
+		sh.fail(io, &fatalError{err, STATUS_FAILURE}, STATUS_FAILURE)
+		return
+	}
+
+	params, loops := sh.params, sh.loops
+	sh.params, sh.loops = args[1:], 0
+	sh.funcDepth++
+	sh.vars.push()
+
+	defer func() {
+		sh.vars.pop()
+		sh.funcDepth--
+		sh.params, sh.loops = params, loops
+		sh.returning = false
+	}()
+
+	sh.runCommand(fn.Body, io)
+
+	if !sh.exiting {
+		sh.returning = false
+		sh.runPseudoTrap("RETURN", io)
+	}
+}
+
+// funcNest return the maximum nesting level of the function calls
+func (sh *Shell) funcNest() int {
+	value, _ := sh.vars.Get("FUNCNEST")
+
+	if limit, err := strconv.Atoi(value); err == nil && limit > 0 {
+		return limit
+	}
+
+	return DEFAULT_FUNCNEST
+}
+
+// local declare the variables in the scope of the running function
+func (sh *Shell) local(args []string, io streams) int {
+	if sh.funcDepth == 0 {
+		printError(io, fmt.Errorf("local: can only be used in a function"))
+		return STATUS_FAILURE
+	}
+
+	return sh.declareLocal("local", args[1:], io, func(*Variable) {})
+}
+
+// declareLocal declare the variables named by the arguments
+// in the current scope, then assign and mark them.
+func (sh *Shell) declareLocal(builtin string, args []string, io streams, mark func(*Variable)) int {
+	for _, arg := range args {
+		if name, _, _ := strings.Cut(arg, "="); scanner.IsName(name) {
+			sh.vars.Local(name)
+		}
+	}
+
+	return sh.declareVariables(builtin, args, io, mark)
+}
+
+// returnBuiltin leave the running function with the status n,
+// or with the status of the last command.
+func (sh *Shell) returnBuiltin(args []string, io streams) int {
+	if sh.funcDepth == 0 {
+		printError(io, fmt.Errorf("return: can only `return' from a function"))
+		return STATUS_FAILURE
+	}
+
+	status := sh.status.Code()
+
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			printError(io, fmt.Errorf("return: %s: numeric argument required", args[1]))
+			return STATUS_MISUSE
+		}
+		status = n & 0xff
+	}
+
+	sh.returning = true
+
+	return status
+}
+
+// declare print the functions with `-f`, or their names with `-F`.
+// Otherwise, it declare the variables like `local` in a function,
+// exporting them with `-x` and making them readonly with `-r`.
+func (sh *Shell) declare(args []string, io streams) int {
+	flags, names, err := parseFlags(args, "fFprx")
+	if err != nil {
+		printError(io, fmt.Errorf("declare: %s", err.Error()))
+		return STATUS_MISUSE
+	}
+
+	if flags['f'] || flags['F'] {
+		return sh.printFunctions(names, flags['F'], io)
+	}
+
+	if len(names) == 0 || flags['p'] {
+		sh.printVariables("declare", func(v *Variable) bool {
+			return (!flags['x'] || v.Exported) && (!flags['r'] || v.ReadOnly)
+		}, io)
+		return STATUS_SUCCESS
+	}
+
+	mark := func(variable *Variable) {
+		variable.Exported = variable.Exported || flags['x']
+		variable.ReadOnly = variable.ReadOnly || flags['r']
+	}
+
+	if sh.funcDepth != 0 {
+		return sh.declareLocal("declare", names, io, mark)
+	}
+
+	return sh.declareVariables("declare", names, io, mark)
+}
+
+// printFunctions print the definitions of the functions,
+// or only their names, sorted. Without names, all the
+// functions are printed.
+func (sh *Shell) printFunctions(names []string, onlyNames bool, io streams) int {
+	status := STATUS_SUCCESS
+
+	if len(names) == 0 {
+		for name := range sh.funcs {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+	}
+
+	for _, name := range names {
+		fn, ok := sh.funcs[name]
+
+		switch {
+		case !ok:
+			status = STATUS_FAILURE
+		case onlyNames:
+			fmt.Fprintf(io.stdout, "declare -f %s\n", name)
+		default:
+			fmt.Fprintln(io.stdout, parser.Format(fn))
+		}
+	}
+
+	return status
+}
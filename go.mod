@@ -2,11 +2,14 @@ module github.com/Aboubakary833/cish
 
 go 1.23.0
 
+require (
+	github.com/stretchr/testify v1.9.0
+	golang.org/x/sys v0.26.0
+	golang.org/x/term v0.25.0
+)
+
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/stretchr/testify v1.9.0 // indirect
-	golang.org/x/sys v0.26.0 // indirect
-	golang.org/x/term v0.25.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Runes which join the grapheme cluster they follow
+const (
+	ZERO_WIDTH_JOINER = '\u200d'
+	// EMOJI_PRESENTATION select the emoji presentation of the previous
+	// rune, which is then shown on two columns
+	EMOJI_PRESENTATION = '\ufe0f'
+)
+
+// wideRanges are the East Asian Wide and Fullwidth ranges, and the emoji
+// blocks, whose chars are shown on two columns by the terminals
+var wideRanges = [][2]rune{
+	{0x1100, 0x115f},   // Hangul Jamo initial consonants
+	{0x231a, 0x231b},   // watch, hourglass
+	{0x23e9, 0x23ec},   // media controls
+	{0x23f0, 0x23f0},   // alarm clock
+	{0x23f3, 0x23f3},   // hourglass
+	{0x25fd, 0x25fe},   // small squares
+	{0x2614, 0x2615},   // umbrella, hot beverage
+	{0x2648, 0x2653},   // zodiac
+	{0x267f, 0x267f},   // wheelchair
+	{0x2693, 0x2693},   // anchor
+	{0x26a1, 0x26a1},   // high voltage
+	{0x26aa, 0x26ab},   // circles
+	{0x26bd, 0x26be},   // balls
+	{0x26c4, 0x26c5},   // snowman, sun
+	{0x26ce, 0x26ce},   // ophiuchus
+	{0x26d4, 0x26d4},   // no entry
+	{0x26ea, 0x26ea},   // church
+	{0x26f2, 0x26f5},   // fountain, golf, sailboat
+	{0x26fa, 0x26fa},   // tent
+	{0x26fd, 0x26fd},   // fuel pump
+	{0x2705, 0x2705},   // check mark
+	{0x270a, 0x270b},   // fists
+	{0x2728, 0x2728},   // sparkles
+	{0x274c, 0x274c},   // cross mark
+	{0x274e, 0x274e},   // cross mark
+	{0x2753, 0x2755},   // question marks
+	{0x2757, 0x2757},   // exclamation mark
+	{0x2795, 0x2797},   // heavy plus, minus, division
+	{0x27b0, 0x27b0},   // curly loop
+	{0x27bf, 0x27bf},   // double curly loop
+	{0x2b1b, 0x2b1c},   // large squares
+	{0x2b50, 0x2b50},   // star
+	{0x2b55, 0x2b55},   // circle
+	{0x2e80, 0x303e},   // CJK radicals, symbols and punctuation
+	{0x3041, 0x33ff},   // Hiragana, Katakana, CJK compatibility
+	{0x3400, 0x4dbf},   // CJK extension A
+	{0x4e00, 0x9fff},   // CJK unified ideographs
+	{0xa000, 0xa4cf},   // Yi
+	{0xa960, 0xa97f},   // Hangul Jamo extended A
+	{0xac00, 0xd7a3},   // Hangul syllables
+	{0xf900, 0xfaff},   // CJK compatibility ideographs
+	{0xfe10, 0xfe19},   // vertical forms
+	{0xfe30, 0xfe6f},   // CJK compatibility forms, small forms
+	{0xff00, 0xff60},   // fullwidth forms
+	{0xffe0, 0xffe6},   // fullwidth signs
+	{0x16fe0, 0x18cff}, // Tangut, Khitan
+	{0x1b000, 0x1b2ff}, // Kana supplement and extensions, Nushu
+	{0x1f004, 0x1f004}, // mahjong tile
+	{0x1f0cf, 0x1f0cf}, // playing card
+	{0x1f18e, 0x1f18e}, // AB button
+	{0x1f191, 0x1f19a}, // squared words
+	{0x1f200, 0x1f251}, // enclosed ideographic supplement
+	{0x1f300, 0x1f64f}, // pictographs, emoticons
+	{0x1f680, 0x1f6ff}, // transport and map symbols
+	{0x1f7e0, 0x1f7eb}, // colored circles and squares
+	{0x1f90c, 0x1f9ff}, // supplemental symbols and pictographs
+	{0x1fa70, 0x1faff}, // symbols and pictographs extended A
+	{0x20000, 0x2fffd}, // CJK extensions B to F
+	{0x30000, 0x3fffd}, // CJK extensions G and H
+}
+
+// isRegionalIndicator report whether the rune is one of the letters
+// which make a flag when two of them follow each other
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
+
+// isExtending report whether the rune extend the grapheme cluster it
+// follows: the combining marks, the joiners, the variation selectors,
+// the emoji skin tones and the tags.
+func isExtending(r rune) bool {
+	switch {
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc):
+		return true
+	case r == ZERO_WIDTH_JOINER, r == '\u200c':
+		return true
+	case r >= 0xfe00 && r <= 0xfe0f, r >= 0xe0100 && r <= 0xe01ef:
+		return true
+	case r >= 0x1f3fb && r <= 0x1f3ff, r >= 0xe0020 && r <= 0xe007f:
+		return true
+	}
+
+	return false
+}
+
+// runeWidth return the number of columns the rune is shown on
+func runeWidth(r rune) int {
+	switch {
+	case r == '\t':
+		return 1
+	case unicode.IsControl(r), isExtending(r), unicode.Is(unicode.Cf, r):
+		return 0
+	case r < 0x1100:
+		return 1
+	}
+
+	for _, wide := range wideRanges {
+		if r < wide[0] {
+			break
+		}
+		if r <= wide[1] {
+			return 2
+		}
+	}
+
+	return 1
+}
+
+// nextGrapheme return the end of the grapheme cluster starting at
+// pos in text: a rune followed by the runes extending it, an emoji
+// joined to the next one, or a pair of regional indicators.
+func nextGrapheme(text string, pos int) int {
+	if pos >= len(text) {
+		return len(text)
+	}
+
+	first, size := utf8.DecodeRuneInString(text[pos:])
+	end := pos + size
+
+	if first == '\r' && end < len(text) && text[end] == '\n' {
+		return end + 1
+	}
+
+	if isRegionalIndicator(first) {
+		if r, size := utf8.DecodeRuneInString(text[end:]); isRegionalIndicator(r) {
+			end += size
+		}
+	}
+
+	previous := first
+
+	for end < len(text) {
+		r, size := utf8.DecodeRuneInString(text[end:])
+
+		if !isExtending(r) && (previous != ZERO_WIDTH_JOINER || unicode.IsControl(r)) {
+			break
+		}
+
+		previous = r
+		end += size
+	}
+
+	return end
+}
+
+// previousGrapheme return the start of the
+// grapheme cluster ending at pos in text
+func previousGrapheme(text string, pos int) int {
+	start := 0
+
+	for end := nextGrapheme(text, start); end < pos; end = nextGrapheme(text, start) {
+		start = end
+	}
+
+	return start
+}
+
+// graphemeWidth return the number of columns the grapheme cluster
+// is shown on, which is the width of its first rune, or two for a
+// flag or an emoji presentation
+func graphemeWidth(cluster string) int {
+	first, size := utf8.DecodeRuneInString(cluster)
+
+	if isRegionalIndicator(first) && len(cluster) > size ||
+		strings.ContainsRune(cluster, EMOJI_PRESENTATION) {
+		return 2
+	}
+
+	return runeWidth(first)
+}
+
+// stringWidth return the number of columns the text is shown on
+func stringWidth(text string) int {
+	width := 0
+
+	for pos := 0; pos < len(text); {
+		end := nextGrapheme(text, pos)
+		width += graphemeWidth(text[pos:end])
+		pos = end
+	}
+
+	return width
+}
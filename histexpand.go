@@ -0,0 +1,462 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// historyOperators are the chars of the operators, which are
+// words of their own when an entry is split into words
+const historyOperators = "|&;<>()"
+
+// historyExpansion is the expansion of the history references of a line
+type historyExpansion struct {
+	history *History
+	line    string
+	pos     int
+	// print is set by the :p modifier, so the line is printed, not run
+	print bool
+}
+
+// Expand expand the history references of the line, like csh: an event
+// designator, `!!`, `!n`, `!-n`, `!prefix` or `!?substr?`, followed by a
+// word designator and modifiers, and the quick substitution `^old^new^`.
+// The references are not expanded between single quotes nor after a
+// backslash. It also report whether the line must be printed, not run.
+func (h *History) Expand(line string) (string, bool, error) {
+	if strings.HasPrefix(line, "^") {
+		line = "!!:s" + line
+	}
+
+	x := &historyExpansion{history: h, line: line}
+	var builder strings.Builder
+	singleQuoted, doubleQuoted := false, false
+
+	for x.pos < len(line) {
+		c := line[x.pos]
+
+		switch {
+		case c == '\\' && !singleQuoted && x.pos+1 < len(line):
+			builder.WriteString(line[x.pos : x.pos+2])
+			x.pos += 2
+			continue
+
+		case c == '\'' && !doubleQuoted:
+			singleQuoted = !singleQuoted
+
+		case c == '"' && !singleQuoted:
+			doubleQuoted = !doubleQuoted
+
+		case c == '!' && !singleQuoted && x.expandable(doubleQuoted):
+			text, err := x.reference()
+			if err != nil {
+				return line, false, err
+			}
+
+			builder.WriteString(text)
+			continue
+		}
+
+		builder.WriteByte(c)
+		x.pos++
+	}
+
+	return builder.String(), x.print, nil
+}
+
+// expandable report whether the `!` at pos start a reference. It doesn't
+// when followed by a blank, `=`, `(`, or the closing double quote, nor
+// in `$!`, `${!name}` and a bracket expression like `[!a-z]`.
+func (x *historyExpansion) expandable(doubleQuoted bool) bool {
+	line, pos := x.line, x.pos
+	if pos+1 == len(line) {
+		return false
+	}
+
+	if pos > 0 {
+		switch previous := line[pos-1]; {
+		case previous == '$':
+			return false
+		case previous == '{' && pos > 1 && line[pos-2] == '$':
+			return false
+		case previous == '[' && strings.IndexByte(line[pos+1:], ']') >= 0:
+			return false
+		}
+	}
+
+	next := line[pos+1]
+
+	return !strings.ContainsRune(" \t\n=(", rune(next)) && !(doubleQuoted && next == '"')
+}
+
+// reference expand the reference starting with the `!` at pos,
+// and move pos after it
+func (x *historyExpansion) reference() (string, error) {
+	start := x.pos
+	x.pos++
+
+	event, err := x.event()
+	if err != nil {
+		return "", err
+	}
+
+	text, err := x.words(event)
+	if err != nil {
+		return "", fmt.Errorf("%s: bad word specifier", x.line[start:x.pos])
+	}
+
+	return x.modifiers(text)
+}
+
+// event return the entry the event designator at pos refer to.
+// Without designator, before a word designator, it's the last one.
+func (x *historyExpansion) event() (string, error) {
+	line, start := x.line, x.pos-1
+	entries := x.history.entries
+	index := -1
+
+	switch c := line[x.pos]; {
+	case c == '!':
+		x.pos++
+		index = len(entries) - 1
+
+	case strings.ContainsRune("$^*:", rune(c)):
+		index = len(entries) - 1
+
+	case isDigit(c) || c == '-' && x.pos+1 < len(line) && isDigit(line[x.pos+1]):
+		end := x.pos + 1
+		for end < len(line) && isDigit(line[end]) {
+			end++
+		}
+
+		n, _ := strconv.Atoi(line[x.pos:end])
+		x.pos = end
+
+		if n > 0 {
+			index = n - 1
+		} else {
+			index = len(entries) + n
+		}
+
+	case c == '?':
+		end := x.pos + 1
+		for end < len(line) && line[end] != '?' && line[end] != '\n' {
+			end++
+		}
+
+		substr := line[x.pos+1 : end]
+		x.pos = end
+		if end < len(line) && line[end] == '?' {
+			x.pos++
+		}
+
+		index = x.history.find(func(entry string) bool {
+			return strings.Contains(entry, substr)
+		})
+
+	default:
+		end := x.pos
+		for end < len(line) && !isHistoryDelimiter(line[end]) {
+			end++
+		}
+
+		prefix := line[x.pos:end]
+		x.pos = end
+
+		index = x.history.find(func(entry string) bool {
+			return strings.HasPrefix(entry, prefix)
+		})
+	}
+
+	if index < 0 || index >= len(entries) {
+		return "", fmt.Errorf("%s: event not found", line[start:x.pos])
+	}
+
+	return entries[index], nil
+}
+
+// find return the index of the latest entry
+// matching, or -1 when there's none
+func (h *History) find(match func(entry string) bool) int {
+	for i := len(h.entries) - 1; i >= 0; i-- {
+		if match(h.entries[i]) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// isHistoryDelimiter report whether the char end
+// the prefix of the `!prefix` event designator
+func isHistoryDelimiter(c byte) bool {
+	return strings.ContainsRune(" \t\n:'\"", rune(c)) || strings.IndexByte(historyOperators, c) >= 0
+}
+
+// words return the words of the event the word designator at pos select:
+// `n`, `^` for the first argument, `$` for the last one, `*` for all the
+// arguments, or a range `n-m`, `n-` without the last word, or `n*`.
+// Without designator, it's the whole event.
+func (x *historyExpansion) words(event string) (string, error) {
+	line := x.line
+	if x.pos == len(line) {
+		return event, nil
+	}
+
+	switch c := line[x.pos]; {
+	case strings.ContainsRune("^$*", rune(c)):
+	case c == ':' && x.pos+1 < len(line) && strings.ContainsRune("0123456789^$*-", rune(line[x.pos+1])):
+		x.pos++
+	default:
+		return event, nil
+	}
+
+	words := historyWords(event)
+	last := len(words) - 1
+
+	// word parse a word number, `^` or `$`
+	word := func() (int, bool) {
+		switch {
+		case x.pos == len(line):
+			return 0, false
+		case line[x.pos] == '^':
+			x.pos++
+			return 1, true
+		case line[x.pos] == '$':
+			x.pos++
+			return last, true
+		}
+
+		end := x.pos
+		for end < len(line) && isDigit(line[end]) {
+			end++
+		}
+
+		n, err := strconv.Atoi(line[x.pos:end])
+		x.pos = end
+
+		return n, err == nil
+	}
+
+	first, to := 0, 0
+
+	if line[x.pos] == '*' {
+		x.pos++
+		first, to = 1, last
+	} else {
+		if line[x.pos] != '-' {
+			n, ok := word()
+			if !ok {
+				return "", strconv.ErrSyntax
+			}
+			first, to = n, n
+		}
+
+		if x.pos < len(line) && line[x.pos] == '*' {
+			x.pos++
+			to = last
+		} else if x.pos < len(line) && line[x.pos] == '-' {
+			x.pos++
+			n, ok := word()
+			if !ok {
+				n = last - 1
+			}
+			to = n
+		}
+	}
+
+	if first > last || to > last || to < first && !(first == 1 && to == 0) {
+		return "", strconv.ErrRange
+	}
+
+	return strings.Join(words[first:to+1], " "), nil
+}
+
+// isDigit report whether the char is a decimal digit
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// historyWords split the entry into words, like the shell does,
+// but the quotes are kept and the operators are words too
+func historyWords(entry string) []string {
+	var words []string
+
+	for i := 0; i < len(entry); {
+		if strings.ContainsRune(" \t\n", rune(entry[i])) {
+			i++
+			continue
+		}
+
+		start := i
+
+		if strings.IndexByte(historyOperators, entry[i]) >= 0 {
+			i++
+			if i < len(entry) && entry[i] == entry[start] && strings.IndexByte("|&;>", entry[i]) >= 0 {
+				i++
+			}
+
+			words = append(words, entry[start:i])
+			continue
+		}
+
+		var quote byte
+
+		for ; i < len(entry); i++ {
+			c := entry[i]
+
+			if quote != 0 {
+				if c == quote {
+					quote = 0
+				} else if c == '\\' && quote == '"' {
+					i++
+				}
+				continue
+			}
+
+			if c == '\\' {
+				i++
+				continue
+			}
+
+			if c == '\'' || c == '"' {
+				quote = c
+				continue
+			}
+
+			if strings.ContainsRune(" \t\n", rune(c)) || strings.IndexByte(historyOperators, c) >= 0 {
+				break
+			}
+		}
+
+		i = min(i, len(entry))
+		words = append(words, entry[start:i])
+	}
+
+	return words
+}
+
+// modifiers apply the modifiers at pos to the text: `:h` keep the
+// head of a path, `:t` its tail, `:r` remove the suffix, `:e` keep
+// it, `:s/old/new/` replace old, `:&` repeat the last substitution,
+// `:g` apply it to all the occurrences, and `:p` print the line
+func (x *historyExpansion) modifiers(text string) (string, error) {
+	line := x.line
+
+	for x.pos+1 < len(line) && line[x.pos] == ':' {
+		start := x.pos
+		x.pos++
+
+		global := line[x.pos] == 'g'
+		if global {
+			x.pos++
+		}
+
+		if x.pos == len(line) {
+			return "", fmt.Errorf("%s: unrecognized history modifier", line[start:])
+		}
+
+		modifier := line[x.pos]
+		x.pos++
+
+		slash := strings.LastIndexByte(text, '/')
+		dot := strings.LastIndexByte(text, '.')
+
+		switch {
+		case global && modifier != 's' && modifier != '&':
+			return "", fmt.Errorf("%s: unrecognized history modifier", line[start:x.pos])
+
+		case modifier == 'h':
+			if slash >= 0 {
+				text = text[:slash]
+			}
+
+		case modifier == 't':
+			text = text[slash+1:]
+
+		case modifier == 'r':
+			if dot > slash {
+				text = text[:dot]
+			}
+
+		case modifier == 'e':
+			if dot > slash {
+				text = text[dot:]
+			} else {
+				text = ""
+			}
+
+		case modifier == 'p':
+			x.print = true
+
+		case modifier == 's' || modifier == '&':
+			if modifier == 's' {
+				x.substitution()
+			}
+
+			old, replacement := x.history.substOld, x.history.substNew
+			if old == "" {
+				return "", fmt.Errorf("%s: no previous substitution", line[start:x.pos])
+			}
+
+			if !strings.Contains(text, old) {
+				return "", fmt.Errorf("%s: substitution failed", line[start:x.pos])
+			}
+
+			count := 1
+			if global {
+				count = -1
+			}
+			text = strings.Replace(text, old, replacement, count)
+
+		default:
+			return "", fmt.Errorf("%s: unrecognized history modifier", line[start:x.pos])
+		}
+	}
+
+	return text, nil
+}
+
+// substitution parse the old and new strings of the `:s` modifier,
+// separated by the delimiter following `s`, which a backslash escape.
+// The last delimiter can be left out at the end of the line. An empty
+// old string is the last one, and `&` in the new one is the old one.
+func (x *historyExpansion) substitution() {
+	line := x.line
+	if x.pos == len(line) {
+		return
+	}
+
+	delimiter := line[x.pos]
+	x.pos++
+
+	// part read the string until the delimiter
+	part := func(ampersand string) string {
+		var builder strings.Builder
+
+		for ; x.pos < len(line) && line[x.pos] != delimiter && line[x.pos] != '\n'; x.pos++ {
+			c := line[x.pos]
+
+			if c == '\\' && x.pos+1 < len(line) && (line[x.pos+1] == delimiter || line[x.pos+1] == '&') {
+				x.pos++
+				builder.WriteByte(line[x.pos])
+			} else if c == '&' && ampersand != "" {
+				builder.WriteString(ampersand)
+			} else {
+				builder.WriteByte(c)
+			}
+		}
+
+		if x.pos < len(line) && line[x.pos] == delimiter {
+			x.pos++
+		}
+
+		return builder.String()
+	}
+
+	if old := part(""); old != "" {
+		x.history.substOld = old
+	}
+	x.history.substNew = part(x.history.substOld)
+}
@@ -0,0 +1,608 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Aboubakary833/cish/pattern"
+	"golang.org/x/sys/unix"
+)
+
+// HISTORY_TAIL is the number of bytes kept from the end of the
+// history file read, to find them again once it's rewritten
+const HISTORY_TAIL = 64
+
+// DEFAULT_HISTSIZE is the number of entries kept by default,
+// in memory and in the history file
+const DEFAULT_HISTSIZE = 500
+
+// HISTFILE_NAME is the name of the history file in the home directory
+const HISTFILE_NAME = ".cish_history"
+
+// historySettings are the settings of the history,
+// read from the shell variables before each command line
+type historySettings struct {
+	// file is the history file, if any
+	file string
+	// size and fileSize are the maximum numbers of entries kept
+	// in memory and in the file, or -1 when there's no limit
+	size     int
+	fileSize int
+	// control are the HISTCONTROL values, and ignore the
+	// HISTIGNORE patterns of the lines not added
+	control map[string]bool
+	ignore  []string
+	// timestamps is set when the time of the entries is saved
+	timestamps bool
+	// share is set when the entries saved by the other
+	// sessions are added before each command line
+	share bool
+}
+
+// History are the command lines typed in the shell, oldest first.
+// The lines are appended to the history file as they are added, so the
+// sessions run at once don't lose each other entries. The file is locked
+// while it's read or written.
+type History struct {
+	entries []string
+	// times are when the entries were added, when it's known
+	times    []time.Time
+	settings *historySettings
+	// offset is the size of the history file when the session last
+	// read or wrote it, and tail the bytes of the file before it
+	offset int64
+	tail   string
+	// substOld and substNew are the strings of the last
+	// substitution of the history expansion
+	substOld string
+	substNew string
+}
+
+// config return the settings of the history, or the default
+// ones without a file nor a limit when they are not set
+func (h *History) config() *historySettings {
+	if h.settings == nil {
+		return &historySettings{size: -1, fileSize: -1}
+	}
+
+	return h.settings
+}
+
+// Add add the command line to the history and append it to the history
+// file. Its last newline is removed, and blank lines are not added, nor
+// those HISTCONTROL or HISTIGNORE ignore. With HISTCONTROL=erasedups,
+// the previous entries equal to the line are removed, from the file too.
+func (h *History) Add(line string) error {
+	line = strings.TrimSuffix(line, "\n")
+	settings := h.config()
+
+	if strings.TrimSpace(line) == "" || settings.size == 0 || h.ignored(line) {
+		return nil
+	}
+
+	if settings.control["erasedups"] {
+		for i := len(h.entries) - 1; i >= 0; i-- {
+			if h.entries[i] == line {
+				h.entries = slices.Delete(h.entries, i, i+1)
+				h.times = slices.Delete(h.times, i, i+1)
+			}
+		}
+	}
+
+	return h.record(line, time.Now())
+}
+
+// ignored report whether HISTCONTROL or HISTIGNORE ignore the line.
+// In HISTIGNORE, `&` match the previous entry.
+func (h *History) ignored(line string) bool {
+	settings := h.config()
+	previous := ""
+	if len(h.entries) != 0 {
+		previous = h.entries[len(h.entries)-1]
+	}
+
+	switch {
+	case settings.control["ignorespace"] && strings.HasPrefix(line, " "):
+		return true
+	case settings.control["ignoredups"] && line == previous:
+		return true
+	}
+
+	for _, pat := range settings.ignore {
+		if pat == "&" && line == previous || pat != "&" && pattern.Match(pat, line) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// record add the entry in memory and append it to the history file,
+// which is then rewritten if it has too many entries or erasedups
+// removed some. When the history is shared, the entries saved by the
+// other sessions since the file was last read are added first.
+func (h *History) record(line string, added time.Time) error {
+	settings := h.config()
+
+	if settings.file == "" {
+		h.append(line, added)
+		return nil
+	}
+
+	file, err := openHistoryFile(settings.file, os.O_RDWR|os.O_CREATE|os.O_APPEND, unix.LOCK_EX)
+	if err != nil {
+		h.append(line, added)
+		return err
+	}
+	defer file.Close()
+
+	if settings.share {
+		if err := h.readFrom(file); err != nil {
+			return err
+		}
+	}
+
+	h.append(line, added)
+
+	if !settings.timestamps {
+		added = time.Time{}
+	}
+
+	if _, err := file.WriteString(formatEntry(line, added)); err != nil {
+		return err
+	}
+
+	return h.trimFile(file, line)
+}
+
+// trimFile rewrite the locked history file without the entries equal
+// to the last one, line, when HISTCONTROL has erasedups, and with its
+// last HISTFILESIZE entries only. The file is left as it is when no
+// entry is removed.
+func (h *History) trimFile(file *os.File, line string) error {
+	settings := h.config()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	if settings.fileSize < 0 && !settings.control["erasedups"] {
+		return h.markRead(file, info.Size())
+	}
+
+	data, err := io.ReadAll(io.NewSectionReader(file, 0, info.Size()))
+	if err != nil {
+		return err
+	}
+
+	lines, times := parseHistory(string(data))
+	count := len(lines)
+
+	if settings.control["erasedups"] {
+		for i := len(lines) - 2; i >= 0; i-- {
+			if lines[i] == line {
+				lines = slices.Delete(lines, i, i+1)
+				times = slices.Delete(times, i, i+1)
+			}
+		}
+	}
+
+	if limit := settings.fileSize; limit >= 0 && len(lines) > limit {
+		lines, times = lines[len(lines)-limit:], times[len(times)-limit:]
+	}
+
+	if len(lines) == count {
+		return h.markRead(file, info.Size())
+	}
+
+	return h.rewrite(file, formatHistory(lines, times))
+}
+
+// rewrite replace the content of the locked history file
+func (h *History) rewrite(file *os.File, content string) error {
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := file.WriteString(content); err != nil {
+		return err
+	}
+
+	return h.markRead(file, int64(len(content)))
+}
+
+// markRead record that the history file was read or written up to size
+func (h *History) markRead(file *os.File, size int64) error {
+	tail := make([]byte, min(size, HISTORY_TAIL))
+
+	if _, err := file.ReadAt(tail, size-int64(len(tail))); err != nil {
+		return err
+	}
+	h.offset, h.tail = size, string(tail)
+
+	return nil
+}
+
+// append add the entry in memory, removing the oldest
+// ones when there are more than HISTSIZE entries
+func (h *History) append(line string, added time.Time) {
+	h.entries = append(h.entries, line)
+	h.times = append(h.times, added)
+
+	if size := h.config().size; size >= 0 && len(h.entries) > size {
+		h.entries = slices.Delete(h.entries, 0, len(h.entries)-size)
+		h.times = slices.Delete(h.times, 0, len(h.times)-size)
+	}
+}
+
+// Len return the number of entries of the history
+func (h *History) Len() int {
+	return len(h.entries)
+}
+
+// Load add the entries of the history file, which
+// is first cut to its last HISTFILESIZE entries
+func (h *History) Load() error {
+	settings := h.config()
+	if settings.file == "" {
+		return nil
+	}
+
+	file, err := openHistoryFile(settings.file, os.O_RDWR|os.O_CREATE, unix.LOCK_EX)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+
+	lines, times := parseHistory(string(data))
+	limit := settings.fileSize
+	trimmed := limit >= 0 && len(lines) > limit
+
+	if trimmed {
+		lines, times = lines[len(lines)-limit:], times[len(times)-limit:]
+	}
+
+	for i, line := range lines {
+		h.append(line, times[i])
+	}
+
+	if trimmed {
+		return h.rewrite(file, formatHistory(lines, times))
+	}
+
+	return h.markRead(file, int64(len(data)))
+}
+
+// Sync add the entries saved in the history file by the other
+// sessions since the file was last read, when the history is shared
+func (h *History) Sync() error {
+	settings := h.config()
+	if settings.file == "" || !settings.share {
+		return nil
+	}
+
+	file, err := openHistoryFile(settings.file, os.O_RDONLY, unix.LOCK_SH)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return h.readFrom(file)
+}
+
+// readFrom add the entries of the locked history file following the
+// offset. When another session rewrote the file meanwhile, they follow
+// the last entries of the tail read last which are still in the file,
+// or there are none if it was all removed.
+func (h *History) readFrom(file *os.File) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	size := info.Size()
+
+	if h.rewritten(file, size) {
+		data, err := io.ReadAll(io.NewSectionReader(file, 0, size))
+		if err != nil {
+			return err
+		}
+
+		h.offset = size
+		for tail := h.tail; tail != ""; _, tail, _ = strings.Cut(tail, "\n") {
+			if at := strings.LastIndex(string(data), tail); at >= 0 {
+				h.offset = int64(at + len(tail))
+				break
+			}
+		}
+	}
+
+	data, err := io.ReadAll(io.NewSectionReader(file, h.offset, size-h.offset))
+	if err != nil {
+		return err
+	}
+
+	lines, times := parseHistory(string(data))
+	for i, line := range lines {
+		h.append(line, times[i])
+	}
+
+	return h.markRead(file, size)
+}
+
+// rewritten report whether the history file of the given size is not
+// the one last read anymore, its tail being elsewhere
+func (h *History) rewritten(file *os.File, size int64) bool {
+	if size < h.offset {
+		return true
+	}
+
+	tail := make([]byte, len(h.tail))
+	_, err := file.ReadAt(tail, h.offset-int64(len(tail)))
+
+	return err != nil || string(tail) != h.tail
+}
+
+// openHistoryFile open the history file and lock it with
+// how, LOCK_SH or LOCK_EX. Closing the file unlock it.
+func openHistoryFile(path string, flag int, how int) (*os.File, error) {
+	file, err := os.OpenFile(path, flag, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := unix.Flock(int(file.Fd()), how); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// historyEscaper escape the backslashes and the newlines of the
+// entries, so each entry is written on a single line of the file
+var historyEscaper = strings.NewReplacer("\\", "\\\\", "\n", "\\n")
+
+// formatEntry return the line of the entry in the history file,
+// preceded by a `#seconds` line when its time is given
+func formatEntry(line string, added time.Time) string {
+	entry := historyEscaper.Replace(line) + "\n"
+
+	if !added.IsZero() {
+		entry = fmt.Sprintf("#%d\n", added.Unix()) + entry
+	}
+
+	return entry
+}
+
+// formatHistory return the content of the history file
+func formatHistory(lines []string, times []time.Time) string {
+	var builder strings.Builder
+
+	for i, line := range lines {
+		builder.WriteString(formatEntry(line, times[i]))
+	}
+
+	return builder.String()
+}
+
+// parseHistory return the entries of the history file and their time
+func parseHistory(data string) ([]string, []time.Time) {
+	var lines []string
+	var times []time.Time
+	var added time.Time
+
+	for _, line := range strings.Split(data, "\n") {
+		if seconds, ok := strings.CutPrefix(line, "#"); ok {
+			if n, err := strconv.ParseInt(seconds, 10, 64); err == nil && n >= 0 {
+				added = time.Unix(n, 0)
+				continue
+			}
+		}
+
+		if line == "" {
+			continue
+		}
+
+		lines = append(lines, unescapeEntry(line))
+		times = append(times, added)
+		added = time.Time{}
+	}
+
+	return lines, times
+}
+
+// unescapeEntry return the entry written on the line
+func unescapeEntry(line string) string {
+	var builder strings.Builder
+
+	for i := 0; i < len(line); i++ {
+		if line[i] != '\\' || i+1 == len(line) {
+			builder.WriteByte(line[i])
+			continue
+		}
+
+		i++
+		if line[i] == 'n' {
+			builder.WriteByte('\n')
+		} else {
+			builder.WriteByte(line[i])
+		}
+	}
+
+	return builder.String()
+}
+
+// initHistory set the history variables which are not set
+// yet, like bash does when it start interactive
+func (sh *Shell) initHistory() {
+	if _, set := sh.vars.Get("HISTFILE"); !set {
+		if home, set := sh.vars.Get("HOME"); set {
+			sh.vars.Set("HISTFILE", filepath.Join(home, HISTFILE_NAME))
+		}
+	}
+
+	size, set := sh.vars.Get("HISTSIZE")
+	if !set {
+		size = strconv.Itoa(DEFAULT_HISTSIZE)
+		sh.vars.Set("HISTSIZE", size)
+	}
+
+	if _, set := sh.vars.Get("HISTFILESIZE"); !set {
+		sh.vars.Set("HISTFILESIZE", size)
+	}
+}
+
+// historySettings read the settings of the history from HISTFILE,
+// HISTSIZE, HISTFILESIZE, HISTCONTROL, HISTIGNORE and HISTTIMEFORMAT,
+// and the sharehistory option
+func (sh *Shell) historySettings() *historySettings {
+	settings := &historySettings{
+		control: make(map[string]bool),
+		share:   sh.options[OPTION_SHAREHISTORY],
+	}
+
+	settings.file, _ = sh.vars.Get("HISTFILE")
+	settings.size = sh.historyLimit("HISTSIZE")
+	settings.fileSize = sh.historyLimit("HISTFILESIZE")
+	_, settings.timestamps = sh.vars.Get("HISTTIMEFORMAT")
+
+	control, _ := sh.vars.Get("HISTCONTROL")
+	for _, value := range strings.Split(control, ":") {
+		if value == "ignoreboth" {
+			settings.control["ignorespace"] = true
+			settings.control["ignoredups"] = true
+		}
+		settings.control[value] = true
+	}
+
+	if ignore, _ := sh.vars.Get("HISTIGNORE"); ignore != "" {
+		settings.ignore = strings.Split(ignore, ":")
+	}
+
+	return settings
+}
+
+// historyLimit return the limit of the variable, or -1 when
+// it's not set to a number, which mean there's no limit
+func (sh *Shell) historyLimit(name string) int {
+	value, _ := sh.vars.Get(name)
+
+	if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+		return n
+	}
+
+	return -1
+}
+
+// lineDraft is the command line being typed when an
+// entry of the history is recalled in its place
+type lineDraft struct {
+	buffer       string
+	quotesOpened bool
+	openedQuote  byte
+	shouldEscape bool
+}
+
+// recallPrevious replace the buffer with the previous entry of the
+// history starting with the text typed before the navigation began
+func (cmd *Command) recallPrevious() {
+	if cmd.history == nil {
+		return
+	}
+
+	if cmd.draft == nil {
+		cmd.draft = &lineDraft{cmd.buffer, cmd.quotesOpened, cmd.openedQuote, cmd.shouldEscape}
+		cmd.histIndex = cmd.history.Len()
+	}
+
+	for i := cmd.histIndex - 1; i >= 0; i-- {
+		if cmd.matchDraft(cmd.history.entries[i]) {
+			cmd.histIndex = i
+			cmd.recall(cmd.history.entries[i])
+			return
+		}
+	}
+}
+
+// recallNext replace the buffer with the next entry of the history
+// starting with the text typed, or with the text typed after the last one
+func (cmd *Command) recallNext() {
+	if cmd.draft == nil {
+		return
+	}
+
+	for i := cmd.histIndex + 1; i < cmd.history.Len(); i++ {
+		if cmd.matchDraft(cmd.history.entries[i]) {
+			cmd.histIndex = i
+			cmd.recall(cmd.history.entries[i])
+			return
+		}
+	}
+
+	draft := cmd.draft
+	cmd.draft = nil
+	cmd.histIndex = cmd.history.Len()
+	cmd.showBuffer(draft.buffer)
+	cmd.quotesOpened, cmd.openedQuote, cmd.shouldEscape = draft.quotesOpened, draft.openedQuote, draft.shouldEscape
+}
+
+// matchDraft report whether the entry can be recalled: it start with the
+// text typed, and it's not the one shown, so the duplicates are skipped
+func (cmd *Command) matchDraft(entry string) bool {
+	return strings.HasPrefix(entry, cmd.draft.buffer) && entry != cmd.buffer
+}
+
+// recall show the entry of the history in place of the buffer.
+// The entries are complete commands, so no quote is left open.
+func (cmd *Command) recall(entry string) {
+	cmd.showBuffer(entry)
+	cmd.quotesOpened, cmd.openedQuote, cmd.shouldEscape = false, NULChar, false
+}
+
+// showBuffer replace the command shown after the prompt with text,
+// whose lines are shown after the secondary prompt, and set it as
+// the buffer with the cursor at its end
+func (cmd *Command) showBuffer(text string) {
+	cmd.clearShown(strings.Count(cmd.buffer[:cmd.cursorPos], "\n"))
+	cmd.printBuffer(text)
+}
+
+// clearShown erase the command shown, whose cursor is
+// the given number of lines below its first line
+func (cmd *Command) clearShown(lines int) {
+	if lines != 0 {
+		cmd.defaultPrint(fmt.Sprintf("%s%dA", ARROW_CHUNK, lines))
+	}
+	cmd.defaultPrint("\r" + ARROW_CHUNK + "J")
+}
+
+// printBuffer print the prompt followed by text,
+// and set it as the buffer with the cursor at its end
+func (cmd *Command) printBuffer(text string) {
+	cmd.printPS1Prompt()
+	cmd.defaultPrint(strings.ReplaceAll(text, "\n", "\r\n> "))
+
+	if strings.Contains(text, "\n") {
+		cmd.prompt = PS2
+	}
+
+	cmd.setBuffer(text)
+}
@@ -0,0 +1,948 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/Aboubakary833/cish/parser"
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// States of the jobs and of their programs
+const (
+	JOB_RUNNING = iota
+	JOB_STOPPED
+	JOB_DONE
+)
+
+// MAX_PID is PID_MAX_LIMIT, above the process ids of Linux. The
+// asynchronous lists starting no program get an id above it.
+const MAX_PID = 1 << 22
+
+// stopDescriptions describe the signals stopping the programs
+var stopDescriptions = map[syscall.Signal]string{
+	syscall.SIGTSTP: "Stopped",
+	syscall.SIGSTOP: "Stopped (signal)",
+	syscall.SIGTTIN: "Stopped (tty input)",
+	syscall.SIGTTOU: "Stopped (tty output)",
+}
+
+// process is a program started by the shell
+type process struct {
+	pid  int
+	pgid int
+	// job is the job which started the program, if any
+	job   *Job
+	state int
+	// status is the exit status of the program, or 128 plus
+	// the number of the signal which stopped or killed it
+	status int
+	signal syscall.Signal
+}
+
+// Job is a pipeline run in the foreground, or an asynchronous
+// list run in the background, with the programs it started.
+type Job struct {
+	id   int
+	text string
+	// pid is the id `$!` and `wait` know an asynchronous list by,
+	// which is the process id of its first program, if any
+	pid int
+	// pgid is the process group of the last programs started,
+	// when the job control is enabled
+	pgid  int
+	procs []*process
+	// async is true for the asynchronous lists, whose
+	// commands are not stopped with their programs
+	async bool
+	// foreground is true while the shell wait for the job
+	foreground bool
+	// running is true while the commands of the job are run,
+	// and status is their status once they are done
+	running bool
+	status  int
+	// resumed is set when the job is continued after its commands
+	// are done, its status being then the one of its last program
+	resumed bool
+	// seq order the jobs by when they were last started or stopped
+	seq int
+	// reported is the last state of the job which was reported
+	reported int
+	// noHangup is set by `disown -h`
+	noHangup bool
+}
+
+// state return the state of the job. It's stopped as soon as one of
+// its programs is stopped, and done when all its programs and
+// commands are done.
+func (job *Job) state() int {
+	state := JOB_DONE
+	if job.running {
+		state = JOB_RUNNING
+	}
+
+	for _, proc := range job.procs {
+		switch proc.state {
+		case JOB_STOPPED:
+			return JOB_STOPPED
+		case JOB_RUNNING:
+			state = JOB_RUNNING
+		}
+	}
+
+	return state
+}
+
+// exitStatus return the status of the job
+func (job *Job) exitStatus() int {
+	if job.resumed && len(job.procs) != 0 {
+		return job.procs[len(job.procs)-1].status
+	}
+
+	return job.status
+}
+
+// leader return the process id identifying the job
+func (job *Job) leader() int {
+	switch {
+	case job.pgid != 0:
+		return job.pgid
+	case len(job.procs) != 0:
+		return job.procs[0].pid
+	}
+
+	return 0
+}
+
+// describe return the state of the job as `jobs` print it
+func (job *Job) describe() string {
+	switch job.state() {
+	case JOB_RUNNING:
+		return "Running"
+
+	case JOB_STOPPED:
+		for _, proc := range job.procs {
+			if description, ok := stopDescriptions[proc.signal]; ok && proc.state == JOB_STOPPED {
+				return description
+			}
+		}
+		return "Stopped"
+	}
+
+	status := job.exitStatus()
+
+	if len(job.procs) != 0 {
+		last := job.procs[len(job.procs)-1]
+		if last.signal != 0 && status == STATUS_SIGNAL+int(last.signal) {
+			name := last.signal.String()
+			return strings.ToUpper(name[:1]) + name[1:]
+		}
+	}
+
+	if status == STATUS_SUCCESS {
+		return "Done"
+	}
+
+	return fmt.Sprintf("Exit %d", status)
+}
+
+// jobTable hold the jobs of the shell. Each started program is waited
+// for by a goroutine which update its state and broadcast cond.
+type jobTable struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	jobs []*Job
+	// seq is the seq of the last job started or stopped
+	seq int
+	// ids is the number of ids given to the
+	// asynchronous lists starting no program
+	ids int
+}
+
+func newJobTable() *jobTable {
+	t := &jobTable{}
+	t.cond = sync.NewCond(&t.mu)
+
+	return t
+}
+
+// add number the job and add it to the table. t.mu must be held.
+func (t *jobTable) add(job *Job) {
+	job.id = 1
+	if len(t.jobs) != 0 {
+		job.id = t.jobs[len(t.jobs)-1].id + 1
+	}
+
+	t.seq++
+	job.seq = t.seq
+	t.jobs = append(t.jobs, job)
+}
+
+// remove remove the job from the table. t.mu must be held.
+func (t *jobTable) remove(job *Job) {
+	t.jobs = slices.DeleteFunc(t.jobs, func(other *Job) bool {
+		return other == job
+	})
+}
+
+// finish record the status of the commands of the job
+func (t *jobTable) finish(job *Job, status int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	job.running = false
+	job.status = status
+	t.cond.Broadcast()
+}
+
+// ordered return the jobs from the current one, which is the last
+// stopped or the last started in the background if none is stopped.
+// t.mu must be held.
+func (t *jobTable) ordered() []*Job {
+	jobs := slices.Clone(t.jobs)
+
+	slices.SortStableFunc(jobs, func(a, b *Job) int {
+		aStopped, bStopped := a.state() == JOB_STOPPED, b.state() == JOB_STOPPED
+
+		switch {
+		case aStopped && !bStopped:
+			return -1
+		case bStopped && !aStopped:
+			return 1
+		}
+		return b.seq - a.seq
+	})
+
+	return jobs
+}
+
+// marker return `+` for the current job, `-` for the
+// previous one or a space for the others. t.mu must be held.
+func (t *jobTable) marker(job *Job) byte {
+	jobs := t.ordered()
+
+	switch {
+	case jobs[0] == job:
+		return '+'
+	case len(jobs) > 1 && jobs[1] == job:
+		return '-'
+	}
+
+	return ' '
+}
+
+// format return the line of the job as `jobs` print it,
+// with its process id when long is true. t.mu must be held.
+func (t *jobTable) format(job *Job, long bool) string {
+	pid := " "
+	if long {
+		pid = strconv.Itoa(job.leader()) + " "
+	}
+
+	text := job.text
+	if job.state() == JOB_RUNNING {
+		text += " &"
+	}
+
+	return fmt.Sprintf("[%d]%c %s%-24s%s", job.id, t.marker(job), pid, job.describe(), text)
+}
+
+// find return the job of the job spec, like `%1`, `%+`, `%-`,
+// `%name` for the job whose command start with name, or `%?text`
+// for the job whose command contain text. An empty spec is the
+// current job. t.mu must be held.
+func (t *jobTable) find(spec string) (*Job, error) {
+	jobs := t.ordered()
+
+	switch spec {
+	case "", "%", "%%", "%+":
+		if len(jobs) == 0 {
+			return nil, fmt.Errorf("current: no such job")
+		}
+		return jobs[0], nil
+
+	case "%-":
+		if len(jobs) < 2 {
+			return nil, fmt.Errorf("%s: no such job", spec)
+		}
+		return jobs[1], nil
+	}
+
+	if !strings.HasPrefix(spec, "%") {
+		return nil, fmt.Errorf("%s: no such job", spec)
+	}
+
+	if id, err := strconv.Atoi(spec[1:]); err == nil {
+		for _, job := range t.jobs {
+			if job.id == id {
+				return job, nil
+			}
+		}
+		return nil, fmt.Errorf("%s: no such job", spec)
+	}
+
+	var found *Job
+
+	for _, job := range t.jobs {
+		matched := strings.HasPrefix(job.text, spec[1:])
+		if text, ok := strings.CutPrefix(spec, "%?"); ok {
+			matched = strings.Contains(job.text, text)
+		}
+
+		if !matched {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("%s: ambiguous job spec", spec)
+		}
+		found = job
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf("%s: no such job", spec)
+	}
+
+	return found, nil
+}
+
+// watch wait for the program to change state until it's done. The
+// program is reaped with t.mu held, so its process group is known to
+// exist while it's not done.
+func (t *jobTable) watch(proc *process) {
+	for {
+		var info unix.Siginfo
+		options := unix.WEXITED | unix.WSTOPPED | unix.WCONTINUED | unix.WNOWAIT
+
+		err := unix.Waitid(unix.P_PID, proc.pid, &info, options, nil)
+		if err == unix.EINTR {
+			continue
+		}
+
+		t.mu.Lock()
+
+		var ws syscall.WaitStatus
+		if err == nil {
+			var pid int
+			pid, err = syscall.Wait4(proc.pid, &ws, syscall.WNOHANG|syscall.WUNTRACED|syscall.WCONTINUED, nil)
+			if err == nil && pid == 0 {
+				t.mu.Unlock()
+				continue
+			}
+		}
+
+		switch {
+		case err != nil:
+			proc.state, proc.status = JOB_DONE, STATUS_FAILURE
+
+		case ws.Stopped():
+			proc.state, proc.signal = JOB_STOPPED, ws.StopSignal()
+			proc.status = STATUS_SIGNAL + int(proc.signal)
+
+			if proc.job != nil {
+				t.seq++
+				proc.job.seq = t.seq
+			}
+
+		case ws.Continued():
+			proc.state = JOB_RUNNING
+
+		case ws.Signaled():
+			proc.state, proc.signal = JOB_DONE, ws.Signal()
+			proc.status = STATUS_SIGNAL + int(proc.signal)
+
+		default:
+			proc.state, proc.status = JOB_DONE, ws.ExitStatus()
+		}
+
+		done := proc.state == JOB_DONE
+		t.cond.Broadcast()
+		t.mu.Unlock()
+
+		if done {
+			return
+		}
+	}
+}
+
+// startProcess start the program and wait for it in the background.
+// With the job control, the programs of a job are put in the same
+// process group, which get the terminal when the job is in the
+// foreground.
+func (sh *Shell) startProcess(cmd *exec.Cmd) (*process, error) {
+	t := sh.jobs
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	job := sh.job
+	pgid := 0
+
+	if job != nil && sh.jobControl {
+		// a new process group is made when the
+		// programs of the last one are all done
+		for _, proc := range job.procs {
+			if proc.pgid == job.pgid && proc.state != JOB_DONE {
+				pgid = job.pgid
+			}
+		}
+
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Pgid: pgid}
+		if job.foreground {
+			cmd.SysProcAttr.Foreground = true
+			cmd.SysProcAttr.Ctty = sh.sourceFd
+		}
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	proc := &process{pid: cmd.Process.Pid, pgid: pgid, job: job, state: JOB_RUNNING}
+	// the program is waited for by watch
+	cmd.Process.Release()
+
+	if job != nil {
+		if cmd.SysProcAttr != nil && pgid == 0 {
+			job.pgid = proc.pid
+			proc.pgid = proc.pid
+		}
+		job.procs = append(job.procs, proc)
+	}
+
+	go t.watch(proc)
+
+	return proc, nil
+}
+
+// waitProcess wait for the program to be done and return its status.
+// The programs of a foreground pipeline are only waited for until
+// they are stopped, so the shell get the terminal back.
+// In an interactive shell, a program killed by SIGINT
+// interrupt the command line as Ctrl-C would.
+func (sh *Shell) waitProcess(proc *process) int {
+	t := sh.jobs
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for proc.state == JOB_RUNNING || (proc.state == JOB_STOPPED && (proc.job == nil || proc.job.async)) {
+		t.cond.Wait()
+	}
+
+	// a program interrupted by Ctrl-C interrupt the command line too
+	if proc.signal == syscall.SIGINT && proc.state == JOB_DONE && sh.interactive {
+		sh.interrupt()
+	}
+
+	return proc.status
+}
+
+// runJob run the pipeline as a foreground job. If its programs
+// are stopped, the job is added to the job table so it can be
+// continued with `fg` or `bg`.
+func (sh *Shell) runJob(pipeline *parser.Pipeline, io streams) {
+	text := parser.FormatAndOr(&parser.AndOr{Pipelines: []*parser.Pipeline{pipeline}})
+	job := &Job{text: text, foreground: true, running: true}
+
+	sh.job = job
+	sh.runPipeline(pipeline, io)
+	sh.job = nil
+
+	sh.jobs.finish(job, sh.status.Code())
+	sh.setForeground(sh.pgid)
+
+	t := sh.jobs
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	job.foreground = false
+	if job.state() != JOB_STOPPED {
+		return
+	}
+
+	t.add(job)
+	job.reported = JOB_STOPPED
+	fmt.Fprintf(io.stderr, "\n%s\n", t.format(job, false))
+}
+
+// runBackground run the AND-OR list as a background job, without
+// waiting for it. `$!` is set to the process id of its first program
+// when it start with one, or else to an id above MAX_PID.
+func (sh *Shell) runBackground(andOr *parser.AndOr, io streams) {
+	job := &Job{text: parser.FormatAndOr(andOr), async: true, running: true}
+
+	sh.jobs.mu.Lock()
+	sh.jobs.add(job)
+	sh.jobs.mu.Unlock()
+
+	sub := sh.subshell()
+	sub.job = job
+	// Ctrl-C only interrupt the foreground commands
+	sub.interrupted = &atomic.Bool{}
+	started := make(chan int, 1)
+
+	if sh.startsProgram(andOr) {
+		sub.onStart = func(pid int) {
+			select {
+			case started <- pid:
+			default:
+			}
+		}
+	} else {
+		started <- 0
+	}
+
+	go func() {
+		sub.runAndOr(andOr, io)
+		sub.runExitTrap(io)
+		sh.jobs.finish(job, sub.status.Code())
+		if sub.onStart != nil {
+			sub.onStart(0)
+		}
+	}()
+
+	pid := <-started
+
+	sh.jobs.mu.Lock()
+	if pid == 0 {
+		sh.jobs.ids++
+		pid = MAX_PID + sh.jobs.ids
+	}
+	job.pid = pid
+	sh.jobs.mu.Unlock()
+
+	sh.lastPid = pid
+
+	if sh.interactive {
+		fmt.Fprintf(io.stderr, "[%d] %d\n", job.id, pid)
+	}
+	sh.status.Set(STATUS_SUCCESS)
+}
+
+// startsProgram report whether the AND-OR list start with a program
+// which is started without running other commands before, so it's
+// waited for to give its process id to `$!`. Its name must be a plain
+// word, and its words must not run command substitutions.
+func (sh *Shell) startsProgram(andOr *parser.AndOr) bool {
+	cmd, ok := andOr.Pipelines[0].Commands[0].(*parser.SimpleCommand)
+	if !ok || len(cmd.Args) == 0 || sh.traps["DEBUG"] != "" {
+		return false
+	}
+
+	name := cmd.Args[0].Text
+	_, function := sh.funcs[name]
+	_, builtin := builtins[name]
+	if function || builtin || strings.ContainsAny(name, "$`'\"\\") {
+		return false
+	}
+
+	words := slices.Clone(cmd.Args)
+	for _, assign := range cmd.Assigns {
+		words = append(words, assign.Value)
+	}
+	for _, redirect := range cmd.Redirects {
+		if redirect.Target != nil {
+			words = append(words, redirect.Target)
+		}
+	}
+
+	for _, word := range words {
+		if strings.Contains(word.Text, "$(") || strings.Contains(word.Text, "`") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// continueJob send SIGCONT to the programs of the job and wait
+// for them to be continued. t.mu must be held.
+func (sh *Shell) continueJob(job *Job) {
+	// the status of the job is the one of its last
+	// program if its commands were done already
+	job.resumed = job.resumed || !job.running
+	sh.signalJob(job, syscall.SIGCONT)
+
+	for job.state() == JOB_STOPPED {
+		sh.jobs.cond.Wait()
+	}
+}
+
+// signalJob send the signal to the programs of the job,
+// or to their process groups. t.mu must be held.
+func (sh *Shell) signalJob(job *Job, sig syscall.Signal) {
+	var signaled []int
+
+	for _, proc := range job.procs {
+		if proc.state == JOB_DONE {
+			continue
+		}
+
+		pid := proc.pid
+		if sh.jobControl && proc.pgid != 0 {
+			pid = -proc.pgid
+		}
+
+		if !slices.Contains(signaled, pid) {
+			syscall.Kill(pid, sig)
+			signaled = append(signaled, pid)
+		}
+	}
+}
+
+// foregroundJob continue the job in the foreground and wait for it
+// to be done or stopped, then return its status
+func (sh *Shell) foregroundJob(job *Job, io streams) int {
+	t := sh.jobs
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	job.foreground = true
+	sh.setForeground(job.pgid)
+	sh.continueJob(job)
+
+	for job.state() == JOB_RUNNING {
+		t.cond.Wait()
+	}
+
+	job.foreground = false
+	sh.setForeground(sh.pgid)
+
+	if job.state() == JOB_STOPPED {
+		job.reported = JOB_STOPPED
+		fmt.Fprintf(io.stderr, "\n%s\n", t.format(job, false))
+
+		for _, proc := range job.procs {
+			if proc.state == JOB_STOPPED {
+				return proc.status
+			}
+		}
+	}
+
+	t.remove(job)
+
+	return job.exitStatus()
+}
+
+// notifyJobs report the jobs which were stopped or are done since
+// their last report, and remove the done ones from the table
+func (sh *Shell) notifyJobs(w io.Writer) {
+	t := sh.jobs
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, job := range slices.Clone(t.jobs) {
+		state := job.state()
+		if state == job.reported {
+			continue
+		}
+
+		if state != JOB_RUNNING {
+			fmt.Fprintln(w, t.format(job, false))
+		}
+		job.reported = state
+
+		if state == JOB_DONE {
+			t.remove(job)
+		}
+	}
+}
+
+// hangupJobs send SIGHUP to the jobs, except those disowned with
+// `disown -h`. The stopped jobs are continued so they get it.
+func (sh *Shell) hangupJobs() {
+	t := sh.jobs
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, job := range t.jobs {
+		if job.noHangup {
+			continue
+		}
+
+		sh.signalJob(job, syscall.SIGHUP)
+		if job.state() == JOB_STOPPED {
+			sh.signalJob(job, syscall.SIGCONT)
+		}
+	}
+}
+
+// initJobControl enable the job control when the shell read a terminal.
+// The stop signals must then be caught with initSignals, so they don't
+// stop the shell.
+func (sh *Shell) initJobControl() {
+	if !term.IsTerminal(sh.sourceFd) {
+		return
+	}
+
+	sh.jobControl = true
+	sh.pgid = unix.Getpgrp()
+	sh.setForeground(sh.pgid)
+}
+
+// setForeground give the terminal to the process group. SIGTTOU is
+// blocked meanwhile, as the shell may not be in the foreground itself.
+func (sh *Shell) setForeground(pgid int) {
+	if !sh.jobControl || pgid == 0 {
+		return
+	}
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	var blocked, saved unix.Sigset_t
+	bit := uint(syscall.SIGTTOU) - 1
+	blocked.Val[bit/64] |= 1 << (bit % 64)
+
+	unix.PthreadSigmask(unix.SIG_BLOCK, &blocked, &saved)
+	unix.IoctlSetPointerInt(sh.sourceFd, unix.TIOCSPGRP, pgid)
+	unix.PthreadSigmask(unix.SIG_SETMASK, &saved, nil)
+}
+
+// jobsBuiltin print the jobs, with their process id when -l is
+// given, or only their process id with -p. The done jobs printed
+// are removed from the table.
+func (sh *Shell) jobsBuiltin(args []string, io streams) int {
+	flags, specs, err := parseFlags(args, "lprs")
+	if err != nil {
+		printError(io, fmt.Errorf("jobs: %s", err.Error()))
+		return STATUS_MISUSE
+	}
+
+	t := sh.jobs
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	jobs := slices.Clone(t.jobs)
+	status := STATUS_SUCCESS
+
+	if len(specs) != 0 {
+		jobs = nil
+
+		for _, spec := range specs {
+			job, err := t.find(spec)
+			if err != nil {
+				printError(io, fmt.Errorf("jobs: %s", err.Error()))
+				status = STATUS_FAILURE
+				continue
+			}
+			jobs = append(jobs, job)
+		}
+	}
+
+	for _, job := range jobs {
+		state := job.state()
+
+		if (flags['r'] && state != JOB_RUNNING) || (flags['s'] && state != JOB_STOPPED) {
+			continue
+		}
+
+		if flags['p'] {
+			fmt.Fprintln(io.stdout, job.leader())
+		} else {
+			fmt.Fprintln(io.stdout, t.format(job, flags['l']))
+		}
+
+		job.reported = state
+		if state == JOB_DONE {
+			t.remove(job)
+		}
+	}
+
+	return status
+}
+
+// fg continue the job in the foreground, the current one by default
+func (sh *Shell) fg(args []string, io streams) int {
+	if len(args) > 2 {
+		printError(io, fmt.Errorf("fg: too many arguments"))
+		return STATUS_MISUSE
+	}
+
+	spec := ""
+	if len(args) == 2 {
+		spec = args[1]
+	}
+
+	sh.jobs.mu.Lock()
+	job, err := sh.jobs.find(spec)
+	sh.jobs.mu.Unlock()
+
+	if err != nil {
+		printError(io, fmt.Errorf("fg: %s", err.Error()))
+		return STATUS_FAILURE
+	}
+
+	fmt.Fprintln(io.stdout, job.text)
+
+	return sh.foregroundJob(job, io)
+}
+
+// bg continue the stopped jobs in the background,
+// the current one by default
+func (sh *Shell) bg(args []string, io streams) int {
+	specs := args[1:]
+	if len(specs) == 0 {
+		specs = []string{""}
+	}
+
+	t := sh.jobs
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status := STATUS_SUCCESS
+
+	for _, spec := range specs {
+		job, err := t.find(spec)
+		if err != nil {
+			printError(io, fmt.Errorf("bg: %s", err.Error()))
+			status = STATUS_FAILURE
+			continue
+		}
+
+		if job.state() != JOB_STOPPED {
+			printError(io, fmt.Errorf("bg: job %d already in background", job.id))
+			continue
+		}
+
+		sh.continueJob(job)
+
+		t.seq++
+		job.seq = t.seq
+		job.reported = JOB_RUNNING
+		fmt.Fprintf(io.stdout, "[%d]%c %s &\n", job.id, t.marker(job), job.text)
+	}
+
+	return status
+}
+
+// waitBuiltin wait for the jobs or the programs given, and return the
+// status of the last one. Without arguments, it wait for all the jobs
+// running and succeed. A trapped signal make it return 128 plus the
+// signal number, so its trap is run.
+func (sh *Shell) waitBuiltin(args []string, io streams) int {
+	t := sh.jobs
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(args) == 1 {
+		for slices.ContainsFunc(t.jobs, func(job *Job) bool { return job.state() == JOB_RUNNING }) {
+			if sig, ok := sh.trappedSignal(); ok {
+				return STATUS_SIGNAL + int(sig)
+			}
+			t.cond.Wait()
+		}
+
+		t.jobs = slices.DeleteFunc(t.jobs, func(job *Job) bool { return job.state() == JOB_DONE })
+		return STATUS_SUCCESS
+	}
+
+	status := STATUS_SUCCESS
+
+	for _, arg := range args[1:] {
+		job, err := t.findWaited(arg)
+		if err != nil {
+			printError(io, fmt.Errorf("wait: %s", err.Error()))
+			status = STATUS_NOT_FOUND
+			continue
+		}
+
+		for job.state() == JOB_RUNNING {
+			if sig, ok := sh.trappedSignal(); ok {
+				return STATUS_SIGNAL + int(sig)
+			}
+			t.cond.Wait()
+		}
+
+		status = job.exitStatus()
+		if job.state() == JOB_DONE {
+			t.remove(job)
+		}
+	}
+
+	return status
+}
+
+// findWaited return the job of the job spec or of the
+// process id given to `wait`. t.mu must be held.
+func (t *jobTable) findWaited(arg string) (*Job, error) {
+	if strings.HasPrefix(arg, "%") {
+		return t.find(arg)
+	}
+
+	pid, err := strconv.Atoi(arg)
+	if err != nil {
+		return nil, fmt.Errorf("`%s': not a pid or valid job spec", arg)
+	}
+
+	for _, job := range t.jobs {
+		if job.pid == pid {
+			return job, nil
+		}
+
+		for _, proc := range job.procs {
+			if proc.pid == pid {
+				return job, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("pid %d is not a child of this shell", pid)
+}
+
+// disown remove the jobs from the table, the current one by default,
+// or all of them with -a, or the running ones with -r. With -h,
+// the jobs are kept but are not hung up with the shell.
+func (sh *Shell) disown(args []string, io streams) int {
+	flags, specs, err := parseFlags(args, "ahr")
+	if err != nil {
+		printError(io, fmt.Errorf("disown: %s", err.Error()))
+		return STATUS_MISUSE
+	}
+
+	t := sh.jobs
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var jobs []*Job
+	status := STATUS_SUCCESS
+
+	switch {
+	case len(specs) == 0 && (flags['a'] || flags['r']):
+		for _, job := range t.jobs {
+			if !flags['r'] || job.state() == JOB_RUNNING {
+				jobs = append(jobs, job)
+			}
+		}
+
+	case len(specs) == 0:
+		specs = []string{""}
+	}
+
+	for _, spec := range specs {
+		job, err := t.find(spec)
+		if err != nil {
+			printError(io, fmt.Errorf("disown: %s", err.Error()))
+			status = STATUS_FAILURE
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+
+	for _, job := range jobs {
+		if flags['h'] {
+			job.noHangup = true
+		} else {
+			t.remove(job)
+		}
+	}
+
+	return status
+}
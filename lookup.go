@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/Aboubakary833/cish/parser"
+)
+
+// DEFAULT_PATH is the PATH where `command -p`
+// find the standard utilities
+const DEFAULT_PATH = "/usr/bin:/bin:/usr/sbin:/sbin"
+
+// Kinds of commands, as printed by `type -t`
+const (
+	KIND_KEYWORD  = "keyword"
+	KIND_FUNCTION = "function"
+	KIND_BUILTIN  = "builtin"
+	KIND_FILE     = "file"
+)
+
+// resolved is a command that a name resolve to
+type resolved struct {
+	kind string
+	// path is the path of a file, and hashed is true
+	// when it comes from the hash table
+	path   string
+	hashed bool
+}
+
+// resolve return the commands that the name resolve to, in the order
+// they are searched when the name is run. Only the first is returned
+// unless all is true, in which case all the programs found in PATH
+// are returned too. With noFunctions, the functions are skipped.
+func (sh *Shell) resolve(name string, all, noFunctions bool) []resolved {
+	var commands []resolved
+
+	add := func(command resolved) bool {
+		commands = append(commands, command)
+		return !all
+	}
+
+	if slices.Contains(parser.ReservedWords, name) && add(resolved{kind: KIND_KEYWORD}) {
+		return commands
+	}
+
+	if _, ok := sh.funcs[name]; ok && !noFunctions && add(resolved{kind: KIND_FUNCTION}) {
+		return commands
+	}
+
+	if _, ok := builtins[name]; ok && add(resolved{kind: KIND_BUILTIN}) {
+		return commands
+	}
+
+	if strings.Contains(name, "/") {
+		if path, err := exec.LookPath(sh.path(name)); err == nil {
+			commands = append(commands, resolved{kind: KIND_FILE, path: path})
+		}
+		return commands
+	}
+
+	path := sh.syncHash()
+
+	if entry, ok := sh.hash[name]; ok && !all {
+		return append(commands, resolved{kind: KIND_FILE, path: entry.path, hashed: true})
+	}
+
+	programs, _ := sh.findPrograms(name, path, all)
+	for _, program := range programs {
+		commands = append(commands, resolved{kind: KIND_FILE, path: program})
+	}
+
+	return commands
+}
+
+// describe return the description of a command as printed by `type`
+func (sh *Shell) describe(name string, command resolved) string {
+	switch command.kind {
+	case KIND_KEYWORD:
+		return name + " is a shell keyword"
+	case KIND_FUNCTION:
+		return name + " is a function\n" + parser.Format(sh.funcs[name])
+	case KIND_BUILTIN:
+		return name + " is a shell builtin"
+	case KIND_FILE:
+		if command.hashed {
+			return fmt.Sprintf("%s is hashed (%s)", name, command.path)
+		}
+	}
+
+	return name + " is " + command.path
+}
+
+// typeBuiltin tell how each name would be interpreted as a command.
+// `-t` print a single word, the kind of command, and `-p` the path
+// of the file that would be run. `-a` print all the commands, and
+// all the files of PATH, and not only the first one.
+func (sh *Shell) typeBuiltin(args []string, io streams) int {
+	flags, names, err := parseFlags(args, "atp")
+	if err != nil {
+		printError(io, fmt.Errorf("type: %s", err.Error()))
+		return STATUS_MISUSE
+	}
+
+	status := STATUS_SUCCESS
+
+	for _, name := range names {
+		commands := sh.resolve(name, flags['a'], false)
+
+		if len(commands) == 0 {
+			if !flags['t'] && !flags['p'] {
+				printError(io, fmt.Errorf("type: %s: not found", name))
+			}
+			status = STATUS_FAILURE
+			continue
+		}
+
+		for _, command := range commands {
+			switch {
+			case flags['t']:
+				fmt.Fprintln(io.stdout, command.kind)
+			case flags['p']:
+				if command.kind == KIND_FILE {
+					fmt.Fprintln(io.stdout, command.path)
+				}
+			default:
+				fmt.Fprintln(io.stdout, sh.describe(name, command))
+			}
+		}
+	}
+
+	return status
+}
+
+// command run the builtin or the program named by its first
+// operand, ignoring the functions. `-p` search the program in
+// DEFAULT_PATH. `-v` print the path or the name of the commands,
+// and `-V` describe them like `type`.
+func (sh *Shell) command(args []string, io streams) int {
+	flags, names, err := parseFlags(args, "pvV")
+	if err != nil {
+		printError(io, fmt.Errorf("command: %s", err.Error()))
+		return STATUS_MISUSE
+	}
+
+	if len(names) == 0 {
+		return STATUS_SUCCESS
+	}
+
+	if flags['v'] || flags['V'] {
+		return sh.describeCommands(names, flags['V'], io)
+	}
+
+	if status, ok := sh.runBuiltin(names, io); ok {
+		return status
+	}
+
+	if !flags['p'] || strings.Contains(names[0], "/") {
+		return sh.runProgram(names, io)
+	}
+
+	path, err := sh.findProgram(names[0], DEFAULT_PATH)
+	program, err := sh.startPath(path, err, names, io)
+	if err != nil {
+		return statusFromError(err)
+	}
+
+	return sh.waitProcess(program)
+}
+
+// describeCommands print the path of the files and the name of the
+// other commands, or their description when verbose is true.
+func (sh *Shell) describeCommands(names []string, verbose bool, io streams) int {
+	status := STATUS_SUCCESS
+
+	for _, name := range names {
+		commands := sh.resolve(name, false, false)
+
+		switch {
+		case len(commands) == 0:
+			if verbose {
+				printError(io, fmt.Errorf("command: %s: not found", name))
+			}
+			status = STATUS_FAILURE
+		case verbose:
+			fmt.Fprintln(io.stdout, sh.describe(name, commands[0]))
+		case commands[0].kind == KIND_FILE:
+			fmt.Fprintln(io.stdout, commands[0].path)
+		default:
+			fmt.Fprintln(io.stdout, name)
+		}
+	}
+
+	return status
+}
+
+// builtin run the builtin named by its first operand,
+// even when a function has the same name
+func (sh *Shell) builtin(args []string, io streams) int {
+	if len(args) == 1 {
+		return STATUS_SUCCESS
+	}
+
+	status, ok := sh.runBuiltin(args[1:], io)
+	if !ok {
+		printError(io, fmt.Errorf("builtin: %s: not a shell builtin", args[1]))
+		return STATUS_FAILURE
+	}
+
+	return status
+}
+
+// hashBuiltin search the named programs and remember their path.
+// `-r` forget all of them. Without names, the remembered paths
+// are printed with the number of times they were run.
+func (sh *Shell) hashBuiltin(args []string, io streams) int {
+	flags, names, err := parseFlags(args, "r")
+	if err != nil {
+		printError(io, fmt.Errorf("hash: %s", err.Error()))
+		return STATUS_MISUSE
+	}
+
+	sh.syncHash()
+
+	if flags['r'] {
+		clear(sh.hash)
+	}
+
+	if len(names) == 0 {
+		if !flags['r'] {
+			sh.printHash(io)
+		}
+		return STATUS_SUCCESS
+	}
+
+	status := STATUS_SUCCESS
+
+	for _, name := range names {
+		if strings.Contains(name, "/") {
+			continue
+		}
+
+		delete(sh.hash, name)
+		if _, err := sh.lookPath(name); err != nil {
+			printError(io, fmt.Errorf("hash: %s: not found", name))
+			status = STATUS_FAILURE
+		}
+	}
+
+	return status
+}
+
+// printHash print the hash table sorted by command name
+func (sh *Shell) printHash(io streams) {
+	if len(sh.hash) == 0 {
+		fmt.Fprintln(io.stdout, "hash: hash table empty")
+		return
+	}
+
+	names := make([]string, 0, len(sh.hash))
+	for name := range sh.hash {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	fmt.Fprintln(io.stdout, "hits\tcommand")
+	for _, name := range names {
+		fmt.Fprintf(io.stdout, "%4d\t%s\n", sh.hash[name].hits, sh.hash[name].path)
+	}
+}
@@ -1,9 +1,37 @@
 package main
 
 import (
+	"fmt"
 	"os"
+
+	"golang.org/x/term"
 )
 
+// main run the script or the command string given, or read the
+// commands from the standard input. The line editor is only used
+// when the standard input is a terminal.
 func main() {
-	Repl(os.Stdin)
+	opts, err := parseArgs(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cish: %s\n%s\n", err.Error(), USAGE)
+		os.Exit(STATUS_MISUSE)
+	}
+
+	if opts.stdin && term.IsTerminal(int(os.Stdin.Fd())) {
+		Repl(os.Stdin, opts)
+	}
+
+	sh := newShell(-1, nil)
+	sh.name, sh.params = opts.name, opts.params
+
+	switch {
+	case opts.stdin:
+		sh.input = 's'
+		os.Exit(sh.runSource(byteReader{os.Stdin}))
+	case opts.script != "":
+		os.Exit(sh.runScriptFile(opts.script))
+	}
+
+	sh.input = 'c'
+	os.Exit(sh.runCommandString(opts.command))
 }
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// SPECIAL_PARAMS are the one char parameters which are not names
+const SPECIAL_PARAMS = "?$!#@*-"
+
+// param return the value of a parameter and whether it's set.
+// name is a variable name, a positional parameter number
+// or a special parameter.
+func (sh *Shell) param(name string) (string, bool) {
+	if n, err := strconv.Atoi(name); err == nil {
+		if n == 0 {
+			return sh.name, true
+		}
+
+		if n <= len(sh.params) {
+			return sh.params[n-1], true
+		}
+
+		return "", false
+	}
+
+	switch name {
+	case "?":
+		return sh.status.String(), true
+
+	case "$":
+		return strconv.Itoa(sh.pid), true
+
+	case "!":
+		if sh.lastPid == 0 {
+			return "", false
+		}
+		return strconv.Itoa(sh.lastPid), true
+
+	case "#":
+		return strconv.Itoa(len(sh.params)), true
+
+	case "@", "*":
+		return strings.Join(sh.params, " "), len(sh.params) != 0
+
+	case "-":
+		return sh.flags(), true
+	}
+
+	return sh.vars.Get(name)
+}
+
+// flags return the letters of the enabled options, as `$-` expand to,
+// followed by i for an interactive shell and the input letter
+func (sh *Shell) flags() string {
+	var builder strings.Builder
+
+	for _, letter := range optionLetters {
+		if sh.options[optionByLetter[letter]] {
+			builder.WriteByte(letter)
+		}
+	}
+
+	if sh.interactive {
+		builder.WriteByte('i')
+	}
+
+	if sh.input != 0 {
+		builder.WriteByte(sh.input)
+	}
+
+	return builder.String()
+}
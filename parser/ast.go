@@ -0,0 +1,231 @@
+package parser
+
+import (
+	"github.com/Aboubakary833/cish/scanner"
+)
+
+// Position locate a node in the source
+type Position = scanner.Position
+
+// Heredoc is the content of a here-document
+type Heredoc = scanner.Heredoc
+
+// Node is implemented by all the nodes of the syntax tree
+type Node interface {
+	Pos() Position
+}
+
+// Command is a simple command, a pipeline member
+// or any of the compound commands.
+type Command interface {
+	Node
+	command()
+}
+
+// List is a sequence of AND-OR lists separated by `;`, `&` or newlines
+type List struct {
+	Position
+	Items []*ListItem
+}
+
+// ListItem is an AND-OR list of a List.
+// Async is true when it's terminated by `&`.
+type ListItem struct {
+	Position
+	AndOr *AndOr
+	Async bool
+}
+
+// AndOr is a sequence of pipelines separated by `&&` or `||`.
+// Ops[i] is the operator between Pipelines[i] and Pipelines[i+1].
+type AndOr struct {
+	Position
+	Pipelines []*Pipeline
+	Ops       []string
+}
+
+// Pipeline is a sequence of commands separated by `|`.
+// Bang is true when the pipeline is preceded by `!`.
+type Pipeline struct {
+	Position
+	Bang     bool
+	Commands []Command
+}
+
+// Word is a word of the source as typed,
+// quotes and backslashes included.
+type Word struct {
+	Position
+	Text string
+}
+
+// Assign is a `NAME=value` assignment
+type Assign struct {
+	Position
+	Name  string
+	Value *Word
+}
+
+// Redirect is an I/O redirection like `2>&1` or `<<EOF`.
+// Fd is -1 when no IO_NUMBER precede the operator.
+type Redirect struct {
+	Position
+	Fd      int
+	Op      string
+	Target  *Word
+	Heredoc *Heredoc
+}
+
+// SimpleCommand is a command name with its arguments,
+// assignments and redirections.
+type SimpleCommand struct {
+	Position
+	Assigns   []*Assign
+	Args      []*Word
+	Redirects []*Redirect
+}
+
+// Subshell is a list run in a subshell environment: `( list )`
+type Subshell struct {
+	Position
+	Body      *List
+	Redirects []*Redirect
+}
+
+// BraceGroup is a list run in the current environment: `{ list; }`
+type BraceGroup struct {
+	Position
+	Body      *List
+	Redirects []*Redirect
+}
+
+// IfClause is `if list; then list; [elif list; then list;]... [else list;] fi`.
+// Else is nil without `else` branch.
+type IfClause struct {
+	Position
+	Cond      *List
+	Then      *List
+	Elifs     []*Elif
+	Else      *List
+	Redirects []*Redirect
+}
+
+// Elif is an `elif list; then list` branch of an IfClause
+type Elif struct {
+	Position
+	Cond *List
+	Then *List
+}
+
+// WhileClause is `while list; do list; done`,
+// or `until list; do list; done` when Until is true.
+type WhileClause struct {
+	Position
+	Until     bool
+	Cond      *List
+	Body      *List
+	Redirects []*Redirect
+}
+
+// ForClause is `for name [in word...]; do list; done`.
+// Without `in`, the loop is over the positional parameters.
+type ForClause struct {
+	Position
+	Name      string
+	In        bool
+	Words     []*Word
+	Body      *List
+	Redirects []*Redirect
+}
+
+// CaseClause is `case word in [(]pattern[|pattern]...) list;; ... esac`
+type CaseClause struct {
+	Position
+	Word      *Word
+	Items     []*CaseItem
+	Redirects []*Redirect
+}
+
+// CaseItem is a branch of a CaseClause.
+// Its body is run when the word match one of the patterns.
+type CaseItem struct {
+	Position
+	Patterns []*Word
+	Body     *List
+}
+
+// ArithCommand is `(( expression ))`.
+// Its status is 0 when the expression is not 0.
+type ArithCommand struct {
+	Position
+	Expr      *Word
+	Redirects []*Redirect
+}
+
+// CondCommand is `[[ expression ]]`
+type CondCommand struct {
+	Position
+	Expr      CondExpr
+	Redirects []*Redirect
+}
+
+// CondExpr is an expression of a CondCommand
+type CondExpr interface {
+	Node
+	condExpr()
+}
+
+// CondBinary is `left op right`, where op is `&&`, `||`
+// or a binary test operator like `==` or `-nt`
+type CondBinary struct {
+	Position
+	Op    string
+	Left  CondExpr
+	Right CondExpr
+}
+
+// CondUnary is `op operand`, where op is `!`
+// or a unary test operator like `-f` or `-z`
+type CondUnary struct {
+	Position
+	Op      string
+	Operand CondExpr
+}
+
+// CondParen is `( expression )`
+type CondParen struct {
+	Position
+	Expr CondExpr
+}
+
+// CondWord is an operand of a CondCommand. Alone,
+// it's true when it's not empty.
+type CondWord struct {
+	Position
+	Word *Word
+}
+
+// FunctionDefinition is `name() compound-command` or
+// `function name [()] compound-command`
+type FunctionDefinition struct {
+	Position
+	Name string
+	Body Command
+}
+
+func (*SimpleCommand) command() {}
+func (*Subshell) command()      {}
+func (*BraceGroup) command()    {}
+func (*IfClause) command()      {}
+func (*WhileClause) command()   {}
+func (*ForClause) command()     {}
+func (*CaseClause) command()    {}
+func (*ArithCommand) command()  {}
+func (*CondCommand) command()   {}
+
+func (*FunctionDefinition) command() {}
+
+func (*CondBinary) condExpr() {}
+func (*CondUnary) condExpr()  {}
+func (*CondParen) condExpr()  {}
+func (*CondWord) condExpr()   {}
@@ -0,0 +1,176 @@
+package parser
+
+import (
+	"slices"
+
+	"github.com/Aboubakary833/cish/scanner"
+)
+
+// unaryTestOps are the unary operators of `test` and `[[ ]]`
+var unaryTestOps = []string{"-a", "-b", "-c", "-d", "-e", "-f", "-g", "-h", "-k", "-n", "-o", "-p",
+	"-r", "-s", "-t", "-u", "-v", "-w", "-x", "-z", "-G", "-L", "-N", "-O", "-S"}
+
+// binaryTestOps are the binary operators of `test` and `[[ ]]`
+var binaryTestOps = []string{"=", "==", "!=", "<", ">", "=~", "-eq", "-ne", "-lt", "-le", "-gt", "-ge",
+	"-nt", "-ot", "-ef"}
+
+// IsUnaryTestOp report whether op is a unary test operator, like `-f`
+func IsUnaryTestOp(op string) bool {
+	return slices.Contains(unaryTestOps, op)
+}
+
+// IsBinaryTestOp report whether op is a binary test operator, like `-eq`
+func IsBinaryTestOp(op string) bool {
+	return slices.Contains(binaryTestOps, op)
+}
+
+// condCommand parse `[[ expression ]]` and its redirections.
+// The operands are not split into fields, and `<`, `>`,
+// `(` and `)` are operators of the expression.
+func (p *parser) condCommand() (*CondCommand, error) {
+	cmd := &CondCommand{Position: p.tok.Pos()}
+
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	expr, err := p.condOr()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Expr = expr
+
+	if err := p.skipWord("]]"); err != nil {
+		return nil, err
+	}
+
+	redirects, err := p.redirectList()
+	cmd.Redirects = redirects
+
+	return cmd, err
+}
+
+// condOr parse expressions separated by `||`
+func (p *parser) condOr() (CondExpr, error) {
+	return p.condList(scanner.OR_IF, p.condAnd)
+}
+
+// condAnd parse expressions separated by `&&`
+func (p *parser) condAnd() (CondExpr, error) {
+	return p.condList(scanner.AND_IF, p.condNot)
+}
+
+// condList parse the operands separated by
+// the operator of kind op, which are left associative
+func (p *parser) condList(op scanner.Kind, operand func() (CondExpr, error)) (CondExpr, error) {
+	left, err := operand()
+
+	for err == nil && p.tok.Kind() == op {
+		binary := &CondBinary{Position: p.tok.Pos(), Op: p.tok.Text(), Left: left}
+
+		if err = p.next(); err != nil {
+			break
+		}
+		if err = p.linebreak(); err != nil {
+			break
+		}
+
+		binary.Right, err = operand()
+		left = binary
+	}
+
+	return left, err
+}
+
+// condNot parse `! expression` or a primary expression
+func (p *parser) condNot() (CondExpr, error) {
+	if err := p.linebreak(); err != nil {
+		return nil, err
+	}
+
+	if !p.isWord("!") {
+		return p.condPrimary()
+	}
+
+	not := &CondUnary{Position: p.tok.Pos(), Op: "!"}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	operand, err := p.condNot()
+	not.Operand = operand
+
+	return not, err
+}
+
+// condPrimary parse `( expression )`, a unary test,
+// a binary test or a single word
+func (p *parser) condPrimary() (CondExpr, error) {
+	if p.tok.Kind() == scanner.LPAREN {
+		paren := &CondParen{Position: p.tok.Pos()}
+
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+
+		expr, err := p.condOr()
+		if err != nil {
+			return nil, err
+		}
+		paren.Expr = expr
+
+		if p.tok.Kind() != scanner.RPAREN {
+			return nil, p.unexpected()
+		}
+		return paren, p.next()
+	}
+
+	left, err := p.condWord()
+	if err != nil {
+		return nil, err
+	}
+
+	// `-f file` is a unary test, but `-f` alone is a word
+	if IsUnaryTestOp(left.Word.Text) && p.tok.Kind().IsWord() && !p.isWord("]]") {
+		operand, err := p.condWord()
+		return &CondUnary{Position: left.Pos(), Op: left.Word.Text, Operand: operand}, err
+	}
+
+	isOperator := p.tok.Kind() == scanner.LESS || p.tok.Kind() == scanner.GREAT
+	if !isOperator && !(p.tok.Kind().IsWord() && IsBinaryTestOp(p.tok.Text())) {
+		return left, nil
+	}
+
+	binary := &CondBinary{Position: left.Pos(), Op: p.tok.Text(), Left: left}
+
+	// the regular expression is read as a single word,
+	// in which the parentheses and `|` are not operators
+	if binary.Op == "=~" {
+		tok, err := p.lex.RegexWord()
+		if err != nil {
+			return nil, err
+		}
+		binary.Right = &CondWord{Position: tok.Pos(), Word: &Word{Position: tok.Pos(), Text: tok.Text()}}
+
+		return binary, p.next()
+	}
+
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	binary.Right, err = p.condWord()
+
+	return binary, err
+}
+
+// condWord parse an operand of a conditional expression
+func (p *parser) condWord() (*CondWord, error) {
+	if !p.tok.Kind().IsWord() || p.isWord("]]") {
+		return nil, p.unexpected()
+	}
+
+	word := &Word{Position: p.tok.Pos(), Text: p.tok.Text()}
+
+	return &CondWord{Position: word.Pos(), Word: word}, p.next()
+}
@@ -0,0 +1,14 @@
+package parser
+
+import (
+	"github.com/Aboubakary833/cish/scanner"
+)
+
+// SyntaxError is returned when the source doesn't follow the shell grammar
+type SyntaxError = scanner.SyntaxError
+
+// IsIncomplete report whether err is a syntax error
+// caused by a source ending too early.
+func IsIncomplete(err error) bool {
+	return scanner.IsIncomplete(err)
+}
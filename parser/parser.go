@@ -0,0 +1,761 @@
+package parser
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Aboubakary833/cish/scanner"
+)
+
+// ReservedWords are the words having a special meaning
+// when they are the first word of a command
+var ReservedWords = []string{"!", "{", "}", "[[", "]]", "case", "do", "done", "elif", "else", "esac",
+	"fi", "for", "function", "if", "in", "then", "until", "while"}
+
+// listTerminators are the reserved words that end a list
+var listTerminators = []string{"}", "then", "elif", "else", "fi", "do", "done", "esac"}
+
+type parser struct {
+	lex *scanner.Lexer
+	tok scanner.Token
+}
+
+// Parse build the syntax tree of the source
+// following the POSIX Shell Command Language grammar.
+func Parse(src string) (*List, error) {
+	return parse(scanner.NewLexer(src))
+}
+
+// ParseEOF build the syntax tree of a source which no input follow,
+// like the end of a script, so the end of the source also end the
+// here-documents. The delimiters of the here-documents ended this
+// way are returned with the tree.
+func ParseEOF(src string) (*List, []string, error) {
+	lex := scanner.NewLexer(src)
+	lex.EndAtEOF()
+
+	list, err := parse(lex)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var delimiters []string
+	for _, heredoc := range lex.Unterminated() {
+		delimiters = append(delimiters, heredoc.Delimiter)
+	}
+
+	return list, delimiters, nil
+}
+
+func parse(lex *scanner.Lexer) (*List, error) {
+	p := &parser{lex: lex}
+
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	list, err := p.list()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.tok.Kind() != scanner.END_OF_FILE {
+		return nil, p.unexpected()
+	}
+
+	return list, nil
+}
+
+// next move to the next token
+func (p *parser) next() (err error) {
+	p.tok, err = p.lex.Next()
+
+	return
+}
+
+// isWord report whether the current token is the unquoted word text
+func (p *parser) isWord(text string) bool {
+	return p.tok.Kind().IsWord() && p.tok.Text() == text
+}
+
+// isRedirect report whether a redirection start at the current token
+func (p *parser) isRedirect() bool {
+	return p.tok.Kind() == scanner.IO_NUMBER || p.tok.Kind().IsRedirect()
+}
+
+// atListEnd report whether the current token can't start a command
+// because it close the enclosing construct.
+func (p *parser) atListEnd() bool {
+	switch p.tok.Kind() {
+	case scanner.END_OF_FILE, scanner.RPAREN, scanner.DSEMI:
+		return true
+
+	case scanner.WORD, scanner.NAME:
+		return slices.Contains(listTerminators, p.tok.Text())
+	}
+
+	return false
+}
+
+// unexpected return the syntax error for the current token
+func (p *parser) unexpected() error {
+	switch p.tok.Kind() {
+	case scanner.END_OF_FILE:
+		return scanner.NewSyntaxError(p.tok.Pos(), true, "unexpected end of file")
+
+	case scanner.NEWLINE:
+		return scanner.NewSyntaxError(p.tok.Pos(), false, "unexpected token `newline'")
+	}
+
+	return scanner.NewSyntaxError(p.tok.Pos(), false, "unexpected token `%s'", p.tok.Text())
+}
+
+// linebreak skip the newline tokens
+func (p *parser) linebreak() error {
+	for p.tok.Kind() == scanner.NEWLINE {
+		if err := p.next(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// list parse AND-OR lists until a token closing the list
+func (p *parser) list() (*List, error) {
+	list := &List{Position: p.tok.Pos()}
+
+	if err := p.linebreak(); err != nil {
+		return nil, err
+	}
+
+	for !p.atListEnd() {
+		item := &ListItem{Position: p.tok.Pos()}
+		andOr, err := p.andOr()
+		if err != nil {
+			return nil, err
+		}
+		item.AndOr = andOr
+		list.Items = append(list.Items, item)
+
+		switch p.tok.Kind() {
+		case scanner.SEMI, scanner.AMP:
+			item.Async = p.tok.Kind() == scanner.AMP
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+
+		case scanner.NEWLINE:
+
+		default:
+			if !p.atListEnd() {
+				return nil, p.unexpected()
+			}
+		}
+
+		if err := p.linebreak(); err != nil {
+			return nil, err
+		}
+	}
+
+	return list, nil
+}
+
+// andOr parse pipelines separated by `&&` or `||`
+func (p *parser) andOr() (*AndOr, error) {
+	andOr := &AndOr{Position: p.tok.Pos()}
+
+	pipeline, err := p.pipeline()
+	if err != nil {
+		return nil, err
+	}
+	andOr.Pipelines = append(andOr.Pipelines, pipeline)
+
+	for p.tok.Kind() == scanner.AND_IF || p.tok.Kind() == scanner.OR_IF {
+		andOr.Ops = append(andOr.Ops, p.tok.Text())
+
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if err := p.linebreak(); err != nil {
+			return nil, err
+		}
+
+		if pipeline, err = p.pipeline(); err != nil {
+			return nil, err
+		}
+		andOr.Pipelines = append(andOr.Pipelines, pipeline)
+	}
+
+	return andOr, nil
+}
+
+// pipeline parse commands separated by `|`
+func (p *parser) pipeline() (*Pipeline, error) {
+	pipeline := &Pipeline{Position: p.tok.Pos()}
+
+	if p.isWord("!") {
+		pipeline.Bang = true
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	cmd, err := p.command()
+	if err != nil {
+		return nil, err
+	}
+	pipeline.Commands = append(pipeline.Commands, cmd)
+
+	for p.tok.Kind() == scanner.PIPE {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if err := p.linebreak(); err != nil {
+			return nil, err
+		}
+
+		if cmd, err = p.command(); err != nil {
+			return nil, err
+		}
+		pipeline.Commands = append(pipeline.Commands, cmd)
+	}
+
+	return pipeline, nil
+}
+
+// command parse a simple or a compound command
+func (p *parser) command() (Command, error) {
+	switch {
+	case p.tok.Kind() == scanner.LPAREN:
+		if tok, ok := p.lex.ArithmeticCommand(); ok {
+			return p.arithCommand(tok)
+		}
+		return p.subshell()
+
+	case p.isWord("{"):
+		return p.braceGroup()
+
+	case p.isWord("if"):
+		return p.ifClause()
+
+	case p.isWord("while"), p.isWord("until"):
+		return p.whileClause()
+
+	case p.isWord("for"):
+		return p.forClause()
+
+	case p.isWord("case"):
+		return p.caseClause()
+
+	case p.isWord("function"):
+		return p.function()
+
+	case p.isWord("[["):
+		return p.condCommand()
+	}
+
+	cmd, err := p.simpleCommand()
+	if err != nil {
+		return nil, err
+	}
+
+	// `name()` start a function definition
+	if p.tok.Kind() == scanner.LPAREN && len(cmd.Args) == 1 && len(cmd.Assigns) == 0 && len(cmd.Redirects) == 0 {
+		return p.functionDefinition(cmd.Args[0])
+	}
+
+	return cmd, nil
+}
+
+// function parse `function name [()] compound-command`
+func (p *parser) function() (*FunctionDefinition, error) {
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	if !p.tok.Kind().IsWord() {
+		return nil, p.unexpected()
+	}
+	name := &Word{Position: p.tok.Pos(), Text: p.tok.Text()}
+
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	return p.functionDefinition(name)
+}
+
+// functionDefinition parse the `()` following the name of
+// a function, which is optional after `function name`,
+// then its body, a compound command.
+func (p *parser) functionDefinition(name *Word) (*FunctionDefinition, error) {
+	if strings.ContainsAny(name.Text, "'\"\\$`=") {
+		return nil, scanner.NewSyntaxError(name.Pos(), false, "`%s': not a valid identifier", name.Text)
+	}
+
+	definition := &FunctionDefinition{Position: name.Pos(), Name: name.Text}
+
+	if p.tok.Kind() == scanner.LPAREN {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if p.tok.Kind() != scanner.RPAREN {
+			return nil, p.unexpected()
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.linebreak(); err != nil {
+		return nil, err
+	}
+
+	if !p.isCompoundStart() {
+		return nil, p.unexpected()
+	}
+
+	body, err := p.command()
+	definition.Body = body
+
+	return definition, err
+}
+
+// isCompoundStart report whether a compound command start at the current token
+func (p *parser) isCompoundStart() bool {
+	if p.tok.Kind() == scanner.LPAREN {
+		return true
+	}
+
+	return slices.ContainsFunc([]string{"{", "if", "while", "until", "for", "case", "[["}, p.isWord)
+}
+
+// subshell parse `( list )` and its redirections
+func (p *parser) subshell() (*Subshell, error) {
+	subshell := &Subshell{Position: p.tok.Pos()}
+
+	body, err := p.compoundList(func() bool { return p.tok.Kind() == scanner.RPAREN })
+	if err != nil {
+		return nil, err
+	}
+	subshell.Body = body
+
+	subshell.Redirects, err = p.redirectList()
+
+	return subshell, err
+}
+
+// arithCommand parse the redirections following the
+// expression of a `(( expression ))` command
+func (p *parser) arithCommand(expr scanner.Token) (*ArithCommand, error) {
+	cmd := &ArithCommand{
+		Position: p.tok.Pos(),
+		Expr:     &Word{Position: expr.Pos(), Text: expr.Text()},
+	}
+
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	redirects, err := p.redirectList()
+	cmd.Redirects = redirects
+
+	return cmd, err
+}
+
+// braceGroup parse `{ list; }` and its redirections
+func (p *parser) braceGroup() (*BraceGroup, error) {
+	group := &BraceGroup{Position: p.tok.Pos()}
+
+	body, err := p.compoundList(func() bool { return p.isWord("}") })
+	if err != nil {
+		return nil, err
+	}
+	group.Body = body
+
+	group.Redirects, err = p.redirectList()
+
+	return group, err
+}
+
+// compoundList skip the opening token and parse the list
+// until the closing token, which is skipped too.
+// The list must not be empty.
+func (p *parser) compoundList(isClosing func() bool) (*List, error) {
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	list, err := p.list()
+	if err != nil {
+		return nil, err
+	}
+
+	if !isClosing() || len(list.Items) == 0 {
+		return nil, p.unexpected()
+	}
+
+	return list, p.next()
+}
+
+// listUntil parse the list until one of the closing words,
+// which is not skipped. The list must not be empty.
+func (p *parser) listUntil(closing ...string) (*List, error) {
+	list, err := p.list()
+	if err != nil {
+		return nil, err
+	}
+
+	if !slices.ContainsFunc(closing, p.isWord) || len(list.Items) == 0 {
+		return nil, p.unexpected()
+	}
+
+	return list, nil
+}
+
+// skipWord skip the current token, which must be the word text
+func (p *parser) skipWord(text string) error {
+	if !p.isWord(text) {
+		return p.unexpected()
+	}
+
+	return p.next()
+}
+
+// ifClause parse `if list then list [elif list then list]... [else list] fi`
+// and its redirections
+func (p *parser) ifClause() (*IfClause, error) {
+	clause := &IfClause{Position: p.tok.Pos()}
+	var err error
+
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	if clause.Cond, err = p.listUntil("then"); err != nil {
+		return nil, err
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if clause.Then, err = p.listUntil("elif", "else", "fi"); err != nil {
+		return nil, err
+	}
+
+	for p.isWord("elif") {
+		elif := &Elif{Position: p.tok.Pos()}
+
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if elif.Cond, err = p.listUntil("then"); err != nil {
+			return nil, err
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if elif.Then, err = p.listUntil("elif", "else", "fi"); err != nil {
+			return nil, err
+		}
+		clause.Elifs = append(clause.Elifs, elif)
+	}
+
+	if p.isWord("else") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if clause.Else, err = p.listUntil("fi"); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	clause.Redirects, err = p.redirectList()
+
+	return clause, err
+}
+
+// whileClause parse `while list do list done` or `until list do list done`
+// and its redirections
+func (p *parser) whileClause() (*WhileClause, error) {
+	clause := &WhileClause{Position: p.tok.Pos(), Until: p.isWord("until")}
+	var err error
+
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	if clause.Cond, err = p.listUntil("do"); err != nil {
+		return nil, err
+	}
+	if clause.Body, err = p.doGroup(); err != nil {
+		return nil, err
+	}
+
+	clause.Redirects, err = p.redirectList()
+
+	return clause, err
+}
+
+// forClause parse `for name [in word...]; do list done` and its redirections
+func (p *parser) forClause() (*ForClause, error) {
+	clause := &ForClause{Position: p.tok.Pos()}
+	var err error
+
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	if p.tok.Kind() != scanner.NAME {
+		return nil, p.unexpected()
+	}
+	clause.Name = p.tok.Text()
+
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if err := p.linebreak(); err != nil {
+		return nil, err
+	}
+
+	if p.isWord("in") {
+		clause.In = true
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+
+		for p.tok.Kind().IsWord() {
+			clause.Words = append(clause.Words, &Word{Position: p.tok.Pos(), Text: p.tok.Text()})
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+
+		if p.tok.Kind() != scanner.SEMI && p.tok.Kind() != scanner.NEWLINE {
+			return nil, p.unexpected()
+		}
+	}
+
+	if p.tok.Kind() == scanner.SEMI {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.linebreak(); err != nil {
+		return nil, err
+	}
+
+	if clause.Body, err = p.doGroup(); err != nil {
+		return nil, err
+	}
+
+	clause.Redirects, err = p.redirectList()
+
+	return clause, err
+}
+
+// doGroup parse `do list done`
+func (p *parser) doGroup() (*List, error) {
+	if !p.isWord("do") {
+		return nil, p.unexpected()
+	}
+
+	return p.compoundList(func() bool { return p.isWord("done") })
+}
+
+// caseClause parse `case word in [[(]pattern[|pattern]...) list ;;]... esac`
+// and its redirections. The `;;` of the last item is optional.
+func (p *parser) caseClause() (*CaseClause, error) {
+	clause := &CaseClause{Position: p.tok.Pos()}
+
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	if !p.tok.Kind().IsWord() {
+		return nil, p.unexpected()
+	}
+	clause.Word = &Word{Position: p.tok.Pos(), Text: p.tok.Text()}
+
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if err := p.linebreak(); err != nil {
+		return nil, err
+	}
+	if err := p.skipWord("in"); err != nil {
+		return nil, err
+	}
+	if err := p.linebreak(); err != nil {
+		return nil, err
+	}
+
+	for !p.isWord("esac") {
+		item, err := p.caseItem()
+		if err != nil {
+			return nil, err
+		}
+		clause.Items = append(clause.Items, item)
+
+		if p.tok.Kind() != scanner.DSEMI {
+			break
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if err := p.linebreak(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := p.skipWord("esac"); err != nil {
+		return nil, err
+	}
+
+	redirects, err := p.redirectList()
+	clause.Redirects = redirects
+
+	return clause, err
+}
+
+// caseItem parse the patterns and the list of a case item
+func (p *parser) caseItem() (*CaseItem, error) {
+	item := &CaseItem{Position: p.tok.Pos()}
+
+	if p.tok.Kind() == scanner.LPAREN {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		if !p.tok.Kind().IsWord() {
+			return nil, p.unexpected()
+		}
+		item.Patterns = append(item.Patterns, &Word{Position: p.tok.Pos(), Text: p.tok.Text()})
+
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if p.tok.Kind() != scanner.PIPE {
+			break
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.tok.Kind() != scanner.RPAREN {
+		return nil, p.unexpected()
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	body, err := p.list()
+	item.Body = body
+
+	return item, err
+}
+
+// simpleCommand parse the assignments, words and
+// redirections of a simple command.
+func (p *parser) simpleCommand() (*SimpleCommand, error) {
+	cmd := &SimpleCommand{Position: p.tok.Pos()}
+
+	for {
+		switch {
+		case p.isRedirect():
+			redirect, err := p.redirect()
+			if err != nil {
+				return nil, err
+			}
+			cmd.Redirects = append(cmd.Redirects, redirect)
+			continue
+
+		case p.tok.Kind().IsWord():
+			if p.tok.Kind() == scanner.ASSIGNMENT_WORD && len(cmd.Args) == 0 {
+				cmd.Assigns = append(cmd.Assigns, newAssign(p.tok))
+			} else {
+				cmd.Args = append(cmd.Args, &Word{Position: p.tok.Pos(), Text: p.tok.Text()})
+			}
+
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		break
+	}
+
+	if len(cmd.Assigns) == 0 && len(cmd.Args) == 0 && len(cmd.Redirects) == 0 {
+		return nil, p.unexpected()
+	}
+
+	return cmd, nil
+}
+
+// redirectList parse the redirections following a compound command
+func (p *parser) redirectList() (redirects []*Redirect, err error) {
+	for p.isRedirect() {
+		redirect, err := p.redirect()
+		if err != nil {
+			return nil, err
+		}
+		redirects = append(redirects, redirect)
+	}
+
+	return
+}
+
+// redirect parse an optional IO_NUMBER,
+// a redirection operator and its target word.
+func (p *parser) redirect() (*Redirect, error) {
+	redirect := &Redirect{Position: p.tok.Pos(), Fd: -1}
+
+	if p.tok.Kind() == scanner.IO_NUMBER {
+		fd, err := strconv.Atoi(p.tok.Text())
+		if err != nil {
+			return nil, scanner.NewSyntaxError(p.tok.Pos(), false, "bad file descriptor `%s'", p.tok.Text())
+		}
+		redirect.Fd = fd
+
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	redirect.Op = p.tok.Text()
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	if !p.tok.Kind().IsWord() {
+		return nil, p.unexpected()
+	}
+
+	redirect.Target = &Word{Position: p.tok.Pos(), Text: p.tok.Text()}
+	redirect.Heredoc = p.tok.Heredoc()
+
+	return redirect, p.next()
+}
+
+// newAssign return the assignment of an ASSIGNMENT_WORD token
+func newAssign(tok scanner.Token) *Assign {
+	name, value, _ := strings.Cut(tok.Text(), "=")
+
+	valuePos := tok.Pos()
+	valuePos.Column += len(name) + 1
+
+	return &Assign{
+		Position: tok.Pos(),
+		Name:     name,
+		Value:    &Word{Position: valuePos, Text: value},
+	}
+}
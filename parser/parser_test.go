@@ -0,0 +1,296 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func argsText(cmd Command) (texts []string) {
+	for _, word := range cmd.(*SimpleCommand).Args {
+		texts = append(texts, word.Text)
+	}
+
+	return
+}
+
+func TestParseSimpleCommand(t *testing.T) {
+	t.Run("it should parse the words of the command", func(t *testing.T) {
+		list, err := Parse("echo 'Hello, world' \"a b\"\n")
+		require.NoError(t, err)
+		require.Len(t, list.Items, 1)
+
+		cmd := list.Items[0].AndOr.Pipelines[0].Commands[0]
+		assert.Equal(t, []string{"echo", "'Hello, world'", "\"a b\""}, argsText(cmd))
+	})
+
+	t.Run("it should parse the assignments and redirections", func(t *testing.T) {
+		list, err := Parse("FOO=bar 2>&1 env >out x=y")
+		require.NoError(t, err)
+
+		cmd := list.Items[0].AndOr.Pipelines[0].Commands[0].(*SimpleCommand)
+		require.Len(t, cmd.Assigns, 1)
+		assert.Equal(t, "FOO", cmd.Assigns[0].Name)
+		assert.Equal(t, "bar", cmd.Assigns[0].Value.Text)
+		assert.Equal(t, []string{"env", "x=y"}, argsText(cmd))
+
+		require.Len(t, cmd.Redirects, 2)
+		assert.Equal(t, 2, cmd.Redirects[0].Fd)
+		assert.Equal(t, ">&", cmd.Redirects[0].Op)
+		assert.Equal(t, "1", cmd.Redirects[0].Target.Text)
+		assert.Equal(t, -1, cmd.Redirects[1].Fd)
+		assert.Equal(t, ">", cmd.Redirects[1].Op)
+	})
+
+	t.Run("it should skip the comments", func(t *testing.T) {
+		list, err := Parse("ls -l # list the files\n")
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"ls", "-l"}, argsText(list.Items[0].AndOr.Pipelines[0].Commands[0]))
+	})
+}
+
+func TestParseLists(t *testing.T) {
+	list, err := Parse("a && b || ! c | d; e &\nf")
+	require.NoError(t, err)
+	require.Len(t, list.Items, 3)
+
+	andOr := list.Items[0].AndOr
+	assert.Equal(t, []string{"&&", "||"}, andOr.Ops)
+	assert.True(t, andOr.Pipelines[2].Bang)
+	assert.Len(t, andOr.Pipelines[2].Commands, 2)
+
+	assert.False(t, list.Items[0].Async)
+	assert.True(t, list.Items[1].Async)
+	assert.Equal(t, Position{Line: 2, Column: 1}, list.Items[2].Pos())
+}
+
+func TestParseCompoundCommands(t *testing.T) {
+	t.Run("it should parse a subshell", func(t *testing.T) {
+		list, err := Parse("(cd /tmp; ls) > files")
+		require.NoError(t, err)
+
+		subshell := list.Items[0].AndOr.Pipelines[0].Commands[0].(*Subshell)
+		assert.Len(t, subshell.Body.Items, 2)
+		assert.Len(t, subshell.Redirects, 1)
+	})
+
+	t.Run("it should parse a brace group", func(t *testing.T) {
+		list, err := Parse("{ echo a\n echo } ; }")
+		require.NoError(t, err)
+
+		group := list.Items[0].AndOr.Pipelines[0].Commands[0].(*BraceGroup)
+		require.Len(t, group.Body.Items, 2)
+		assert.Equal(t, []string{"echo", "}"}, argsText(group.Body.Items[1].AndOr.Pipelines[0].Commands[0]))
+	})
+
+	t.Run("it should parse an if clause", func(t *testing.T) {
+		list, err := Parse("if a; then b\nelif c\nthen d; elif e; then f; else g; h; fi >out")
+		require.NoError(t, err)
+
+		clause := list.Items[0].AndOr.Pipelines[0].Commands[0].(*IfClause)
+		assert.Len(t, clause.Cond.Items, 1)
+		assert.Len(t, clause.Then.Items, 1)
+		require.Len(t, clause.Elifs, 2)
+		assert.Equal(t, []string{"c"}, argsText(clause.Elifs[0].Cond.Items[0].AndOr.Pipelines[0].Commands[0]))
+		assert.Len(t, clause.Else.Items, 2)
+		assert.Len(t, clause.Redirects, 1)
+	})
+
+	t.Run("it should parse the while and until loops", func(t *testing.T) {
+		list, err := Parse("while a; do b; done; until c\ndo d; done")
+		require.NoError(t, err)
+
+		while := list.Items[0].AndOr.Pipelines[0].Commands[0].(*WhileClause)
+		assert.False(t, while.Until)
+		until := list.Items[1].AndOr.Pipelines[0].Commands[0].(*WhileClause)
+		assert.True(t, until.Until)
+		assert.Equal(t, []string{"d"}, argsText(until.Body.Items[0].AndOr.Pipelines[0].Commands[0]))
+	})
+
+	t.Run("it should parse the for loops", func(t *testing.T) {
+		list, err := Parse("for x in a \"b c\"; do echo $x; done; for y\ndo echo; done")
+		require.NoError(t, err)
+
+		clause := list.Items[0].AndOr.Pipelines[0].Commands[0].(*ForClause)
+		assert.Equal(t, "x", clause.Name)
+		assert.True(t, clause.In)
+		require.Len(t, clause.Words, 2)
+		assert.Equal(t, "\"b c\"", clause.Words[1].Text)
+
+		clause = list.Items[1].AndOr.Pipelines[0].Commands[0].(*ForClause)
+		assert.False(t, clause.In)
+		assert.Empty(t, clause.Words)
+	})
+
+	t.Run("it should parse a case clause", func(t *testing.T) {
+		list, err := Parse("case $x in\n(a|b) echo ab;;\n*.go) ;;\nc) echo c\nesac")
+		require.NoError(t, err)
+
+		clause := list.Items[0].AndOr.Pipelines[0].Commands[0].(*CaseClause)
+		assert.Equal(t, "$x", clause.Word.Text)
+		require.Len(t, clause.Items, 3)
+		assert.Len(t, clause.Items[0].Patterns, 2)
+		assert.Empty(t, clause.Items[1].Body.Items)
+		assert.Len(t, clause.Items[2].Body.Items, 1)
+	})
+
+	t.Run("it should parse the function definitions", func(t *testing.T) {
+		list, err := Parse("greet() { echo hi; }\nfunction bye\n{ echo bye; } >&2; function up() (cd ..)")
+		require.NoError(t, err)
+		require.Len(t, list.Items, 3)
+
+		greet := list.Items[0].AndOr.Pipelines[0].Commands[0].(*FunctionDefinition)
+		assert.Equal(t, "greet", greet.Name)
+		assert.IsType(t, &BraceGroup{}, greet.Body)
+
+		bye := list.Items[1].AndOr.Pipelines[0].Commands[0].(*FunctionDefinition)
+		assert.Equal(t, "bye", bye.Name)
+		assert.Len(t, bye.Body.(*BraceGroup).Redirects, 1)
+
+		up := list.Items[2].AndOr.Pipelines[0].Commands[0].(*FunctionDefinition)
+		assert.IsType(t, &Subshell{}, up.Body)
+	})
+
+	t.Run("it should only recognize the reserved words as command names", func(t *testing.T) {
+		list, err := Parse("echo if then fi")
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"echo", "if", "then", "fi"}, argsText(list.Items[0].AndOr.Pipelines[0].Commands[0]))
+	})
+}
+
+func TestParseArithCommand(t *testing.T) {
+	t.Run("it should read the expression as is", func(t *testing.T) {
+		list, err := Parse("(( x = 1 << 2, y > 3 )) >out; echo $((1 + (2)))")
+		require.NoError(t, err)
+
+		cmd := list.Items[0].AndOr.Pipelines[0].Commands[0].(*ArithCommand)
+		assert.Equal(t, " x = 1 << 2, y > 3 ", cmd.Expr.Text)
+		assert.Equal(t, Position{Line: 1, Column: 3}, cmd.Expr.Pos())
+		assert.Len(t, cmd.Redirects, 1)
+
+		assert.Equal(t, []string{"echo", "$((1 + (2)))"}, argsText(list.Items[1].AndOr.Pipelines[0].Commands[0]))
+	})
+
+	t.Run("it should parse nested subshells", func(t *testing.T) {
+		list, err := Parse("((echo a) | (echo b))")
+		require.NoError(t, err)
+
+		assert.IsType(t, &Subshell{}, list.Items[0].AndOr.Pipelines[0].Commands[0])
+	})
+}
+
+func TestParseCondCommand(t *testing.T) {
+	t.Run("it should parse the conditional expressions", func(t *testing.T) {
+		list, err := Parse("[[ ! -f $x && ( a < b || $y == *.go ) ]] >out")
+		require.NoError(t, err)
+
+		cmd := list.Items[0].AndOr.Pipelines[0].Commands[0].(*CondCommand)
+		and := cmd.Expr.(*CondBinary)
+		assert.Equal(t, "&&", and.Op)
+		assert.Len(t, cmd.Redirects, 1)
+
+		not := and.Left.(*CondUnary)
+		assert.Equal(t, "!", not.Op)
+		assert.Equal(t, "-f", not.Operand.(*CondUnary).Op)
+
+		or := and.Right.(*CondParen).Expr.(*CondBinary)
+		assert.Equal(t, "<", or.Left.(*CondBinary).Op)
+		assert.Equal(t, "*.go", or.Right.(*CondBinary).Right.(*CondWord).Word.Text)
+	})
+
+	t.Run("it should read the regular expressions as a single word", func(t *testing.T) {
+		list, err := Parse("[[ $x =~ ^(a|b c)+$ ]]")
+		require.NoError(t, err)
+
+		cmd := list.Items[0].AndOr.Pipelines[0].Commands[0].(*CondCommand)
+		assert.Equal(t, "^(a|b c)+$", cmd.Expr.(*CondBinary).Right.(*CondWord).Word.Text)
+	})
+
+	t.Run("it should read -f alone as a word", func(t *testing.T) {
+		list, err := Parse("[[ -f ]]")
+		require.NoError(t, err)
+
+		assert.Equal(t, "-f", list.Items[0].AndOr.Pipelines[0].Commands[0].(*CondCommand).Expr.(*CondWord).Word.Text)
+	})
+}
+
+func TestParseHeredoc(t *testing.T) {
+	list, err := Parse("cat <<EOF; cat <<-'END'\nHello $USER\nEOF\n\t\tbye\n\tEND\n")
+	require.NoError(t, err)
+	require.Len(t, list.Items, 2)
+
+	first := list.Items[0].AndOr.Pipelines[0].Commands[0].(*SimpleCommand).Redirects[0].Heredoc
+	assert.Equal(t, "Hello $USER\n", first.Body)
+	assert.False(t, first.Quoted)
+
+	second := list.Items[1].AndOr.Pipelines[0].Commands[0].(*SimpleCommand).Redirects[0].Heredoc
+	assert.Equal(t, "END", second.Delimiter)
+	assert.Equal(t, "bye\n", second.Body)
+	assert.True(t, second.Quoted)
+	assert.True(t, second.StripTabs)
+}
+
+func TestParseEOF(t *testing.T) {
+	list, unterminated, err := ParseEOF("cat <<A; cat <<B\nabc\nA\ndef")
+	require.NoError(t, err)
+	require.Len(t, list.Items, 2)
+
+	first := list.Items[0].AndOr.Pipelines[0].Commands[0].(*SimpleCommand).Redirects[0].Heredoc
+	assert.Equal(t, "abc\n", first.Body)
+
+	second := list.Items[1].AndOr.Pipelines[0].Commands[0].(*SimpleCommand).Redirects[0].Heredoc
+	assert.Equal(t, "def\n", second.Body)
+	assert.Equal(t, []string{"B"}, unterminated)
+
+	list, unterminated, err = ParseEOF("cat <<EOF")
+	require.NoError(t, err)
+	assert.Equal(t, "", list.Items[0].AndOr.Pipelines[0].Commands[0].(*SimpleCommand).Redirects[0].Heredoc.Body)
+	assert.Equal(t, []string{"EOF"}, unterminated)
+
+	_, _, err = ParseEOF("cat <<EOF; (\n")
+	assert.EqualError(t, err, "syntax error at line 2, column 1: unexpected end of file")
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src        string
+		msg        string
+		incomplete bool
+	}{
+		{"ls |", "syntax error at line 1, column 5: unexpected end of file", true},
+		{"echo 'abc", "syntax error at line 1, column 6: unexpected end of file while looking for matching `''", true},
+		{"(ls", "syntax error at line 1, column 4: unexpected end of file", true},
+		{"cat <<EOF\nabc\n", "syntax error at line 3, column 1: here-document delimited by \"EOF\" is not terminated", true},
+		{"ls\n&& pwd", "syntax error at line 2, column 1: unexpected token `&&'", false},
+		{"echo a ;; b", "syntax error at line 1, column 8: unexpected token `;;'", false},
+		{"( )", "syntax error at line 1, column 3: unexpected token `)'", false},
+		{"{ ls }", "syntax error at line 1, column 7: unexpected end of file", true},
+		{"ls >", "syntax error at line 1, column 5: unexpected end of file", true},
+		{"ls > ;", "syntax error at line 1, column 6: unexpected token `;'", false},
+		{"if true; then", "syntax error at line 1, column 14: unexpected end of file", true},
+		{"if true; fi", "syntax error at line 1, column 10: unexpected token `fi'", false},
+		{"while true; do\n", "syntax error at line 2, column 1: unexpected end of file", true},
+		{"for 1 in a; do b; done", "syntax error at line 1, column 5: unexpected token `1'", false},
+		{"case a in b) c;; d", "syntax error at line 1, column 19: unexpected end of file", true},
+		{"then", "syntax error at line 1, column 1: unexpected token `then'", false},
+		{"f() echo", "syntax error at line 1, column 5: unexpected token `echo'", false},
+		{"f() {", "syntax error at line 1, column 6: unexpected end of file", true},
+		{"((1 + 2", "syntax error at line 1, column 8: unexpected end of file", true},
+		{"[[ a b ]]", "syntax error at line 1, column 6: unexpected token `b'", false},
+		{"[[ -n a", "syntax error at line 1, column 8: unexpected end of file", true},
+		{"'f'() { :; }", "syntax error at line 1, column 1: `'f'': not a valid identifier", false},
+		{"echo a \\\n", "syntax error at line 2, column 1: unexpected end of file after an escaped newline", true},
+		{"echo a\\\n", "syntax error at line 2, column 1: unexpected end of file after an escaped newline", true},
+	}
+
+	for _, test := range tests {
+		_, err := Parse(test.src)
+
+		require.Error(t, err, test.src)
+		assert.Equal(t, test.msg, err.Error(), test.src)
+		assert.Equal(t, test.incomplete, IsIncomplete(err), test.src)
+	}
+}
@@ -0,0 +1,283 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+)
+
+// INDENT is the indentation of the nested lists in the formatted source
+const INDENT = "    "
+
+// Format return the source of the command, with
+// one command per line in the compound commands.
+func Format(cmd Command) string {
+	p := &printer{}
+	p.command(cmd)
+
+	if len(p.heredocs) != 0 {
+		p.newline()
+	}
+
+	return strings.TrimSuffix(p.String(), "\n")
+}
+
+// FormatAndOr return the source of the AND-OR list,
+// as the job it's run by is shown.
+func FormatAndOr(andOr *AndOr) string {
+	p := &printer{}
+	p.andOr(andOr)
+
+	return p.String()
+}
+
+type printer struct {
+	strings.Builder
+	depth int
+	// heredocs are the here-documents whose body
+	// is written after the current line
+	heredocs []*Heredoc
+}
+
+// newline end the current line and indent the next one
+func (p *printer) newline() {
+	p.WriteByte('\n')
+
+	for _, heredoc := range p.heredocs {
+		p.WriteString(heredoc.Body)
+		p.WriteString(heredoc.Delimiter + "\n")
+	}
+	p.heredocs = nil
+
+	p.WriteString(strings.Repeat(INDENT, p.depth))
+}
+
+// block write the items of the list on their own lines, indented
+func (p *printer) block(list *List) {
+	p.depth++
+	p.items(list)
+	p.depth--
+	p.newline()
+}
+
+func (p *printer) items(list *List) {
+	for _, item := range list.Items {
+		p.newline()
+		p.andOr(item.AndOr)
+
+		if item.Async {
+			p.WriteString(" &")
+		}
+	}
+}
+
+// inline write the items of the list on the current line
+func (p *printer) inline(list *List) {
+	for i, item := range list.Items {
+		p.andOr(item.AndOr)
+
+		switch {
+		case item.Async:
+			p.WriteString(" &")
+			if i != len(list.Items)-1 {
+				p.WriteByte(' ')
+			}
+		case i != len(list.Items)-1:
+			p.WriteString("; ")
+		}
+	}
+}
+
+func (p *printer) andOr(andOr *AndOr) {
+	for i, pipeline := range andOr.Pipelines {
+		if i != 0 {
+			p.WriteString(" " + andOr.Ops[i-1] + " ")
+		}
+
+		if pipeline.Bang {
+			p.WriteString("! ")
+		}
+
+		for j, cmd := range pipeline.Commands {
+			if j != 0 {
+				p.WriteString(" | ")
+			}
+			p.command(cmd)
+		}
+	}
+}
+
+func (p *printer) command(cmd Command) {
+	switch cmd := cmd.(type) {
+	case *SimpleCommand:
+		var words []string
+		for _, assign := range cmd.Assigns {
+			words = append(words, assign.Name+"="+assign.Value.Text)
+		}
+		for _, arg := range cmd.Args {
+			words = append(words, arg.Text)
+		}
+		for _, redirect := range cmd.Redirects {
+			words = append(words, p.redirect(redirect))
+		}
+		p.WriteString(strings.Join(words, " "))
+		return
+
+	case *Subshell:
+		p.WriteString("(")
+		p.block(cmd.Body)
+		p.WriteString(")")
+
+	case *BraceGroup:
+		p.WriteString("{")
+		p.block(cmd.Body)
+		p.WriteString("}")
+
+	case *IfClause:
+		p.WriteString("if ")
+		p.inline(cmd.Cond)
+		p.WriteString("; then")
+		p.block(cmd.Then)
+
+		for _, elif := range cmd.Elifs {
+			p.WriteString("elif ")
+			p.inline(elif.Cond)
+			p.WriteString("; then")
+			p.block(elif.Then)
+		}
+
+		if cmd.Else != nil {
+			p.WriteString("else")
+			p.block(cmd.Else)
+		}
+		p.WriteString("fi")
+
+	case *WhileClause:
+		if cmd.Until {
+			p.WriteString("until ")
+		} else {
+			p.WriteString("while ")
+		}
+		p.inline(cmd.Cond)
+		p.WriteString("; do")
+		p.block(cmd.Body)
+		p.WriteString("done")
+
+	case *ForClause:
+		p.WriteString("for " + cmd.Name)
+
+		if cmd.In {
+			p.WriteString(" in")
+			for _, word := range cmd.Words {
+				p.WriteString(" " + word.Text)
+			}
+		}
+		p.WriteString("; do")
+		p.block(cmd.Body)
+		p.WriteString("done")
+
+	case *CaseClause:
+		p.WriteString("case " + cmd.Word.Text + " in")
+		p.depth++
+
+		for _, item := range cmd.Items {
+			var patterns []string
+			for _, pattern := range item.Patterns {
+				patterns = append(patterns, pattern.Text)
+			}
+
+			p.newline()
+			p.WriteString(strings.Join(patterns, " | ") + ")")
+			p.depth++
+			p.items(item.Body)
+			p.newline()
+			p.WriteString(";;")
+			p.depth--
+		}
+
+		p.depth--
+		p.newline()
+		p.WriteString("esac")
+
+	case *ArithCommand:
+		p.WriteString("((" + cmd.Expr.Text + "))")
+
+	case *CondCommand:
+		p.WriteString("[[ ")
+		p.condExpr(cmd.Expr)
+		p.WriteString(" ]]")
+
+	case *FunctionDefinition:
+		p.WriteString(cmd.Name + "() ")
+		p.command(cmd.Body)
+		return
+	}
+
+	p.redirects(redirectsOf(cmd))
+}
+
+func (p *printer) condExpr(expr CondExpr) {
+	switch expr := expr.(type) {
+	case *CondBinary:
+		p.condExpr(expr.Left)
+		p.WriteString(" " + expr.Op + " ")
+		p.condExpr(expr.Right)
+
+	case *CondUnary:
+		p.WriteString(expr.Op + " ")
+		p.condExpr(expr.Operand)
+
+	case *CondParen:
+		p.WriteString("( ")
+		p.condExpr(expr.Expr)
+		p.WriteString(" )")
+
+	case *CondWord:
+		p.WriteString(expr.Word.Text)
+	}
+}
+
+func (p *printer) redirects(redirects []*Redirect) {
+	for _, redirect := range redirects {
+		p.WriteString(" " + p.redirect(redirect))
+	}
+}
+
+// redirect return the text of the redirection and queue
+// its here-document body to be written after the line
+func (p *printer) redirect(redirect *Redirect) string {
+	text := redirect.Op + redirect.Target.Text
+
+	if redirect.Fd >= 0 {
+		text = strconv.Itoa(redirect.Fd) + text
+	}
+
+	if redirect.Heredoc != nil {
+		p.heredocs = append(p.heredocs, redirect.Heredoc)
+	}
+
+	return text
+}
+
+// redirectsOf return the redirections of the command
+func redirectsOf(cmd Command) []*Redirect {
+	switch cmd := cmd.(type) {
+	case *Subshell:
+		return cmd.Redirects
+	case *BraceGroup:
+		return cmd.Redirects
+	case *IfClause:
+		return cmd.Redirects
+	case *WhileClause:
+		return cmd.Redirects
+	case *ForClause:
+		return cmd.Redirects
+	case *CaseClause:
+		return cmd.Redirects
+	case *ArithCommand:
+		return cmd.Redirects
+	case *CondCommand:
+		return cmd.Redirects
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	src := "f() { x=1 echo \"$x\" >out 2>&1 && ! a | b & if a; b; then c\nelif d; then e; else f; fi\n" +
+		"while a; do b; done; for x in 'a b' c; do case $x in a|b) echo ab;; *) ;; esac; done\n[[ ! -f $x && ( a < b || $y =~ ^a(b|c) ) ]]\ncat <<END\nhello\nEND\n}"
+	expected := `f() {
+    x=1 echo "$x" >out 2>&1 && ! a | b &
+    if a; b; then
+        c
+    elif d; then
+        e
+    else
+        f
+    fi
+    while a; do
+        b
+    done
+    for x in 'a b' c; do
+        case $x in
+            a | b)
+                echo ab
+                ;;
+            *)
+                ;;
+        esac
+    done
+    [[ ! -f $x && ( a < b || $y =~ ^a(b|c) ) ]]
+    cat <<END
+hello
+END
+}`
+
+	list, err := Parse(src)
+	require.NoError(t, err)
+	formatted := Format(list.Items[0].AndOr.Pipelines[0].Commands[0])
+	assert.Equal(t, expected, formatted)
+
+	// the formatted source is parsed back to the same source
+	list, err = Parse(formatted)
+	require.NoError(t, err)
+	assert.Equal(t, expected, Format(list.Items[0].AndOr.Pipelines[0].Commands[0]))
+}
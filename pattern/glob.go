@@ -0,0 +1,110 @@
+package pattern
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// Glob return the sorted paths matching the pattern.
+// Each part of the pattern between slashes match the names of a
+// directory, and the names starting with a dot are only matched by
+// a part starting with a dot. A pattern ending with a slash only
+// match directories.
+func Glob(pattern string) []string {
+	return GlobIn("", pattern)
+}
+
+// GlobIn is like Glob, but the relative patterns are matched in
+// dir instead of the working directory. The paths stay relative.
+func GlobIn(dir, pattern string) []string {
+	paths := []string{""}
+	root := dir
+
+	if strings.HasPrefix(pattern, "/") {
+		paths[0] = "/"
+		root = ""
+	}
+
+	onlyDirs := strings.HasSuffix(pattern, "/")
+	parts := strings.FieldsFunc(pattern, func(r rune) bool { return r == '/' })
+
+	for i, part := range parts {
+		var matches []string
+		last := i == len(parts)-1
+
+		for _, path := range paths {
+			matches = append(matches, globDir(root, path, part, last && !onlyDirs)...)
+		}
+		paths = matches
+	}
+
+	if onlyDirs {
+		for i := range paths {
+			paths[i] += "/"
+		}
+	}
+
+	sort.Strings(paths)
+
+	return paths
+}
+
+// globDir return the paths of dir entries matched by the part.
+// Unless last is true, only the directories are returned.
+// The relative paths are relative to root, when it's set.
+func globDir(root, dir, part string, last bool) []string {
+	if !HasMeta(part) {
+		path := joinPath(dir, Unescape(part))
+
+		if last {
+			if _, err := os.Lstat(joinPath(root, path)); err != nil {
+				return nil
+			}
+		} else if info, err := os.Stat(joinPath(root, path)); err != nil || !info.IsDir() {
+			return nil
+		}
+		return []string{path}
+	}
+
+	name := joinPath(root, dir)
+	if name == "" {
+		name = "."
+	}
+
+	entries, err := os.ReadDir(name)
+	if err != nil {
+		return nil
+	}
+
+	hidden := strings.HasPrefix(Unescape(part), ".")
+	var paths []string
+
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") && !hidden {
+			continue
+		}
+
+		if !Match(part, entry.Name()) {
+			continue
+		}
+
+		path := joinPath(dir, entry.Name())
+		if !last {
+			if info, err := os.Stat(joinPath(root, path)); err != nil || !info.IsDir() {
+				continue
+			}
+		}
+		paths = append(paths, path)
+	}
+
+	return paths
+}
+
+func joinPath(dir, name string) string {
+	if dir == "" || strings.HasSuffix(dir, "/") {
+		return dir + name
+	}
+
+	return dir + "/" + name
+}
@@ -0,0 +1,44 @@
+package pattern
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlob(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.go", "a.go", ".hidden.go", "c.txt", "sub/x.go", "sub/y.txt", "lib/z.go", "é"} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, nil, 0o644))
+	}
+
+	t.Run("it should return the sorted matching paths", func(t *testing.T) {
+		assert.Equal(t, []string{dir + "/a.go", dir + "/b.go"}, Glob(dir+"/*.go"))
+		assert.Equal(t, []string{dir + "/a.go", dir + "/c.txt"}, Glob(dir+"/[ac].*"))
+	})
+
+	t.Run("it should only match the hidden files explicitly", func(t *testing.T) {
+		assert.Equal(t, []string{dir + "/.hidden.go"}, Glob(dir+"/.*.go"))
+	})
+
+	t.Run("it should match each part of the path", func(t *testing.T) {
+		assert.Equal(t, []string{dir + "/lib/z.go", dir + "/sub/x.go"}, Glob(dir+"/*/*.go"))
+		assert.Equal(t, []string{dir + "/sub/y.txt"}, Glob(dir+"/sub/?.txt"))
+		assert.Equal(t, []string{dir + "/lib/", dir + "/sub/"}, Glob(dir+"/*/"))
+	})
+
+	t.Run("it should match the multibyte chars as a whole", func(t *testing.T) {
+		assert.Equal(t, []string{dir + "/é"}, Glob(dir+"/?"))
+		assert.Equal(t, []string{dir + "/é"}, Glob(dir+"/[éè]"))
+	})
+
+	t.Run("it should return nothing without match", func(t *testing.T) {
+		assert.Empty(t, Glob(dir+"/*.rs"))
+		assert.Empty(t, Glob(dir+"/a.go/*"))
+	})
+}
@@ -0,0 +1,220 @@
+// Package pattern implement the pattern matching notation
+// of the shell, used by the parameter and pathname expansions.
+package pattern
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// classes are the character classes of the bracket expressions,
+// like `[[:digit:]]`.
+var classes = map[string]func(c rune) bool{
+	"alnum":  func(c rune) bool { return isAlpha(c) || isDigit(c) },
+	"alpha":  isAlpha,
+	"blank":  func(c rune) bool { return c == ' ' || c == '\t' },
+	"cntrl":  func(c rune) bool { return c < ' ' || c == 0x7f },
+	"digit":  isDigit,
+	"graph":  func(c rune) bool { return c > ' ' && c < 0x7f },
+	"lower":  func(c rune) bool { return 'a' <= c && c <= 'z' },
+	"print":  func(c rune) bool { return c >= ' ' && c < 0x7f },
+	"punct":  func(c rune) bool { return c > ' ' && c < 0x7f && !isAlpha(c) && !isDigit(c) },
+	"space":  func(c rune) bool { return c == ' ' || ('\t' <= c && c <= '\r') },
+	"upper":  func(c rune) bool { return 'A' <= c && c <= 'Z' },
+	"xdigit": func(c rune) bool { return isDigit(c) || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F') },
+}
+
+func isAlpha(c rune) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isDigit(c rune) bool {
+	return '0' <= c && c <= '9'
+}
+
+// Match report whether name match the pattern entirely.
+// `*` match any string, `?` any char and `[...]` any char of the
+// bracket expression, which is negated when it start with `!` or `^`
+// and can hold ranges like `a-z` and classes like `[:digit:]`.
+// A backslash make the next char match itself. The chars are
+// UTF-8 encoded, and an invalid byte is a char of its own.
+func Match(pattern, name string) bool {
+	px, nx := 0, 0
+	// position to restart from when the last `*` must match more chars
+	nextPx, nextNx := -1, -1
+
+	for px < len(pattern) || nx < len(name) {
+		// char is the next char of name, and size its length
+		char, size := utf8.DecodeRuneInString(name[nx:])
+		more := nx < len(name)
+
+		if px < len(pattern) {
+			c, width := utf8.DecodeRuneInString(pattern[px:])
+
+			switch c {
+			case '*':
+				nextPx, nextNx = px, nx+max(size, 1)
+				px++
+				continue
+
+			case '?':
+				if more {
+					px++
+					nx += size
+					continue
+				}
+
+			case '[':
+				if more {
+					if matched, width, ok := matchBracket(pattern[px:], char); ok {
+						if matched {
+							px += width
+							nx += size
+							continue
+						}
+						break
+					}
+				}
+				if more && name[nx] == '[' {
+					px++
+					nx++
+					continue
+				}
+
+			case '\\':
+				if px+1 < len(pattern) {
+					_, width = utf8.DecodeRuneInString(pattern[px+1:])
+					if more && name[nx:nx+size] == pattern[px+1:px+1+width] {
+						px += 1 + width
+						nx += size
+						continue
+					}
+					break
+				}
+				fallthrough
+
+			default:
+				if more && name[nx:nx+size] == pattern[px:px+width] {
+					px += width
+					nx += size
+					continue
+				}
+			}
+		}
+
+		if nextNx > 0 && nextNx <= len(name) {
+			px, nx = nextPx, nextNx
+			continue
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// matchBracket match char against the bracket expression at the
+// start of pattern. It return the expression width and false
+// if the expression is not terminated, in which case the `[`
+// match itself.
+func matchBracket(pattern string, char rune) (matched bool, width int, ok bool) {
+	i := 1
+	negate := false
+
+	if i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^') {
+		negate = true
+		i++
+	}
+
+	for first := true; i < len(pattern); first = false {
+		c, size := utf8.DecodeRuneInString(pattern[i:])
+
+		if c == ']' && !first {
+			return matched != negate, i + 1, true
+		}
+
+		if c == '[' && i+1 < len(pattern) && pattern[i+1] == ':' {
+			if end := strings.Index(pattern[i+2:], ":]"); end >= 0 {
+				name := pattern[i+2 : i+2+end]
+				if class, ok := classes[name]; ok && class(char) {
+					matched = true
+				}
+				i += end + 4
+				continue
+			}
+		}
+
+		if c == '\\' && i+1 < len(pattern) {
+			i++
+			c, size = utf8.DecodeRuneInString(pattern[i:])
+		}
+		i += size
+
+		if i+1 < len(pattern) && pattern[i] == '-' && pattern[i+1] != ']' {
+			high, highSize := utf8.DecodeRuneInString(pattern[i+1:])
+			if high == '\\' && i+2 < len(pattern) {
+				i++
+				high, highSize = utf8.DecodeRuneInString(pattern[i+1:])
+			}
+			i += 1 + highSize
+
+			if c <= char && char <= high {
+				matched = true
+			}
+			continue
+		}
+
+		if c == char {
+			matched = true
+		}
+	}
+
+	return false, 0, false
+}
+
+// HasMeta report whether the pattern contain unescaped special chars.
+// A `[` without closing bracket is not special.
+func HasMeta(pattern string) bool {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '*', '?':
+			return true
+		case '[':
+			if _, _, ok := matchBracket(pattern[i:], 0); ok {
+				return true
+			}
+		case '\\':
+			i++
+		}
+	}
+
+	return false
+}
+
+// Escape return the pattern matching text literally
+func Escape(text string) string {
+	var builder strings.Builder
+
+	for i := 0; i < len(text); i++ {
+		if strings.IndexByte("*?[]\\", text[i]) >= 0 {
+			builder.WriteByte('\\')
+		}
+		builder.WriteByte(text[i])
+	}
+
+	return builder.String()
+}
+
+// Unescape return the text matched by a pattern without special chars
+func Unescape(pattern string) string {
+	var builder strings.Builder
+
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '\\' && i+1 < len(pattern) {
+			i++
+		}
+		builder.WriteByte(pattern[i])
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,69 @@
+package pattern
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		matched bool
+	}{
+		{"", "", true},
+		{"abc", "abc", true},
+		{"abc", "abd", false},
+		{"a*", "a", true},
+		{"a*c", "abbbc", true},
+		{"a*c", "abbbd", false},
+		{"*/*", "a/b/c", true},
+		{"*.go", "main.go", true},
+		{"*.go", "main.go.txt", false},
+		{"?", "", false},
+		{"a?c", "abc", true},
+		{"[abc]x", "bx", true},
+		{"[!abc]x", "bx", false},
+		{"[^abc]x", "dx", true},
+		{"[a-c]", "b", true},
+		{"[a-c]", "d", false},
+		{"[]]", "]", true},
+		{"[a-]", "-", true},
+		{"[ab", "[ab", true},
+		{"\\*", "*", true},
+		{"\\*", "a", false},
+		{"a\\?", "ab", false},
+		{"*a*b*", "xxaxxbxx", true},
+		{"[[:digit:]]x", "7x", true},
+		{"[[:digit:]]x", "ax", false},
+		{"[![:alpha:]_]", "_", false},
+		{"[![:alpha:]_]", "-", true},
+		{"[[:upper:][:space:]]", " ", true},
+		{"[[:bogus:]]", "b", false},
+		{"?", "é", true},
+		{"??", "é", false},
+		{"a?c", "aéc", true},
+		{"*é", "café", true},
+		{"[é]", "é", true},
+		{"[!é]x", "éx", false},
+		{"[!a]", "é", true},
+		{"[à-ê]", "é", true},
+		{"[a-z]", "é", false},
+		{"\\é", "é", true},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.matched, Match(test.pattern, test.name), "%q ~ %q", test.name, test.pattern)
+	}
+}
+
+func TestEscape(t *testing.T) {
+	assert.Equal(t, "\\*.go\\[1\\]", Escape("*.go[1]"))
+	assert.True(t, Match(Escape("a*b?"), "a*b?"))
+	assert.False(t, HasMeta(Escape("a*b?")))
+	assert.True(t, HasMeta("a*b"))
+	assert.True(t, HasMeta("[ab]"))
+	assert.False(t, HasMeta("[ab"))
+	assert.Equal(t, "*.go[1]", Unescape(Escape("*.go[1]")))
+}
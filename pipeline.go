@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+
+	"github.com/Aboubakary833/cish/parser"
+)
+
+// runPipeline run the commands of the pipeline concurrently,
+// each one reading the output of the previous one. Unless it's
+// part of a job already, the pipeline is run as a job.
+// The pipeline status is the status of its last command, or with
+// the pipefail option, the status of the last command that failed.
+func (sh *Shell) runPipeline(pipeline *parser.Pipeline, io streams) {
+	if sh.job == nil {
+		sh.runJob(pipeline, io)
+		return
+	}
+
+	if len(pipeline.Commands) == 1 {
+		sh.runCommand(pipeline.Commands[0], io)
+	} else {
+		sh.status.Set(sh.runMembers(pipeline.Commands, io))
+	}
+
+	if pipeline.Bang {
+		if sh.status.Code() == STATUS_SUCCESS {
+			sh.status.Set(STATUS_FAILURE)
+		} else {
+			sh.status.Set(STATUS_SUCCESS)
+		}
+	}
+}
+
+// runMembers connect the commands with pipes, run each
+// of them in a subshell and wait for all of them to finish.
+// When a pipe can't be created, the commands already started
+// are waited for without starting the others.
+func (sh *Shell) runMembers(commands []parser.Command, io streams) int {
+	members := make([]chan int, 0, len(commands))
+	stdin := io.stdin
+
+	for i, cmd := range commands {
+		memberIo := io
+		memberIo.stdin = stdin
+		// the pipe ends are closed once the member is done with them
+		var ends []*os.File
+
+		if stdin != io.stdin {
+			ends = append(ends, stdin)
+		}
+
+		if i != len(commands)-1 {
+			reader, writer, err := os.Pipe()
+			if err != nil {
+				printError(io, err)
+				// closing the read end of the pending pipe
+				// let its writer finish without reader
+				closeFiles(ends)
+				break
+			}
+
+			memberIo.stdout = writer
+			ends = append(ends, writer)
+			stdin = reader
+		}
+
+		members = append(members, sh.startMember(cmd, memberIo, ends))
+	}
+
+	status := STATUS_SUCCESS
+
+	for _, done := range members {
+		code := <-done
+
+		if !sh.options[OPTION_PIPEFAIL] || code != STATUS_SUCCESS {
+			status = code
+		}
+	}
+
+	if len(members) != len(commands) {
+		return STATUS_FAILURE
+	}
+
+	return status
+}
+
+// startMember run a pipeline command in a subshell without waiting for it.
+// The returned channel receive the command exit status.
+func (sh *Shell) startMember(cmd parser.Command, io streams, ends []*os.File) chan int {
+	sub := sh.subshell()
+	done := make(chan int, 1)
+
+	go func() {
+		defer closeFiles(ends)
+		sub.runCommand(cmd, io)
+		sub.runExitTrap(io)
+		done <- sub.status.Code()
+	}()
+
+	return done
+}
+
+func closeFiles(files []*os.File) {
+	for _, file := range files {
+		file.Close()
+	}
+}
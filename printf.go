@@ -0,0 +1,420 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// echo print its arguments separated by spaces and followed by a newline.
+// With -n, the newline is not printed. With -e, the backslash escapes
+// of the arguments are interpreted, and `\c` stop the output.
+func (sh *Shell) echo(args []string, io streams) int {
+	newline, escapes := true, false
+	i := 1
+
+	for ; i < len(args); i++ {
+		arg := args[i]
+
+		if len(arg) < 2 || arg[0] != '-' || strings.Trim(arg[1:], "neE") != "" {
+			break
+		}
+
+		for _, flag := range arg[1:] {
+			switch flag {
+			case 'n':
+				newline = false
+			case 'e':
+				escapes = true
+			case 'E':
+				escapes = false
+			}
+		}
+	}
+
+	text := strings.Join(args[i:], " ")
+
+	if escapes {
+		var stop bool
+		if text, stop = unescape(text, true); stop {
+			newline = false
+		}
+	}
+
+	if newline {
+		text += "\n"
+	}
+
+	if _, err := io.stdout.WriteString(text); err != nil {
+		printError(io, fmt.Errorf("echo: write error: %s", err.Error()))
+		return STATUS_FAILURE
+	}
+
+	return STATUS_SUCCESS
+}
+
+// printf print its arguments following the format. The format
+// is reused as long as there's arguments left. With -v var,
+// the output is assigned to var instead of being printed.
+func (sh *Shell) printf(args []string, io streams) int {
+	var name string
+
+	operands := args[1:]
+	if len(operands) > 0 && operands[0] == "-v" {
+		if len(operands) < 2 {
+			printError(io, fmt.Errorf("printf: -v: option requires an argument"))
+			return STATUS_MISUSE
+		}
+		name = operands[1]
+		operands = operands[2:]
+	}
+
+	if len(operands) > 0 && operands[0] == "--" {
+		operands = operands[1:]
+	}
+
+	if len(operands) == 0 {
+		printError(io, fmt.Errorf("printf: usage: printf [-v var] format [arguments]"))
+		return STATUS_MISUSE
+	}
+
+	f := &formatter{args: operands[1:]}
+	f.run(operands[0])
+
+	for _, err := range f.errors {
+		printError(io, fmt.Errorf("printf: %s", err.Error()))
+	}
+
+	status := STATUS_SUCCESS
+	if len(f.errors) > 0 {
+		status = STATUS_FAILURE
+	}
+
+	if name != "" {
+		if err := sh.vars.Set(name, f.out.String()); err != nil {
+			printError(io, fmt.Errorf("printf: %s", err.Error()))
+			return STATUS_FAILURE
+		}
+		return status
+	}
+
+	if _, err := io.stdout.WriteString(f.out.String()); err != nil {
+		printError(io, fmt.Errorf("printf: write error: %s", err.Error()))
+		return STATUS_FAILURE
+	}
+
+	return status
+}
+
+// formatter write the arguments of printf following its format
+type formatter struct {
+	args []string
+	// pos is the index of the next argument to format
+	pos    int
+	out    strings.Builder
+	errors []error
+	// stop is true after a `\c` or an invalid conversion,
+	// so nothing else is written
+	stop bool
+}
+
+// run write the arguments with the format, which is reused
+// until all the arguments are consumed
+func (f *formatter) run(format string) {
+	for {
+		start := f.pos
+		f.format(format)
+
+		if f.stop || f.pos == start || f.pos >= len(f.args) {
+			return
+		}
+	}
+}
+
+// format write the format once, with its conversions
+// replaced by the next arguments
+func (f *formatter) format(format string) {
+	for i := 0; i < len(format) && !f.stop; {
+		switch format[i] {
+		case '\\':
+			text, length, stop := escapeSequence(format[i:], false)
+			f.out.WriteString(text)
+			f.stop = stop
+			i += length
+
+		case '%':
+			i += f.conversion(format[i:])
+
+		default:
+			f.out.WriteByte(format[i])
+			i++
+		}
+	}
+}
+
+// conversion write the argument of the conversion starting
+// at text[0], and return the length of the conversion
+func (f *formatter) conversion(text string) int {
+	i := 1
+	if i < len(text) && text[i] == '%' {
+		f.out.WriteByte('%')
+		return 2
+	}
+
+	spec := "%"
+	for ; i < len(text) && strings.IndexByte("-+ #0", text[i]) >= 0; i++ {
+		spec += text[i : i+1]
+	}
+
+	width, n := f.size(text[i:])
+	spec += width
+	i += n
+
+	if i < len(text) && text[i] == '.' {
+		precision, n := f.size(text[i+1:])
+		spec += "." + precision
+		i += n + 1
+	}
+
+	if i >= len(text) {
+		f.errors = append(f.errors, fmt.Errorf("`%s': missing format character", text))
+		f.stop = true
+		return i
+	}
+
+	switch verb := text[i]; verb {
+	case 'd', 'i':
+		f.out.WriteString(fmt.Sprintf(spec+"d", f.integer()))
+
+	case 'o', 'u', 'x', 'X':
+		if verb == 'u' {
+			verb = 'd'
+		}
+		f.out.WriteString(fmt.Sprintf(spec+string(verb), f.unsigned()))
+
+	case 'e', 'E', 'f', 'F', 'g', 'G':
+		if verb == 'F' {
+			verb = 'f'
+		}
+		f.out.WriteString(fmt.Sprintf(spec+string(verb), f.float()))
+
+	case 'c':
+		arg := f.next()
+		if arg != "" {
+			arg = arg[:1]
+		}
+		f.out.WriteString(fmt.Sprintf(spec+"s", arg))
+
+	case 's':
+		f.out.WriteString(fmt.Sprintf(spec+"s", f.next()))
+
+	case 'b':
+		arg, stop := unescape(f.next(), true)
+		f.out.WriteString(fmt.Sprintf(spec+"s", arg))
+		f.stop = stop
+
+	case 'q':
+		f.out.WriteString(fmt.Sprintf(spec+"s", shellQuote(f.next())))
+
+	default:
+		f.errors = append(f.errors, fmt.Errorf("`%c': invalid format character", verb))
+		f.stop = true
+	}
+
+	return i + 1
+}
+
+// size read the width or the precision of a conversion,
+// which is taken from the next argument when it's `*`
+func (f *formatter) size(text string) (string, int) {
+	if text != "" && text[0] == '*' {
+		return strconv.FormatInt(f.integer(), 10), 1
+	}
+
+	i := 0
+	for i < len(text) && text[i] >= '0' && text[i] <= '9' {
+		i++
+	}
+
+	return text[:i], i
+}
+
+// next return the next argument, or an empty string
+// when all the arguments were consumed
+func (f *formatter) next() string {
+	if f.pos >= len(f.args) {
+		return ""
+	}
+	f.pos++
+
+	return f.args[f.pos-1]
+}
+
+// integer return the next argument as an integer
+func (f *formatter) integer() int64 {
+	arg := f.next()
+
+	if code, ok := charCode(arg); ok {
+		return code
+	}
+
+	n, err := strconv.ParseInt(strings.TrimSpace(arg), 0, 64)
+	if err != nil && arg != "" {
+		f.errors = append(f.errors, fmt.Errorf("%s: invalid number", arg))
+	}
+
+	return n
+}
+
+// unsigned return the next argument as an unsigned
+// integer, negative numbers wrapping around
+func (f *formatter) unsigned() uint64 {
+	arg := f.next()
+
+	if code, ok := charCode(arg); ok {
+		return uint64(code)
+	}
+
+	arg = strings.TrimSpace(arg)
+
+	if n, err := strconv.ParseUint(arg, 0, 64); err == nil {
+		return n
+	}
+
+	n, err := strconv.ParseInt(arg, 0, 64)
+	if err != nil && arg != "" {
+		f.errors = append(f.errors, fmt.Errorf("%s: invalid number", arg))
+	}
+
+	return uint64(n)
+}
+
+// float return the next argument as a floating point number
+func (f *formatter) float() float64 {
+	arg := f.next()
+
+	if code, ok := charCode(arg); ok {
+		return float64(code)
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(arg), 64)
+	if err != nil && arg != "" {
+		f.errors = append(f.errors, fmt.Errorf("%s: invalid number", arg))
+	}
+
+	return n
+}
+
+// charCode return the code of the character following a leading
+// quote, as printf read `'a` as the number 97
+func charCode(arg string) (int64, bool) {
+	if len(arg) < 2 || (arg[0] != '\'' && arg[0] != '"') {
+		return 0, false
+	}
+
+	char, _ := utf8.DecodeRuneInString(arg[1:])
+
+	return int64(char), true
+}
+
+// unescape interpret the backslash escapes of the text. In the echo
+// mode, octal values are written `\0nnn` instead of `\nnn`. It report
+// true when a `\c` stopped the text.
+func unescape(text string, echo bool) (string, bool) {
+	var builder strings.Builder
+
+	for i := 0; i < len(text); {
+		if text[i] != '\\' {
+			builder.WriteByte(text[i])
+			i++
+			continue
+		}
+
+		escaped, length, stop := escapeSequence(text[i:], echo)
+		if stop {
+			return builder.String(), true
+		}
+
+		builder.WriteString(escaped)
+		i += length
+	}
+
+	return builder.String(), false
+}
+
+// escapes are the characters of the single letter escape sequences
+var escapes = map[byte]string{
+	'a':  "\a",
+	'b':  "\b",
+	'e':  "\x1b",
+	'E':  "\x1b",
+	'f':  "\f",
+	'n':  "\n",
+	'r':  "\r",
+	't':  "\t",
+	'v':  "\v",
+	'\\': "\\",
+}
+
+// escapeSequence return the value and the length of the escape
+// sequence starting at text[0]. It report true for `\c`.
+func escapeSequence(text string, echo bool) (string, int, bool) {
+	if len(text) < 2 {
+		return text, len(text), false
+	}
+
+	char := text[1]
+
+	if value, ok := escapes[char]; ok {
+		return value, 2, false
+	}
+
+	switch {
+	case char == 'c':
+		return "", 2, true
+
+	case char >= '0' && char <= '7':
+		start := 1
+		if echo {
+			if char != '0' {
+				break
+			}
+			start = 2
+		}
+
+		value, n := parseDigits(text[start:], 8, 3)
+		return string([]byte{byte(value)}), start + n, false
+
+	case char == 'x' || char == 'u' || char == 'U':
+		limit := map[byte]int{'x': 2, 'u': 4, 'U': 8}[char]
+
+		value, n := parseDigits(text[2:], 16, limit)
+		if n == 0 {
+			break
+		}
+
+		if char == 'x' {
+			return string([]byte{byte(value)}), n + 2, false
+		}
+		return string(rune(value)), n + 2, false
+	}
+
+	return text[:2], 2, false
+}
+
+// parseDigits parse up to limit digits of the base at
+// the start of the text, and return their count
+func parseDigits(text string, base, limit int) (int, int) {
+	value, n := 0, 0
+
+	for ; n < len(text) && n < limit; n++ {
+		digit, err := strconv.ParseInt(text[n:n+1], base, 64)
+		if err != nil {
+			break
+		}
+		value = value*base + int(digit)
+	}
+
+	return value, n
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// errTimeout is returned when `read -t` wait too long for its input
+var errTimeout = errors.New("timeout")
+
+// readOptions are the options of `read`
+type readOptions struct {
+	raw     bool
+	silent  bool
+	prompt  string
+	array   string
+	delim   byte
+	limit   int
+	timeout time.Duration
+	// timed is true when a timeout was given
+	timed bool
+}
+
+// read read a line of the standard input and split it into fields
+// with IFS, which are assigned to the names given, the last one
+// getting the rest of the line. Without names, the line is assigned
+// to REPLY. Unless -r is given, a backslash escape the next char,
+// and a backslash followed by a newline continue the line.
+func (sh *Shell) read(args []string, stdio streams) int {
+	opts, names, err := parseReadOptions(args)
+	if err != nil {
+		printError(stdio, fmt.Errorf("read: %s", err.Error()))
+		return STATUS_MISUSE
+	}
+
+	fd := int(stdio.stdin.Fd())
+
+	if opts.timed && opts.timeout == 0 {
+		if ready, _ := waitInput(fd, 0); ready {
+			return STATUS_SUCCESS
+		}
+		return STATUS_FAILURE
+	}
+
+	isTerminal := term.IsTerminal(fd)
+
+	if opts.prompt != "" && isTerminal {
+		fmt.Fprint(stdio.stderr, opts.prompt)
+	}
+
+	if opts.silent && isTerminal {
+		if state, err := term.MakeRaw(fd); err == nil {
+			defer term.Restore(fd, state)
+		}
+	}
+
+	line, escaped, err := readLine(stdio.stdin, opts, isTerminal)
+
+	status := STATUS_SUCCESS
+	switch {
+	case errors.Is(err, errTimeout):
+		status = STATUS_SIGNAL + int(syscall.SIGALRM)
+	case errors.Is(err, io.EOF):
+		status = STATUS_FAILURE
+	case err != nil:
+		printError(stdio, fmt.Errorf("read: %s", err.Error()))
+		return STATUS_FAILURE
+	}
+
+	if opts.silent && isTerminal {
+		fmt.Fprint(stdio.stderr, "\r\n")
+	}
+
+	if err := sh.assignRead(line, escaped, names, opts.array); err != nil {
+		printError(stdio, fmt.Errorf("read: %s", err.Error()))
+		return STATUS_FAILURE
+	}
+
+	return status
+}
+
+// assignRead assign the line read to the variables
+func (sh *Shell) assignRead(line []byte, escaped []bool, names []string, array string) error {
+	ifs := sh.ifs()
+
+	switch {
+	case array != "":
+		return sh.vars.SetArray(array, splitRead(line, escaped, ifs, 0))
+
+	case len(names) == 0:
+		return sh.vars.Set("REPLY", string(line))
+	}
+
+	fields := splitRead(line, escaped, ifs, len(names))
+
+	for i, name := range names {
+		value := ""
+		if i < len(fields) {
+			value = fields[i]
+		}
+
+		if err := sh.vars.Set(name, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseReadOptions parse the options of `read`. The options
+// taking a value accept it in the same argument, like `-n3`.
+func parseReadOptions(args []string) (readOptions, []string, error) {
+	opts := readOptions{delim: '\n'}
+	i := 1
+
+	for ; i < len(args); i++ {
+		arg := args[i]
+
+		if arg == "--" {
+			i++
+			break
+		}
+
+		if len(arg) < 2 || arg[0] != '-' {
+			break
+		}
+
+		for j := 1; j < len(arg); j++ {
+			flag := arg[j]
+
+			switch flag {
+			case 'r':
+				opts.raw = true
+				continue
+			case 's':
+				opts.silent = true
+				continue
+			case 'p', 'a', 'd', 'n', 't':
+			default:
+				return opts, nil, fmt.Errorf("-%c: invalid option", flag)
+			}
+
+			value := arg[j+1:]
+			if value == "" {
+				if i+1 >= len(args) {
+					return opts, nil, fmt.Errorf("-%c: option requires an argument", flag)
+				}
+				i++
+				value = args[i]
+			}
+
+			if err := opts.set(flag, value); err != nil {
+				return opts, nil, err
+			}
+
+			break
+		}
+	}
+
+	return opts, args[i:], nil
+}
+
+// set set the option taking a value
+func (opts *readOptions) set(flag byte, value string) error {
+	switch flag {
+	case 'p':
+		opts.prompt = value
+
+	case 'a':
+		opts.array = value
+
+	case 'd':
+		opts.delim = 0
+		if value != "" {
+			opts.delim = value[0]
+		}
+
+	case 'n':
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("%s: invalid number", value)
+		}
+		opts.limit = n
+
+	case 't':
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil || seconds < 0 {
+			return fmt.Errorf("%s: invalid timeout specification", value)
+		}
+		opts.timeout = time.Duration(seconds * float64(time.Second))
+		opts.timed = true
+	}
+
+	return nil
+}
+
+// readLine read the input until the delimiter, one byte at a time
+// so the input following it is left to the next commands. It return
+// the bytes read, without the escaping backslashes, and which of them
+// were escaped. The error is io.EOF when the input ended before the
+// delimiter, and errTimeout when the timeout expired.
+func readLine(file *os.File, opts readOptions, isTerminal bool) ([]byte, []bool, error) {
+	var line []byte
+	var escaped []bool
+	var deadline time.Time
+	buffer := make([]byte, 1)
+	fd := int(file.Fd())
+	// backslash is true when the previous byte was an escaping backslash
+	backslash := false
+
+	if opts.timed {
+		deadline = time.Now().Add(opts.timeout)
+	}
+
+	for opts.limit == 0 || len(line) < opts.limit {
+		if opts.timed {
+			ready, err := waitInput(fd, time.Until(deadline))
+			if err != nil {
+				return line, escaped, err
+			}
+			if !ready {
+				return line, escaped, errTimeout
+			}
+		}
+
+		n, err := file.Read(buffer)
+		if n == 0 {
+			if err == nil {
+				err = io.EOF
+			}
+			return line, escaped, err
+		}
+
+		char := buffer[0]
+		if opts.silent && isTerminal && char == '\r' {
+			char = '\n'
+		}
+
+		switch {
+		case backslash:
+			backslash = false
+			if char == '\n' {
+				continue
+			}
+			line = append(line, char)
+			escaped = append(escaped, true)
+
+		case char == opts.delim:
+			return line, escaped, nil
+
+		case char == '\\' && !opts.raw:
+			backslash = true
+
+		default:
+			line = append(line, char)
+			escaped = append(escaped, false)
+		}
+	}
+
+	return line, escaped, nil
+}
+
+// waitInput wait for the file descriptor to be readable, up to the timeout.
+// A regular file is always readable, so it never wait for it.
+func waitInput(fd int, timeout time.Duration) (bool, error) {
+	fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+
+	for {
+		n, err := unix.Poll(fds, int(max(timeout, 0).Milliseconds()))
+		if err == unix.EINTR {
+			continue
+		}
+
+		return n > 0, err
+	}
+}
+
+// splitRead split the line on the IFS chars which were not escaped,
+// like the expansions are split. With n > 0, at most n fields are
+// returned, the last one being the rest of the line without the
+// IFS whitespaces which end it.
+func splitRead(line []byte, escaped []bool, ifs string, n int) []string {
+	isIFS := func(i int) bool {
+		return !escaped[i] && strings.IndexByte(ifs, line[i]) >= 0
+	}
+	isWhite := func(i int) bool {
+		return isIFS(i) && strings.IndexByte(DEFAULT_IFS, line[i]) >= 0
+	}
+	skipWhites := func(i int) int {
+		for i < len(line) && isWhite(i) {
+			i++
+		}
+		return i
+	}
+
+	var fields []string
+	i := skipWhites(0)
+
+	for i < len(line) {
+		if n > 0 && len(fields) == n-1 {
+			end := len(line)
+			for end > i && isWhite(end-1) {
+				end--
+			}
+			return append(fields, string(line[i:end]))
+		}
+
+		start := i
+		for i < len(line) && !isIFS(i) {
+			i++
+		}
+		fields = append(fields, string(line[start:i]))
+
+		i = skipWhites(i)
+		if i < len(line) && isIFS(i) {
+			i = skipWhites(i + 1)
+		}
+	}
+
+	return fields
+}
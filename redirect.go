@@ -0,0 +1,226 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+
+	"github.com/Aboubakary833/cish/parser"
+)
+
+// file return the file open on the descriptor fd, or nil if it's closed
+func (io *streams) file(fd int) *os.File {
+	switch fd {
+	case 0:
+		return io.stdin
+	case 1:
+		return io.stdout
+	case 2:
+		return io.stderr
+	}
+
+	return io.extra[fd]
+}
+
+// setFile open file on the descriptor fd. A nil file close the descriptor.
+func (io *streams) setFile(fd int, file *os.File) {
+	switch fd {
+	case 0:
+		io.stdin = file
+	case 1:
+		io.stdout = file
+	case 2:
+		io.stderr = file
+	default:
+		// the map may be shared with the streams this one is copied from
+		extra := make(map[int]*os.File, len(io.extra)+1)
+		for n, f := range io.extra {
+			extra[n] = f
+		}
+		extra[fd] = file
+		io.extra = extra
+	}
+}
+
+// extraFiles return the files to pass to a program
+// for the descriptors above 2.
+func (io *streams) extraFiles() []*os.File {
+	maxFd := 2
+	for fd, file := range io.extra {
+		if file != nil && fd > maxFd {
+			maxFd = fd
+		}
+	}
+
+	files := make([]*os.File, maxFd-2)
+	for fd, file := range io.extra {
+		if file != nil {
+			files[fd-3] = file
+		}
+	}
+
+	return files
+}
+
+// redirect apply the redirections to a copy of io. The files opened
+// by the redirections are returned to be closed once the command end.
+func (sh *Shell) redirect(redirects []*parser.Redirect, io streams) (streams, []*os.File, error) {
+	var opened []*os.File
+	original := io
+
+	for _, redirect := range redirects {
+		file, err := sh.openRedirect(redirect, &io)
+		if err != nil {
+			closeFiles(opened)
+			return original, nil, err
+		}
+
+		if file != nil {
+			opened = append(opened, file)
+		}
+	}
+
+	return io, opened, nil
+}
+
+// openRedirect apply a redirection to io.
+// It return the file it opened if there's one.
+func (sh *Shell) openRedirect(redirect *parser.Redirect, io *streams) (*os.File, error) {
+	fd := redirect.Fd
+	if fd < 0 {
+		fd = 1
+		if redirect.Op[0] == '<' {
+			fd = 0
+		}
+	}
+
+	if redirect.Heredoc != nil {
+		body := redirect.Heredoc.Body
+		if !redirect.Heredoc.Quoted {
+			var err error
+			if body, err = sh.expandHeredoc(body); err != nil {
+				return nil, err
+			}
+		}
+
+		file, err := heredocFile(body)
+		if err == nil {
+			io.setFile(fd, file)
+		}
+		return file, err
+	}
+
+	target, err := sh.expand(redirect.Target.Text)
+	if err != nil {
+		return nil, err
+	}
+
+	switch redirect.Op {
+	case "<&", ">&":
+		if target == "-" {
+			io.setFile(fd, nil)
+			return nil, nil
+		}
+
+		if n, err := strconv.Atoi(target); err == nil {
+			if io.file(n) == nil {
+				return nil, fmt.Errorf("%s: bad file descriptor", target)
+			}
+			io.setFile(fd, io.file(n))
+			return nil, nil
+		}
+
+		if redirect.Op == "<&" || redirect.Fd >= 0 {
+			return nil, fmt.Errorf("%s: ambiguous redirect", target)
+		}
+
+		// `>&file` is the same as `&>file`
+		fallthrough
+
+	case "&>", "&>>":
+		flag := os.O_TRUNC
+		if redirect.Op == "&>>" {
+			flag = os.O_APPEND
+		}
+
+		file, err := sh.openFile(target, os.O_WRONLY|os.O_CREATE|flag, redirect.Op == "&>>")
+		if err == nil {
+			io.setFile(1, file)
+			io.setFile(2, file)
+		}
+		return file, err
+	}
+
+	var flag int
+	clobber := redirect.Op == ">|"
+
+	switch redirect.Op {
+	case "<":
+		flag = os.O_RDONLY
+	case ">", ">|":
+		flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	case ">>":
+		flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		clobber = true
+	case "<>":
+		flag = os.O_RDWR | os.O_CREATE
+		clobber = true
+	}
+
+	file, err := sh.openFile(target, flag, clobber)
+	if err == nil {
+		io.setFile(fd, file)
+	}
+
+	return file, err
+}
+
+// openFile open the target of a redirection. With the noclobber option,
+// existing regular files are not truncated unless clobber is true.
+func (sh *Shell) openFile(name string, flag int, clobber bool) (*os.File, error) {
+	if sh.options[OPTION_NOCLOBBER] && !clobber && flag&os.O_TRUNC != 0 {
+		if info, err := os.Stat(sh.path(name)); err == nil && info.Mode().IsRegular() {
+			return nil, fmt.Errorf("%s: cannot overwrite existing file", name)
+		}
+	}
+
+	file, err := os.OpenFile(sh.path(name), flag, 0666)
+
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		err = fmt.Errorf("%s: %s", name, errorMessage(pathErr.Err))
+	}
+
+	return file, err
+}
+
+// heredocFile return a file from which the body can be read
+func heredocFile(body string) (*os.File, error) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		defer writer.Close()
+		writer.WriteString(body)
+	}()
+
+	return reader, nil
+}
+
+// errorMessage return the message of a system error
+// with the first letter in uppercase, as printed by the
+// other shells.
+func errorMessage(err error) string {
+	var errno syscall.Errno
+	message := err.Error()
+
+	if errors.As(err, &errno) && message != "" && message[0] >= 'a' && message[0] <= 'z' {
+		message = string(message[0]-'a'+'A') + message[1:]
+	}
+
+	return message
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedirect(t *testing.T) {
+	t.Run("it should write, append and read files", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "file")
+		output, status := runScript(t, "echo a > "+file+"; echo b >> "+file+"; cat < "+file)
+
+		assert.Equal(t, "a\nb\n", output)
+		assert.Equal(t, STATUS_SUCCESS, status)
+	})
+
+	t.Run("it should report the files that can't be opened", func(t *testing.T) {
+		output, status := runScript(t, "cat < /cish/does/not/exist && echo not run")
+
+		assert.Equal(t, "cish: /cish/does/not/exist: No such file or directory\n", output)
+		assert.Equal(t, STATUS_FAILURE, status)
+	})
+
+	t.Run("it should duplicate and close descriptors", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "file")
+		output, _ := runScript(t, "ls /cish/does/not/exist >"+file+" 2>&1; ls /cish/does/not/exist 2>&-")
+		content, _ := os.ReadFile(file)
+
+		assert.Equal(t, "", output)
+		assert.Contains(t, string(content), "/cish/does/not/exist")
+	})
+
+	t.Run("it should pass the descriptors above 2 to programs", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "file")
+		output, _ := runScript(t, "readlink /proc/self/fd/3 3>"+file)
+
+		assert.Equal(t, file+"\n", output)
+	})
+
+	t.Run("it should redirect both outputs", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "file")
+		runScript(t, "{ echo out; ls /cish/does/not/exist; } &>"+file)
+		content, _ := os.ReadFile(file)
+
+		assert.Contains(t, string(content), "out\n")
+		assert.Contains(t, string(content), "/cish/does/not/exist")
+	})
+
+	t.Run("it should not overwrite files with noclobber", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "file")
+		output, status := runScript(t, "echo a > "+file+"; set -o noclobber; echo b > "+file+"; cat "+file)
+
+		assert.Equal(t, "cish: "+file+": cannot overwrite existing file\na\n", output)
+		assert.Equal(t, STATUS_SUCCESS, status)
+
+		output, _ = runScript(t, "set -o noclobber; echo c >| "+file+"; cat "+file)
+		assert.Equal(t, "c\n", output)
+	})
+
+	t.Run("it should read here-documents", func(t *testing.T) {
+		output, _ := runScript(t, "false; cat <<EOF; cat <<-'EOF'\nstatus $?\nEOF\n\tstatus $?\n\tEOF\n")
+
+		assert.Equal(t, "status 1\nstatus $?\n", output)
+	})
+}
@@ -2,25 +2,28 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"slices"
 	"strings"
+	"syscall"
+	"unicode/utf8"
 
+	"github.com/Aboubakary833/cish/parser"
+	"github.com/Aboubakary833/cish/scanner"
 	"golang.org/x/term"
 )
 
-const (
-	EXIT_SUCCESS = iota
-	EXIT_ERROR
-)
-
 const NULChar = '\x00'
 
 const (
 	KeyCtrlC     = 3
 	KeyCtrlD     = 4
+	KeyCtrlG     = 7
+	KeyCtrlR     = 18
+	KeyCtrlS     = 19
 	KeyEnter     = '\r'
 	KeyNewLine   = '\n'
 	KeyArrow     = '\033'
@@ -52,22 +55,33 @@ const (
 	PS2
 )
 
-var (
-	Quotes   = []byte{'"', '\''}
-	quitKeys = []byte{KeyCtrlC, KeyCtrlD}
-)
+var Quotes = []byte{'"', '\''}
+
+// errInterrupt is returned when Ctrl-C discard the command being typed
+var errInterrupt = errors.New("interrupt")
 
 type Command struct {
 	reader       *bufio.Reader
 	outputStream io.Writer
 	quotesOpened bool
 	openedQuote  byte
+	// heredoc is true while a here-document body is typed
+	heredoc      bool
 	shouldEscape bool
 	cursorPos    uint64
 	prompt       int
+	status       int
 	buffer       string
 	sourceFd     int
 	termState    *term.State
+	// ignoreEOF is set when Ctrl-D must not leave the shell
+	ignoreEOF bool
+	// history are the command lines typed before, recalled with
+	// the up and down arrows. histIndex is the entry shown, and
+	// draft the line typed, while the history is navigated.
+	history   *History
+	histIndex int
+	draft     *lineDraft
 }
 
 func newCommand(source io.Reader, sourceFd int, state *term.State) *Command {
@@ -90,7 +104,7 @@ func (cmd *Command) read() (err error) {
 
 L:
 	for {
-		key, b_err := cmd.reader.ReadByte()
+		key, _, b_err := cmd.reader.ReadRune()
 
 		if b_err != nil {
 			err = b_err
@@ -102,11 +116,39 @@ L:
 
 		switch true {
 
-		case slices.Contains(quitKeys, key):
-			exitCish(cmd.sourceFd, cmd.termState, EXIT_ERROR)
+		case key == KeyCtrlC:
+			cmd.defaultPrint("^C\r\n")
+			err = errInterrupt
+			break L
 
-		case slices.Contains(Quotes, key):
-			cmd.handleQuote(key)
+		case key == KeyCtrlR || key == KeyCtrlS:
+			key, b_err := cmd.searchHistory(key == KeyCtrlR)
+			if b_err != nil {
+				err = b_err
+				break L
+			}
+
+			if key == KeyCtrlC {
+				cmd.defaultPrint("^C\r\n")
+				err = errInterrupt
+				break L
+			}
+
+			if key == KeyEnter {
+				cmd.printKey(key)
+				if cmd.handleKeyEnter() {
+					break L
+				}
+			}
+
+		case key == KeyCtrlD:
+			if !cmd.handleCtrlD() {
+				err = io.EOF
+				break L
+			}
+
+		case key < utf8.RuneSelf && slices.Contains(Quotes, byte(key)):
+			cmd.handleQuote(byte(key))
 
 		case key == KeyBackspace:
 			cmd.handleBackspace()
@@ -136,18 +178,65 @@ L:
 	return
 }
 
+// handleCtrlD delete the char under the cursor. On an empty line,
+// it report false so the shell is left, unless ignoreEOF is set.
+func (cmd *Command) handleCtrlD() bool {
+	if cmd.bufferLen() != 0 {
+		cmd.deleteChar()
+		return true
+	}
+
+	if !cmd.ignoreEOF {
+		return false
+	}
+
+	cmd.defaultPrint("\r\nUse \"exit\" to leave the shell.\r\n")
+	cmd.printPS1Prompt()
+
+	return true
+}
+
+// deleteChar delete the char under the cursor,
+// with the combining chars following it
+func (cmd *Command) deleteChar() {
+	if cmd.cursorIsPeak() {
+		return
+	}
+
+	lastChunk := cmd.buffer[nextGrapheme(cmd.buffer, int(cmd.cursorPos)):]
+	cmd.buffer = cmd.buffer[:cmd.cursorPos] + lastChunk
+
+	cmd.defaultPrint(ARROW_CHUNK + "K" + lastChunk)
+
+	// Replace the cursor in the stdout
+	cmd.moveLeft(stringWidth(lastChunk))
+}
+
+// moveLeft move the cursor in the stdout left by n columns
+func (cmd *Command) moveLeft(n int) {
+	for i := n; i > 0; i-- {
+		cmd.defaultPrint(ARROW_CHUNK + string(rune(KeyArrowLeft)))
+	}
+}
+
+// erase erase the n columns before the cursor in the stdout.
+// A column is erased with DELETE.
+func (cmd *Command) erase(n int) {
+	cmd.defaultPrint(strings.Repeat("\b", n) + DELETE[1:])
+}
+
 func (cmd *Command) hasSuffix(str string) bool {
 	return strings.HasSuffix(cmd.buffer, str)
 }
 
 // appendToBuffer append the typed key to the buffer
 // at the current cursor position
-func (cmd *Command) appendToBuffer(char byte) {
+func (cmd *Command) appendToBuffer(char rune) {
 	bufferLen := cmd.bufferLen()
 
 	if bufferLen == 0 || cmd.cursorIsPeak() {
 		cmd.buffer += string(char)
-		cmd.cursorPos++
+		cmd.cursorPos += uint64(utf8.RuneLen(char))
 		return
 	}
 
@@ -155,38 +244,48 @@ func (cmd *Command) appendToBuffer(char byte) {
 	lastChunk := cmd.buffer[cmd.cursorPos:bufferLen]
 
 	cmd.buffer = firstChunk + string(char) + lastChunk
-	cmd.cursorPos++
+	cmd.cursorPos += uint64(utf8.RuneLen(char))
 }
 
 // handleQuote determine what to do when a quote is typed
 func (cmd *Command) handleQuote(char byte) {
+	if cmd.heredoc {
+		cmd.appendToBuffer(rune(char))
+		return
+	}
+
 	if cmd.shouldEscape {
-		cmd.appendToBuffer(char)
+		cmd.appendToBuffer(rune(char))
 		cmd.shouldEscape = false
 		return
 	}
 
 	if cmd.bufferLen() == 0 || (!cmd.quotesOpened && !cmd.shouldEscape) {
-		cmd.appendToBuffer(char)
+		cmd.appendToBuffer(rune(char))
 		cmd.quotesOpened = true
 		cmd.openedQuote = char
 		return
 	}
 
 	if !cmd.quotesOpened && cmd.shouldEscape {
-		cmd.appendToBuffer(char)
+		cmd.appendToBuffer(rune(char))
 		return
 	}
 
 	if char == cmd.openedQuote && !cmd.shouldEscape {
-		cmd.appendToBuffer(char)
+		cmd.appendToBuffer(rune(char))
 		cmd.quotesOpened = false
 	} else {
-		cmd.appendToBuffer(char)
+		cmd.appendToBuffer(rune(char))
 	}
 }
 
 func (cmd *Command) handleBackSlace() {
+	if cmd.heredoc {
+		cmd.appendToBuffer(KeyBackSlace)
+		return
+	}
+
 	if cmd.shouldEscape {
 		cmd.appendToBuffer(KeyBackSlace)
 		cmd.shouldEscape = false
@@ -201,6 +300,19 @@ func (cmd *Command) handleBackSlace() {
 // It return false if the command is a multiline command.
 // Otherwise, it return true
 func (cmd *Command) handleKeyEnter() bool {
+	if cmd.heredoc {
+		cmd.cursorPos = cmd.bufferLen()
+		cmd.appendToBuffer(KeyNewLine)
+
+		if cmd.pendingHeredoc() {
+			cmd.printPS2Prompt()
+			return false
+		}
+
+		cmd.heredoc = false
+		return true
+	}
+
 	if cmd.quotesOpened {
 		if !cmd.cursorIsPeak() {
 			cmd.clearAndPrint()
@@ -245,16 +357,57 @@ func (cmd *Command) handleKeyEnter() bool {
 	// put the newline key to the end of the cmd
 	cmd.buffer += string(KeyNewLine)
 
+	// the here-documents body is typed on the next lines
+	if cmd.pendingHeredoc() {
+		cmd.heredoc = true
+		cmd.cursorPos = cmd.bufferLen()
+		cmd.printPS2Prompt()
+		return false
+	}
+
+	// an unterminated substitution or compound
+	// command continue on the next line
+	if cmd.isIncomplete() {
+		cmd.cursorPos = cmd.bufferLen()
+		cmd.printPS2Prompt()
+		return false
+	}
+
 	return true
 }
 
+// isIncomplete report whether the command is cut before its end,
+// like an unterminated substitution or an `if` without `fi`.
+func (cmd *Command) isIncomplete() bool {
+	_, err := parser.Parse(cmd.buffer)
+
+	return parser.IsIncomplete(err)
+}
+
+// pendingHeredoc report whether the command contain
+// here-documents whose delimiter line is not typed yet.
+func (cmd *Command) pendingHeredoc() bool {
+	lexer := scanner.NewLexer(cmd.buffer)
+
+	for {
+		token, err := lexer.Next()
+
+		if err != nil {
+			return len(lexer.PendingHeredocs()) != 0
+		}
+
+		if token.Kind() == scanner.END_OF_FILE {
+			return false
+		}
+	}
+}
+
 // handleBackspace is executed when the backspace key is pressed
 // and depending on the cmd states, determine what
 // action should be done.
 func (cmd *Command) handleBackspace() {
 
 	var lastChar byte;
-	var bufferLen uint64;
 
 	if len(cmd.buffer) == 0 || cmd.cursorPos == 0 {
 		return
@@ -278,13 +431,15 @@ func (cmd *Command) handleBackspace() {
 		return
 	}
 
-	bufferLen = cmd.bufferLen()
+	// the char is removed with the combining chars following it
+	start := previousGrapheme(cmd.buffer, int(cmd.cursorPos))
+	width := graphemeWidth(cmd.buffer[start:cmd.cursorPos])
 
 	if cmd.cursorIsPeak() {
-		lastChar = cmd.buffer[bufferLen-1]
-		cmd.buffer = cmd.buffer[:bufferLen-1]
+		lastChar = cmd.buffer[cmd.cursorPos-1]
+		cmd.buffer = cmd.buffer[:start]
 
-		if bufferLen >= 2 && cmd.buffer[bufferLen-2] == KeyBackSlace {
+		if start >= 1 && cmd.buffer[start-1] == KeyBackSlace {
 			cmd.shouldEscape = true
 		} else if lastChar == cmd.openedQuote {
 			cmd.quotesOpened = true
@@ -294,24 +449,22 @@ func (cmd *Command) handleBackspace() {
 			cmd.shouldEscape = false
 		}
 
-		cmd.defaultPrint(DELETE)
-		cmd.cursorPos--
+		cmd.erase(width)
+		cmd.cursorPos = uint64(start)
 		return
 	}
 
 	// Remove the char from buffer
-	firstChunk := cmd.buffer[:cmd.cursorPos-1]
-	lastChunk := cmd.buffer[cmd.cursorPos:bufferLen]
+	firstChunk := cmd.buffer[:start]
+	lastChunk := cmd.buffer[cmd.cursorPos:]
 	cmd.buffer = firstChunk + lastChunk
-	cmd.cursorPos--
+	cmd.cursorPos = uint64(start)
 
-	cmd.defaultPrint(DELETE)
+	cmd.erase(width)
 	cmd.defaultPrint(lastChunk)
 
 	// Replace the cursor in the stdout
-	for i := len(lastChunk); i > 0; i-- {
-		cmd.defaultPrint(ARROW_CHUNK + string(rune(KeyArrowLeft)))
-	}
+	cmd.moveLeft(stringWidth(lastChunk))
 }
 
 // bufferLen return the length of the cmd buffer
@@ -326,7 +479,8 @@ func (cmd *Command) cursorIsPeak() bool {
 }
 
 // moveCursor handle the shell navigation through
-// the arrows keys. It all modify the cmd cursor position.
+// the arrows keys. It all modify the cmd cursor position,
+// except the up and down arrows which navigate the history.
 func (cmd *Command) moveCursor() (err error) {
 	var key byte
 	var b_err error
@@ -345,38 +499,62 @@ func (cmd *Command) moveCursor() (err error) {
 		}
 	}
 
+	switch key {
+	case KeyArrowUp:
+		cmd.recallPrevious()
+		return
+	case KeyArrowBottom:
+		cmd.recallNext()
+		return
+	}
+
 	if len(cmd.buffer) == 0 || !slices.Contains(verticalKeys, key) {
 		return
 	}
 
-	previousChar := cmd.buffer[cmd.cursorPos - 1]
+	if cmd.prompt == PS2 && cmd.cursorPos > 0 {
+		previousChar := cmd.buffer[cmd.cursorPos - 1]
 
-	if cmd.prompt == PS2 {
 		if key == KeyArrowLeft && (slices.Contains([]byte{KeyNewLine, KeyBackSlace}, previousChar)) {
 			return
 		}
 	}
 
-	// Increase or decrease cursor depending on the key pressed.
+	// Move the cursor over a whole char, with the combining
+	// chars following it, depending on the key pressed.
 	// Quit function if the key is one of the vertical keys.
+	var cluster string
+
 	if key == KeyArrowLeft && cmd.cursorPos > 0 {
-		cmd.cursorPos--
+		start := previousGrapheme(cmd.buffer, int(cmd.cursorPos))
+		cluster = cmd.buffer[start:cmd.cursorPos]
+		cmd.cursorPos = uint64(start)
 	} else if key == KeyArrowRight && !cmd.cursorIsPeak() {
-		cmd.cursorPos++
+		end := nextGrapheme(cmd.buffer, int(cmd.cursorPos))
+		cluster = cmd.buffer[cmd.cursorPos:end]
+		cmd.cursorPos = uint64(end)
 	} else {
 		return
 	}
 
-	cmd.defaultPrint(ARROW_CHUNK + string(key))
+	// wide chars are shown on two columns
+	cmd.defaultPrint(strings.Repeat(ARROW_CHUNK + string(key), graphemeWidth(cluster)))
 
 	return
 }
 
+// printPS1Prompt print the primary prompt.
+// The last exit status is shown when it's not a success.
 func (cmd *Command) printPS1Prompt() {
 	if cmd.prompt != PS1 {
 		cmd.prompt = PS1
 	}
 
+	if cmd.status != STATUS_SUCCESS {
+		cmd.defaultPrint(fmt.Sprintf("\r[%d] $ ", cmd.status))
+		return
+	}
+
 	cmd.defaultPrint("\r$ ")
 }
 
@@ -397,11 +575,9 @@ func (cmd *Command) defaultPrint(a ...any) (n int, err error) {
 }
 
 // printKey print out the typed key
-func (cmd *Command) printKey(key byte) {
-	previousChar := " "
-
-	// Escape arrow keys when printing to stdout
-	if key == KeyArrow {
+func (cmd *Command) printKey(key rune) {
+	// Escape arrow and control keys when printing to stdout
+	if slices.Contains([]rune{KeyArrow, KeyCtrlC, KeyCtrlD, KeyCtrlG, KeyCtrlR, KeyCtrlS}, key) {
 		return
 	}
 
@@ -421,27 +597,19 @@ func (cmd *Command) printKey(key byte) {
 		return
 	}
 
-	firstChunk := cmd.buffer[:cmd.cursorPos]
-
-	if len(firstChunk) != 0 {
-		previousChar = firstChunk[cmd.cursorPos-1 : cmd.cursorPos]
-	}
 	lastChunk := cmd.buffer[cmd.cursorPos:cmd.bufferLen()]
 
-	cmd.defaultPrint(DELETE)
-	cmd.defaultPrint(previousChar + string(key))
+	cmd.defaultPrint(string(key))
 	cmd.defaultPrint(lastChunk)
 
 	// Replace the cursor in the stdout
-	for i := len(lastChunk); i > 0; i-- {
-		cmd.defaultPrint(ARROW_CHUNK + string(rune(KeyArrowLeft)))
-	}
+	cmd.moveLeft(stringWidth(lastChunk))
 }
 
 // Clear stdout and print out the command.
 // This function also set the cursor position to peak.
 func (cmd *Command) clearAndPrint() {
-	for i := cmd.bufferLen(); i > 0; i-- {
+	for i := stringWidth(cmd.buffer); i > 0; i-- {
 		cmd.defaultPrint(DELETE)
 	}
 	cmd.printPS1Prompt()
@@ -460,29 +628,86 @@ func (cmd *Command) setBuffer(buffer string) {
 
 // Repl is the acronym for Read Eval Print and Loop.
 // So, it's the orchestrator of this shell
-func Repl(rd io.Reader) {
-	exitCommands := []string{"exit\n", "quit\n"}
+func Repl(rd io.Reader, opts cliOptions) {
 	stdinFd := int(os.Stdin.Fd())
 
 	state := enterRawMode(stdinFd)
+	sh := newShell(stdinFd, state)
+	sh.name, sh.params = opts.name, opts.params
+	sh.interactive = true
+	sh.input = 's'
+	sh.initJobControl()
+	sh.initSignals()
+	sh.initHistory()
+	history := &History{settings: sh.historySettings()}
+	reportHistoryError(history.Load())
 
 	for {
+		history.settings = sh.historySettings()
+		reportHistoryError(history.Sync())
+
 		cmd := newCommand(rd, stdinFd, state)
+		cmd.status = sh.status.Code()
+		cmd.ignoreEOF = sh.options[OPTION_IGNOREEOF]
+		cmd.history = history
+
+		err := cmd.read()
 
-		if err := cmd.read(); err != nil {
+		switch {
+		case errors.Is(err, errInterrupt):
+			sh.status.Set(STATUS_SIGNAL + int(syscall.SIGINT))
+			continue
+
+		case errors.Is(err, io.EOF):
+			cmd.defaultPrint("exit\r\n")
+			sh.leave()
+
+		case err != nil:
 			fmt.Fprintln(os.Stderr, err.Error())
-			exitCish(stdinFd, state, EXIT_ERROR)
+			exitCish(stdinFd, state, STATUS_FAILURE)
+		}
+
+		line, printOnly, err := history.Expand(cmd.buffer)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\r\ncish: %s\r\n", err.Error())
+			sh.status.Set(STATUS_FAILURE)
+			continue
+		}
+
+		// The expanded line is shown before it's run
+		if line != cmd.buffer || printOnly {
+			cmd.defaultPrint("\r\n" + strings.ReplaceAll(strings.TrimSuffix(line, "\n"), "\n", "\r\n"))
+		}
+
+		historyErr := history.Add(line)
+		if printOnly {
+			cmd.defaultPrint("\r\n")
+		} else {
+			sh.run(line)
 		}
+		reportHistoryError(historyErr)
 
-		if slices.Contains(exitCommands, cmd.buffer) {
-			fmt.Print("\n")
+		if sh.exiting {
 			break
 		}
+	}
 
-		fmt.Printf("\n%s", cmd.buffer)
+	sh.leave()
+}
+
+// reportHistoryError print the error the history file was read or
+// written with, if any. The terminal is in raw mode meanwhile.
+func reportHistoryError(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cish: history: %s\r\n", err.Error())
 	}
+}
 
-	exitCish(stdinFd, state, EXIT_SUCCESS)
+// leave run the EXIT trap, then exit with the shell status
+func (sh *Shell) leave() {
+	quitRawMode(sh.sourceFd, sh.termState)
+	sh.runExitTrap(defaultStreams())
+	exitCish(sh.sourceFd, sh.termState, sh.status.Code())
 }
 
 // enterRawMode put the terminal into the raw mode
@@ -500,7 +725,7 @@ func enterRawMode(sourceFd int) (state *term.State) {
 func quitRawMode(sourceFd int, state *term.State) {
 	if t_err := term.Restore(sourceFd, state); t_err != nil {
 		fmt.Fprintln(os.Stderr, t_err.Error())
-		os.Exit(EXIT_ERROR)
+		os.Exit(STATUS_FAILURE)
 	}
 }
 
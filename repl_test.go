@@ -4,9 +4,13 @@ import (
 	"bufio"
 	"bytes"
 	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func newTestCommand(source io.Reader, output io.Writer) *Command {
@@ -24,6 +28,15 @@ func newTestCommand(source io.Reader, output io.Writer) *Command {
 	}
 }
 
+// newTestHistory return a history holding the entries
+func newTestHistory(entries ...string) *History {
+	history := &History{}
+	for _, entry := range entries {
+		history.Add(entry + "\n")
+	}
+	return history
+}
+
 func TestBufferLen(t *testing.T) {
 	t.Run("it should return 0", func(t *testing.T) {
 		cmd := newTestCommand(&bytes.Buffer{}, &bytes.Buffer{})
@@ -101,7 +114,7 @@ func TestHasSuffix(t *testing.T) {
 		text := "Alola"
 
 		for i := 0; i < len(text); i++ {
-			cmd.appendToBuffer(text[i])
+			cmd.appendToBuffer(rune(text[i]))
 		}
 
 		assert.True(t, cmd.hasSuffix("a"))
@@ -215,3 +228,477 @@ func TestMoveCursor(t *testing.T) {
 }
 
 
+
+func TestHandleKeyEnterHeredoc(t *testing.T) {
+	t.Run("it should wait for the here-document delimiter", func(t *testing.T) {
+		cmd := newTestCommand(&bytes.Buffer{}, &bytes.Buffer{})
+		cmd.setBuffer("cat <<EOF")
+
+		assert.False(t, cmd.handleKeyEnter())
+		assert.True(t, cmd.heredoc)
+		assert.Equal(t, PS2, cmd.prompt)
+
+		for _, char := range []byte("it's") {
+			cmd.handleQuote(char)
+		}
+		assert.False(t, cmd.quotesOpened)
+		assert.False(t, cmd.handleKeyEnter())
+
+		cmd.setBuffer(cmd.buffer + "EOF")
+		assert.True(t, cmd.handleKeyEnter())
+		assert.False(t, cmd.heredoc)
+		assert.Equal(t, "cat <<EOF\nit's\nEOF\n", cmd.buffer)
+	})
+}
+
+func TestHandleKeyEnterSubstitution(t *testing.T) {
+	t.Run("it should wait for the end of the command substitution", func(t *testing.T) {
+		cmd := newTestCommand(&bytes.Buffer{}, &bytes.Buffer{})
+		cmd.setBuffer("echo $(ls")
+
+		assert.False(t, cmd.handleKeyEnter())
+		assert.Equal(t, PS2, cmd.prompt)
+
+		cmd.setBuffer(cmd.buffer + ")")
+		assert.True(t, cmd.handleKeyEnter())
+		assert.Equal(t, "echo $(ls\n)\n", cmd.buffer)
+	})
+}
+
+func TestHandleKeyEnterCompound(t *testing.T) {
+	t.Run("it should wait for the end of the compound command", func(t *testing.T) {
+		cmd := newTestCommand(&bytes.Buffer{}, &bytes.Buffer{})
+		cmd.setBuffer("if true; then")
+
+		assert.False(t, cmd.handleKeyEnter())
+		assert.Equal(t, PS2, cmd.prompt)
+
+		cmd.setBuffer(cmd.buffer + "echo yes")
+		assert.False(t, cmd.handleKeyEnter())
+
+		cmd.setBuffer(cmd.buffer + "fi")
+		assert.True(t, cmd.handleKeyEnter())
+		assert.Equal(t, "if true; then\necho yes\nfi\n", cmd.buffer)
+	})
+}
+
+func TestHandleCtrlKeys(t *testing.T) {
+	t.Run("it should discard the buffer on Ctrl-C", func(t *testing.T) {
+		output := &bytes.Buffer{}
+		cmd := newTestCommand(bytes.NewBufferString("echo 'abc\x03"), output)
+
+		assert.ErrorIs(t, cmd.read(), errInterrupt)
+		assert.Equal(t, "\r$ echo 'abc^C\r\n", output.String())
+	})
+
+	t.Run("it should report the end of file on Ctrl-D on an empty line", func(t *testing.T) {
+		cmd := newTestCommand(bytes.NewBufferString("\x04"), &bytes.Buffer{})
+
+		assert.ErrorIs(t, cmd.read(), io.EOF)
+	})
+
+	t.Run("it should ignore Ctrl-D on an empty line with ignoreeof", func(t *testing.T) {
+		output := &bytes.Buffer{}
+		cmd := newTestCommand(bytes.NewBufferString("\x04a"), output)
+		cmd.ignoreEOF = true
+
+		assert.ErrorIs(t, cmd.read(), io.EOF)
+		assert.Equal(t, "a", cmd.buffer)
+		assert.Equal(t, "\r$ \r\nUse \"exit\" to leave the shell.\r\n\r$ a", output.String())
+	})
+
+	t.Run("it should delete the char under the cursor on Ctrl-D", func(t *testing.T) {
+		output := &bytes.Buffer{}
+		cmd := newTestCommand(&bytes.Buffer{}, output)
+		cmd.setBuffer("Ocaml")
+		cmd.cursorPos = 1
+		cmd.handleCtrlD()
+
+		expectedOutput := ARROW_CHUNK + "Kaml"
+		for i := 0; i < 3; i++ {
+			expectedOutput += (ARROW_CHUNK + string(rune(KeyArrowLeft)))
+		}
+
+		assert.Equal(t, "Oaml", cmd.buffer)
+		assert.Equal(t, uint64(1), cmd.cursorPos)
+		assert.Equal(t, expectedOutput, output.String())
+
+		cmd.cursorPos = cmd.bufferLen()
+		assert.True(t, cmd.handleCtrlD())
+		assert.Equal(t, "Oaml", cmd.buffer)
+	})
+}
+
+func TestUTF8Editing(t *testing.T) {
+	t.Run("it should read the UTF-8 chars whole", func(t *testing.T) {
+		cmd := newTestCommand(bytes.NewBufferString("echo é日\U0001F642\r"), &bytes.Buffer{})
+
+		assert.NoError(t, cmd.read())
+		assert.Equal(t, "echo é日\U0001F642\n", cmd.buffer)
+	})
+
+	t.Run("it should delete the whole char with its combining chars", func(t *testing.T) {
+		output := &bytes.Buffer{}
+		cmd := newTestCommand(&bytes.Buffer{}, output)
+		cmd.setBuffer("ae\u0301日")
+
+		cmd.handleBackspace()
+		assert.Equal(t, "aé", cmd.buffer)
+		assert.Equal(t, "\b\b\033[K", output.String())
+
+		output.Reset()
+		cmd.handleBackspace()
+		assert.Equal(t, "a", cmd.buffer)
+		assert.Equal(t, uint64(1), cmd.cursorPos)
+		assert.Equal(t, DELETE, output.String())
+	})
+
+	t.Run("it should move the cursor by char and by column", func(t *testing.T) {
+		output := &bytes.Buffer{}
+		left := ARROW_CHUNK + string(rune(KeyArrowLeft))
+		cmd := newTestCommand(bytes.NewBufferString("[D[D[C"), output)
+		cmd.setBuffer("a日e\u0301")
+
+		assert.NoError(t, cmd.moveCursor())
+		assert.Equal(t, uint64(4), cmd.cursorPos)
+		assert.NoError(t, cmd.moveCursor())
+		assert.Equal(t, uint64(1), cmd.cursorPos)
+		assert.Equal(t, left+left+left, output.String())
+
+		output.Reset()
+		assert.NoError(t, cmd.moveCursor())
+		assert.Equal(t, uint64(4), cmd.cursorPos)
+		assert.Equal(t, strings.Repeat(ARROW_CHUNK+string(rune(KeyArrowRight)), 2), output.String())
+	})
+
+	t.Run("it should redraw the end of the line by column", func(t *testing.T) {
+		output := &bytes.Buffer{}
+		cmd := newTestCommand(&bytes.Buffer{}, output)
+		cmd.setBuffer("a日")
+		cmd.cursorPos = 1
+
+		cmd.printKey('ü')
+		cmd.appendToBuffer('ü')
+
+		assert.Equal(t, "aü日", cmd.buffer)
+		assert.Equal(t, uint64(3), cmd.cursorPos)
+		assert.Equal(t, "ü日"+strings.Repeat(ARROW_CHUNK+string(rune(KeyArrowLeft)), 2), output.String())
+	})
+}
+
+func TestStringWidth(t *testing.T) {
+	tests := map[string]int{
+		"abc": 3,
+		"日本": 4,
+		"e\u0301": 1,
+		"\U0001F642": 2,
+		"\u2764\ufe0f": 2,
+		"\U0001F1EB\U0001F1F7": 2,
+		"\U0001F469\u200d\U0001F4BB": 2,
+		"\U0001F44D\U0001F3FD": 2,
+		"\u200b": 0,
+		"ｆｕｌｌ": 8,
+	}
+
+	for text, width := range tests {
+		assert.Equal(t, width, stringWidth(text), text)
+	}
+
+	flags := "\U0001F1EB\U0001F1F7\U0001F1E9\U0001F1EA"
+	assert.Equal(t, 8, nextGrapheme(flags, 0))
+	assert.Equal(t, 8, previousGrapheme(flags, len(flags)))
+}
+
+func TestHistoryNavigation(t *testing.T) {
+	up, down := "\x1b[A", "\x1b[B"
+
+	t.Run("it should recall the previous and next entries", func(t *testing.T) {
+		cmd := newTestCommand(bytes.NewBufferString(up+up+up+down+"\r"), &bytes.Buffer{})
+		cmd.history = newTestHistory("ls", "pwd", "  ")
+
+		assert.NoError(t, cmd.read())
+		assert.Equal(t, "pwd\n", cmd.buffer)
+	})
+
+	t.Run("it should restore the line typed after the last entry", func(t *testing.T) {
+		cmd := newTestCommand(bytes.NewBufferString("echo 'a"+up+down+"'\r"), &bytes.Buffer{})
+		cmd.history = newTestHistory("ls")
+
+		assert.NoError(t, cmd.read())
+		assert.Equal(t, "echo 'a'\n", cmd.buffer)
+		assert.False(t, cmd.quotesOpened)
+	})
+
+	t.Run("it should only recall the entries starting with the line typed", func(t *testing.T) {
+		cmd := newTestCommand(bytes.NewBufferString("ec"+up+up+up+"\r"), &bytes.Buffer{})
+		cmd.history = newTestHistory("echo a", "ls", "echo b", "echo b", "pwd")
+
+		assert.NoError(t, cmd.read())
+		assert.Equal(t, "echo a\n", cmd.buffer)
+	})
+
+	t.Run("it should recall the multi-line entries intact", func(t *testing.T) {
+		output := &bytes.Buffer{}
+		cmd := newTestCommand(bytes.NewBufferString(up+"\r"), output)
+		cmd.history = newTestHistory("if true\nthen echo a\nfi")
+
+		assert.NoError(t, cmd.read())
+		assert.Equal(t, "if true\nthen echo a\nfi\n", cmd.buffer)
+		assert.Contains(t, output.String(), "$ if true\r\n> then echo a\r\n> fi")
+	})
+
+	t.Run("it should not add the blank lines", func(t *testing.T) {
+		history := newTestHistory("ls", " \n", "")
+
+		assert.Equal(t, []string{"ls"}, history.entries)
+	})
+}
+
+func TestHistoryFile(t *testing.T) {
+	newHistory := func(t *testing.T, vars map[string]string) (*History, string) {
+		sh := newShell(-1, nil)
+		path := filepath.Join(t.TempDir(), ".cish_history")
+		sh.vars.Set("HOME", filepath.Dir(path))
+		for name, value := range vars {
+			sh.vars.Set(name, value)
+		}
+		sh.initHistory()
+		sh.options[OPTION_SHAREHISTORY] = vars["share"] == "on"
+
+		return &History{settings: sh.historySettings()}, path
+	}
+
+	t.Run("it should append the entries to the file and load them", func(t *testing.T) {
+		history, path := newHistory(t, nil)
+		assert.NoError(t, history.Add("echo a\n"))
+		assert.NoError(t, history.Add("echo 'b\nc\\d'\n"))
+
+		data, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, "echo a\necho 'b\\nc\\\\d'\n", string(data))
+
+		loaded := &History{settings: history.settings}
+		assert.NoError(t, loaded.Load())
+		assert.Equal(t, []string{"echo a", "echo 'b\nc\\d'"}, loaded.entries)
+	})
+
+	t.Run("it should keep HISTSIZE entries and cut the file to HISTFILESIZE", func(t *testing.T) {
+		history, path := newHistory(t, map[string]string{"HISTSIZE": "2", "HISTFILESIZE": "3"})
+		assert.NoError(t, os.WriteFile(path, []byte("a\nb\nc\nd\n"), 0600))
+
+		assert.NoError(t, history.Load())
+		assert.Equal(t, []string{"c", "d"}, history.entries)
+
+		data, _ := os.ReadFile(path)
+		assert.Equal(t, "b\nc\nd\n", string(data))
+	})
+
+	t.Run("it should keep HISTFILESIZE entries in the file as they are added", func(t *testing.T) {
+		history, path := newHistory(t, map[string]string{"HISTFILESIZE": "2"})
+		for _, line := range []string{"a", "b", "c"} {
+			assert.NoError(t, history.Add(line+"\n"))
+		}
+
+		data, _ := os.ReadFile(path)
+		assert.Equal(t, "b\nc\n", string(data))
+		assert.Equal(t, []string{"a", "b", "c"}, history.entries)
+	})
+
+	t.Run("it should erase the duplicates from the file", func(t *testing.T) {
+		history, path := newHistory(t, map[string]string{"HISTCONTROL": "erasedups"})
+		assert.NoError(t, os.WriteFile(path, []byte("ls\npwd\nls\n"), 0600))
+		assert.NoError(t, history.Load())
+		assert.NoError(t, history.Add("ls\n"))
+
+		data, _ := os.ReadFile(path)
+		assert.Equal(t, "pwd\nls\n", string(data))
+		assert.Equal(t, []string{"pwd", "ls"}, history.entries)
+	})
+
+	t.Run("it should skip the lines HISTCONTROL and HISTIGNORE ignore", func(t *testing.T) {
+		history, _ := newHistory(t, map[string]string{
+			"HISTCONTROL": "ignoreboth:erasedups", "HISTIGNORE": "ls*:&",
+		})
+		for _, line := range []string{"pwd", " secret", "ls -l", "echo", "echo", "pwd"} {
+			assert.NoError(t, history.Add(line+"\n"))
+		}
+
+		assert.Equal(t, []string{"echo", "pwd"}, history.entries)
+	})
+
+	t.Run("it should save the time of the entries with HISTTIMEFORMAT", func(t *testing.T) {
+		history, path := newHistory(t, map[string]string{"HISTTIMEFORMAT": "%F "})
+		assert.NoError(t, history.Add("ls\n"))
+
+		data, _ := os.ReadFile(path)
+		assert.Regexp(t, `^#\d+\nls\n$`, string(data))
+
+		loaded := &History{settings: history.settings}
+		assert.NoError(t, loaded.Load())
+		assert.Equal(t, []string{"ls"}, loaded.entries)
+		assert.Equal(t, history.times[0].Unix(), loaded.times[0].Unix())
+	})
+
+	t.Run("it should share the entries between the sessions", func(t *testing.T) {
+		first, _ := newHistory(t, map[string]string{"share": "on"})
+		second := &History{settings: first.settings}
+		assert.NoError(t, first.Load())
+		assert.NoError(t, second.Load())
+
+		assert.NoError(t, first.Add("echo a\n"))
+		assert.NoError(t, second.Add("echo b\n"))
+		assert.NoError(t, first.Sync())
+
+		assert.Equal(t, []string{"echo a", "echo b"}, first.entries)
+		assert.Equal(t, []string{"echo a", "echo b"}, second.entries)
+	})
+
+	t.Run("it should share the entries once the file is cut", func(t *testing.T) {
+		first, _ := newHistory(t, map[string]string{"share": "on", "HISTFILESIZE": "2"})
+		second := &History{settings: first.settings}
+
+		for _, add := range []struct {
+			history *History
+			line    string
+		}{{first, "a"}, {first, "b"}, {second, "c"}, {first, "d"}} {
+			assert.NoError(t, add.history.Add(add.line+"\n"))
+		}
+		assert.NoError(t, second.Sync())
+
+		assert.Equal(t, []string{"a", "b", "c", "d"}, first.entries)
+		assert.Equal(t, []string{"a", "b", "c", "d"}, second.entries)
+	})
+
+	t.Run("it should not share the entries without the option", func(t *testing.T) {
+		first, _ := newHistory(t, nil)
+		second := &History{settings: first.settings}
+
+		assert.NoError(t, first.Add("echo a\n"))
+		assert.NoError(t, second.Add("echo b\n"))
+		assert.NoError(t, first.Sync())
+
+		assert.Equal(t, []string{"echo a"}, first.entries)
+	})
+}
+
+func TestHistorySearch(t *testing.T) {
+	history := newTestHistory("echo apple", "ls", "echo banana", "echo apricot")
+	ctrlR, ctrlS, ctrlG := "\x12", "\x13", "\x07"
+
+	t.Run("it should run the latest entry containing the query", func(t *testing.T) {
+		output := &bytes.Buffer{}
+		cmd := newTestCommand(bytes.NewBufferString(ctrlR+"ap\r"), output)
+		cmd.history = history
+
+		assert.NoError(t, cmd.read())
+		assert.Equal(t, "echo apricot\n", cmd.buffer)
+		assert.Contains(t, output.String(), "(reverse-i-search)'ap': echo \x1b[7map\x1b[27mricot")
+	})
+
+	t.Run("it should cycle to the older and newer matches", func(t *testing.T) {
+		cmd := newTestCommand(bytes.NewBufferString(ctrlR+"echo"+ctrlR+ctrlR+ctrlS+"\r"), &bytes.Buffer{})
+		cmd.history = history
+
+		assert.NoError(t, cmd.read())
+		assert.Equal(t, "echo banana\n", cmd.buffer)
+	})
+
+	t.Run("it should accept the match for editing with Esc", func(t *testing.T) {
+		cmd := newTestCommand(bytes.NewBufferString(ctrlR+"ls\x1b -a\r"), &bytes.Buffer{})
+		cmd.history = history
+
+		assert.NoError(t, cmd.read())
+		assert.Equal(t, "ls -a\n", cmd.buffer)
+	})
+
+	t.Run("it should restore the line typed with Ctrl-G", func(t *testing.T) {
+		cmd := newTestCommand(bytes.NewBufferString("cd"+ctrlR+"ban"+ctrlG+" /\r"), &bytes.Buffer{})
+		cmd.history = history
+
+		assert.NoError(t, cmd.read())
+		assert.Equal(t, "cd /\n", cmd.buffer)
+	})
+
+	t.Run("it should show a failed search", func(t *testing.T) {
+		output := &bytes.Buffer{}
+		cmd := newTestCommand(bytes.NewBufferString(ctrlR+"lsx\x7f\r"), output)
+		cmd.history = history
+
+		assert.NoError(t, cmd.read())
+		assert.Equal(t, "ls\n", cmd.buffer)
+		assert.Contains(t, output.String(), "(failed reverse-i-search)'lsx': ls")
+	})
+}
+
+func TestHistoryExpansion(t *testing.T) {
+	history := newTestHistory("cat /usr/lib/libc.so.6 | wc -l", "ls -la 'a b' src", "echo one two three")
+
+	tests := []struct {
+		line     string
+		expanded string
+	}{
+		{"!!\n", "echo one two three\n"},
+		{"sudo !!", "sudo echo one two three"},
+		{"!1", "cat /usr/lib/libc.so.6 | wc -l"},
+		{"!-2", "ls -la 'a b' src"},
+		{"!ls", "ls -la 'a b' src"},
+		{"!?libc?", "cat /usr/lib/libc.so.6 | wc -l"},
+		{"!?libc", "cat /usr/lib/libc.so.6 | wc -l"},
+		{"vi !$", "vi three"},
+		{"vi !^", "vi one"},
+		{"vi !*", "vi one two three"},
+		{"!!:0", "echo"},
+		{"!!:1-2", "one two"},
+		{"!!:2*", "two three"},
+		{"!!:1-", "one two"},
+		{"!ls:2", "'a b'"},
+		{"!cat:2", "|"},
+		{"!cat:1:h", "/usr/lib"},
+		{"!cat:1:t", "libc.so.6"},
+		{"!cat:1:r", "/usr/lib/libc.so"},
+		{"!cat:1:e", ".6"},
+		{"!!:s/one/1/", "echo 1 two three"},
+		{"!!:s/one/[&]", "echo [one] two three"},
+		{"!!:gs/o/0/", "ech0 0ne tw0 three"},
+		{"^two^2^", "echo one 2 three"},
+		{"^three^3", "echo one two 3"},
+		{"echo '!!' \\!! \"!!\"", "echo '!!' \\!! \"echo one two three\""},
+		{"echo ! != x=!", "echo ! != x=!"},
+		{"wait $!; echo ok", "wait $!; echo ok"},
+		{"ls [!a]*", "ls [!a]*"},
+		{"case $x in [!0-9]*) ;; esac", "case $x in [!0-9]*) ;; esac"},
+		{"echo ${!name} \"${!name}\"", "echo ${!name} \"${!name}\""},
+		{"echo [!!", "echo [echo one two three"},
+	}
+
+	for _, test := range tests {
+		expanded, printOnly, err := history.Expand(test.line)
+
+		require.NoError(t, err, test.line)
+		assert.Equal(t, test.expanded, expanded, test.line)
+		assert.False(t, printOnly, test.line)
+	}
+
+	t.Run("it should only print the line with :p", func(t *testing.T) {
+		expanded, printOnly, err := history.Expand("!!:p")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "echo one two three", expanded)
+		assert.True(t, printOnly)
+	})
+
+	t.Run("it should report the invalid references", func(t *testing.T) {
+		errors := map[string]string{
+			"!nothing": "!nothing: event not found",
+			"!42":      "!42: event not found",
+			"!!:9":     "!!:9: bad word specifier",
+			"!!:z":     ":z: unrecognized history modifier",
+			"^four^4":  ":s^four^4: substitution failed",
+		}
+
+		for line, message := range errors {
+			_, _, err := history.Expand(line)
+			assert.EqualError(t, err, message, line)
+		}
+	})
+}
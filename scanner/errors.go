@@ -0,0 +1,36 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+)
+
+//SyntaxError is returned when the source doesn't follow the shell grammar.
+//Incomplete is true when the source end before the command does,
+//which mean that more input could make it valid.
+type SyntaxError struct {
+	Position
+	Msg        string
+	Incomplete bool
+}
+
+//NewSyntaxError create a syntax error located at pos
+func NewSyntaxError(pos Position, incomplete bool, format string, a ...any) *SyntaxError {
+	return &SyntaxError{
+		Position:   pos,
+		Msg:        fmt.Sprintf(format, a...),
+		Incomplete: incomplete,
+	}
+}
+
+func (err *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at line %d, column %d: %s", err.Line, err.Column, err.Msg)
+}
+
+//IsIncomplete report whether err is a syntax error
+//caused by a source ending too early.
+func IsIncomplete(err error) bool {
+	var syntaxErr *SyntaxError
+
+	return errors.As(err, &syntaxErr) && syntaxErr.Incomplete
+}
@@ -0,0 +1,124 @@
+package scanner
+
+import (
+	"strings"
+)
+
+//ExpansionEnd return the offset right after the expansion starting
+//at text[start], which is a parameter expansion like `${name:-word}`,
+//a command substitution like `$(cmd)` or a backquoted command.
+//Quotes and nested expansions are skipped.
+//It return -1 if the expansion is not terminated.
+func ExpansionEnd(text string, start int) int {
+	switch {
+	case strings.HasPrefix(text[start:], "${"):
+		return closingEnd(text, start+2, '{', '}')
+
+	case strings.HasPrefix(text[start:], "$("):
+		return closingEnd(text, start+2, '(', ')')
+
+	case strings.HasPrefix(text[start:], "`"):
+		return backquoteEnd(text, start)
+	}
+
+	return -1
+}
+
+//ArithmeticEnd return the offset right after the `))` closing the
+//`((` at text[start], which start an arithmetic expansion after a `$`
+//or an arithmetic command. It return -1 if the parentheses don't
+//close together, like in `$((cmd) | (cmd))` which is a command
+//substitution, or if they are not terminated.
+func ArithmeticEnd(text string, start int) int {
+	end := closingEnd(text, start+2, '(', ')')
+	if end < 0 || end >= len(text) || text[end] != ')' {
+		return -1
+	}
+
+	return end + 1
+}
+
+//closingEnd return the offset right after the close char
+//matching an open char preceding text[i].
+func closingEnd(text string, i int, open, close byte) int {
+	depth := 1
+
+	for ; i < len(text); i++ {
+		switch c := text[i]; c {
+		case '\\':
+			i++
+
+		case '\'', '"', '`', '$':
+			if c == '$' && !IsExpansionStart(text, i) {
+				continue
+			}
+
+			end := ExpansionEnd(text, i)
+			if c == '\'' || c == '"' {
+				end = QuotedEnd(text, i)
+			}
+
+			if end < 0 {
+				return -1
+			}
+			i = end - 1
+
+		case open:
+			depth++
+
+		case close:
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+
+	return -1
+}
+
+//backquoteEnd return the offset right after
+//the backquote closing the one at text[start]
+func backquoteEnd(text string, start int) int {
+	for i := start + 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+
+		case '`':
+			return i + 1
+		}
+	}
+
+	return -1
+}
+
+//QuotedEnd return the offset right after the quoted string
+//starting at text[start], or -1 if the quote is not closed.
+func QuotedEnd(text string, start int) int {
+	quote := text[start]
+
+	for i := start + 1; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == quote:
+			return i + 1
+
+		case c == '\\' && quote == '"':
+			i++
+
+		case quote == '"' && (c == '`' || IsExpansionStart(text, i)):
+			if i = ExpansionEnd(text, i); i < 0 {
+				return -1
+			}
+			i--
+		}
+	}
+
+	return -1
+}
+
+//IsExpansionStart report whether a parameter expansion
+//or a command substitution start at text[i]
+func IsExpansionStart(text string, i int) bool {
+	return i+1 < len(text) && text[i] == '$' && (text[i+1] == '{' || text[i+1] == '(')
+}
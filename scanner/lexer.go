@@ -0,0 +1,505 @@
+package scanner
+
+import (
+	"strings"
+)
+
+//operators is sorted so that the longest operators are tried first
+var operators = []struct {
+	text string
+	kind Kind
+}{
+	{"<<-", DLESSDASH},
+	{"&>>", ANDDGREAT},
+	{"&&", AND_IF},
+	{"||", OR_IF},
+	{";;", DSEMI},
+	{"<<", DLESS},
+	{">>", DGREAT},
+	{"<&", LESSAND},
+	{">&", GREATAND},
+	{"<>", LESSGREAT},
+	{">|", CLOBBER},
+	{"&>", ANDGREAT},
+	{"|", PIPE},
+	{"&", AMP},
+	{";", SEMI},
+	{"<", LESS},
+	{">", GREAT},
+	{"(", LPAREN},
+	{")", RPAREN},
+}
+
+//Lexer split a source into the tokens of the shell grammar
+type Lexer struct {
+	src    string
+	offset int
+	line   int
+	column int
+	//heredocs wait for the next newline to read their body
+	heredocs []*Heredoc
+	//isDelimiter is set after a << operator
+	isDelimiter bool
+	stripTabs   bool
+	//continued is set when the source end with an escaped newline,
+	//so the command continue on the next line
+	continued bool
+	//atEOF is set when no input follow the source, so its end also
+	//end the pending here-documents, which are kept in unterminated
+	atEOF        bool
+	unterminated []*Heredoc
+}
+
+//NewLexer create a lexer reading the tokens of src
+func NewLexer(src string) *Lexer {
+	return &Lexer{
+		src:    src,
+		line:   1,
+		column: 1,
+	}
+}
+
+func (lex *Lexer) position() Position {
+	return Position{Line: lex.line, Column: lex.column}
+}
+
+//peekByte return the byte at offset n from the
+//current one, or 0 past the end of the source.
+func (lex *Lexer) peekByte(n int) byte {
+	if lex.offset+n >= len(lex.src) {
+		return 0
+	}
+
+	return lex.src[lex.offset+n]
+}
+
+//advance consume n bytes of the source
+func (lex *Lexer) advance(n int) {
+	for ; n > 0 && lex.offset < len(lex.src); n-- {
+		if lex.src[lex.offset] == '\n' {
+			lex.line++
+			lex.column = 1
+		} else {
+			lex.column++
+		}
+		lex.offset++
+	}
+}
+
+func (lex *Lexer) errorf(pos Position, incomplete bool, format string, a ...any) *SyntaxError {
+	return NewSyntaxError(pos, incomplete, format, a...)
+}
+
+//skipBlanks skip the blanks, the escaped newlines and the comments
+func (lex *Lexer) skipBlanks() {
+	for lex.offset < len(lex.src) {
+		switch c := lex.peekByte(0); {
+		case c == ' ' || c == '\t':
+			lex.advance(1)
+
+		case c == '\\' && lex.peekByte(1) == '\n':
+			lex.continued = lex.offset+2 == len(lex.src)
+			lex.advance(2)
+
+		case c == '#':
+			for lex.offset < len(lex.src) && lex.peekByte(0) != '\n' {
+				lex.advance(1)
+			}
+
+		default:
+			return
+		}
+	}
+}
+
+//Next return the next token of the source.
+//Words are classified as ASSIGNMENT_WORD or NAME when they
+//have that form; the parser decide whether it matters.
+func (lex *Lexer) Next() (tok Token, err error) {
+	lex.skipBlanks()
+	tok.pos = lex.position()
+
+	if lex.offset >= len(lex.src) {
+		switch {
+		case len(lex.heredocs) != 0 && lex.atEOF:
+			err = lex.readHeredocs()
+		case len(lex.heredocs) != 0:
+			err = lex.errorf(tok.pos, true, "here-document delimited by %q is not terminated", lex.heredocs[0].Delimiter)
+		case lex.continued:
+			err = lex.errorf(tok.pos, true, "unexpected end of file after an escaped newline")
+		}
+		tok.kind = END_OF_FILE
+		return
+	}
+
+	if lex.peekByte(0) == '\n' {
+		lex.advance(1)
+		tok.kind = NEWLINE
+		tok.text = "\n"
+		tok.Len = 1
+		err = lex.readHeredocs()
+		return
+	}
+
+	for _, op := range operators {
+		if strings.HasPrefix(lex.src[lex.offset:], op.text) {
+			lex.advance(len(op.text))
+			tok.kind = op.kind
+			tok.text = op.text
+			tok.Len = len(op.text)
+
+			if op.kind == DLESS || op.kind == DLESSDASH {
+				lex.isDelimiter = true
+				lex.stripTabs = op.kind == DLESSDASH
+			}
+			return
+		}
+	}
+
+	if tok.text, err = lex.word(); err != nil {
+		return
+	}
+	tok.Len = len(tok.text)
+
+	switch name, _, found := strings.Cut(tok.text, "="); {
+	case isNumber(tok.text) && strings.ContainsRune("<>", rune(lex.peekByte(0))):
+		tok.kind = IO_NUMBER
+		return
+
+	case found && IsName(name):
+		tok.kind = ASSIGNMENT_WORD
+
+	case IsName(tok.text):
+		tok.kind = NAME
+
+	default:
+		tok.kind = WORD
+	}
+
+	if lex.isDelimiter {
+		delimiter, quoted := unquote(tok.text)
+		tok.heredoc = &Heredoc{
+			Delimiter: delimiter,
+			Quoted:    quoted,
+			StripTabs: lex.stripTabs,
+		}
+		lex.heredocs = append(lex.heredocs, tok.heredoc)
+		lex.isDelimiter = false
+	}
+
+	return
+}
+
+//ArithmeticCommand read the expression of a `(( expression ))`
+//command, whose first parenthesis is the last token read.
+//It report false, reading nothing, when the parentheses
+//don't start an arithmetic command.
+func (lex *Lexer) ArithmeticCommand() (tok Token, ok bool) {
+	if lex.offset == 0 || lex.src[lex.offset-1] != '(' || lex.peekByte(0) != '(' {
+		return
+	}
+
+	end := ArithmeticEnd(lex.src, lex.offset-1)
+	if end < 0 {
+		return
+	}
+
+	lex.advance(1)
+	tok.kind = WORD
+	tok.pos = lex.position()
+	tok.text = lex.src[lex.offset : end-2]
+	tok.Len = len(tok.text)
+	lex.advance(end - lex.offset)
+
+	return tok, true
+}
+
+//RegexWord read the regular expression following a `=~` operator,
+//which is the last token read. It's a word in which the parentheses
+//and `|` don't end the word, unless a `)` close a parenthesis
+//opened before the word. Blanks are part of it inside parentheses.
+func (lex *Lexer) RegexWord() (tok Token, err error) {
+	lex.skipBlanks()
+	tok.kind = WORD
+	tok.pos = lex.position()
+	start := lex.offset
+	depth := 0
+
+	for lex.offset < len(lex.src) {
+		c := lex.peekByte(0)
+
+		if depth == 0 && (IsBlank(rune(c)) || c == ')') {
+			break
+		}
+
+		switch c {
+		case '\\':
+			lex.advance(2)
+
+		case '\'', '"':
+			if _, err = lex.quoted(c); err != nil {
+				return
+			}
+
+		case '$', '`':
+			if _, err = lex.expansion(); err != nil {
+				return
+			}
+
+		case '(':
+			depth++
+			lex.advance(1)
+
+		case ')':
+			depth--
+			lex.advance(1)
+
+		default:
+			lex.advance(1)
+		}
+	}
+
+	tok.text = lex.src[start:lex.offset]
+	tok.Len = len(tok.text)
+
+	if tok.text == "" {
+		err = lex.errorf(tok.pos, lex.offset >= len(lex.src), "unexpected argument to conditional binary operator")
+	}
+
+	return
+}
+
+//word read a word until an unquoted blank or operator.
+//Quotes and backslashes are kept in the word text.
+func (lex *Lexer) word() (string, error) {
+	var builder strings.Builder
+
+	for lex.offset < len(lex.src) {
+		c := lex.peekByte(0)
+
+		if IsBlank(rune(c)) || strings.IndexByte("|&;<>()", c) >= 0 {
+			break
+		}
+
+		switch c {
+		case '\\':
+			if lex.peekByte(1) == '\n' {
+				lex.continued = lex.offset+2 == len(lex.src)
+				lex.advance(2)
+				continue
+			}
+			builder.WriteString(lex.src[lex.offset : lex.offset+min(2, len(lex.src)-lex.offset)])
+			lex.advance(2)
+
+		case '\'', '"':
+			text, err := lex.quoted(c)
+			if err != nil {
+				return "", err
+			}
+			builder.WriteString(text)
+
+		case '$', '`':
+			text, err := lex.expansion()
+			if err != nil {
+				return "", err
+			}
+			builder.WriteString(text)
+
+		default:
+			builder.WriteByte(c)
+			lex.advance(1)
+		}
+	}
+
+	return builder.String(), nil
+}
+
+//expansion read an expansion starting with a `$` or a backquote.
+//A `$` starting no expansion is read alone.
+func (lex *Lexer) expansion() (string, error) {
+	pos := lex.position()
+	start := lex.offset
+
+	if lex.peekByte(0) == '$' && !IsExpansionStart(lex.src, start) {
+		lex.advance(1)
+		return "$", nil
+	}
+
+	end := ExpansionEnd(lex.src, start)
+	if end < 0 {
+		closing := "`"
+		if lex.peekByte(0) == '$' {
+			closing = map[byte]string{'{': "}", '(': ")"}[lex.peekByte(1)]
+		}
+		return "", lex.errorf(pos, true, "unexpected end of file while looking for matching `%s'", closing)
+	}
+	lex.advance(end - start)
+
+	return lex.src[start:end], nil
+}
+
+//quoted read a quoted string, quotes included
+func (lex *Lexer) quoted(quote byte) (string, error) {
+	pos := lex.position()
+	start := lex.offset
+	lex.advance(1)
+
+	for lex.offset < len(lex.src) {
+		c := lex.peekByte(0)
+
+		switch {
+		case c == quote:
+			lex.advance(1)
+			return lex.src[start:lex.offset], nil
+
+		case c == '\\' && quote == '"':
+			lex.advance(2)
+
+		case (c == '$' || c == '`') && quote == '"':
+			if _, err := lex.expansion(); err != nil {
+				return "", err
+			}
+
+		default:
+			lex.advance(1)
+		}
+	}
+
+	return "", lex.errorf(pos, true, "unexpected end of file while looking for matching `%c'", quote)
+}
+
+//PendingHeredocs return the here-documents whose
+//body is not read yet
+func (lex *Lexer) PendingHeredocs() []*Heredoc {
+	return lex.heredocs
+}
+
+//EndAtEOF make the end of the source end the here-documents
+//not terminated yet, when no input follow it, like at the
+//end of a script
+func (lex *Lexer) EndAtEOF() {
+	lex.atEOF = true
+}
+
+//Unterminated return the here-documents
+//ended by the end of the source
+func (lex *Lexer) Unterminated() []*Heredoc {
+	return lex.unterminated
+}
+
+//readHeredocs read the body of the pending here-documents.
+//It's called right after a newline token, or at the end of
+//the source when it also end the here-documents.
+func (lex *Lexer) readHeredocs() error {
+	for len(lex.heredocs) != 0 {
+		heredoc := lex.heredocs[0]
+		var body strings.Builder
+
+		for {
+			if lex.offset >= len(lex.src) && lex.atEOF {
+				lex.unterminated = append(lex.unterminated, heredoc)
+				break
+			}
+
+			if lex.offset >= len(lex.src) {
+				return lex.errorf(lex.position(), true, "here-document delimited by %q is not terminated", heredoc.Delimiter)
+			}
+
+			end := strings.IndexByte(lex.src[lex.offset:], '\n')
+			if end < 0 {
+				end = len(lex.src) - lex.offset
+			}
+
+			line := lex.src[lex.offset : lex.offset+end]
+			lex.advance(end + 1)
+
+			if heredoc.StripTabs {
+				line = strings.TrimLeft(line, "\t")
+			}
+
+			if line == heredoc.Delimiter {
+				break
+			}
+
+			body.WriteString(line)
+			body.WriteByte('\n')
+		}
+
+		heredoc.Body = body.String()
+		lex.heredocs = lex.heredocs[1:]
+	}
+
+	return nil
+}
+
+func isNumber(text string) bool {
+	if text == "" {
+		return false
+	}
+
+	for i := 0; i < len(text); i++ {
+		if text[i] < '0' || text[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+//IsName report whether text is a valid variable name
+func IsName(text string) bool {
+	if text == "" {
+		return false
+	}
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+
+		if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+			continue
+		}
+
+		if i != 0 && c >= '0' && c <= '9' {
+			continue
+		}
+
+		return false
+	}
+
+	return true
+}
+
+//unquote remove the quotes and backslashes of text.
+//It also report whether there was something to remove.
+func unquote(text string) (string, bool) {
+	var builder strings.Builder
+	var quote byte
+	quoted := false
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+
+		switch {
+		case c == quote:
+			quote = 0
+			continue
+
+		case quote == 0 && (c == '\'' || c == '"'):
+			quote = c
+			quoted = true
+			continue
+
+		case c == '\\' && quote != '\'' && i+1 < len(text):
+			quoted = true
+			if quote == '"' && strings.IndexByte("$`\"\\\n", text[i+1]) < 0 {
+				break
+			}
+			i++
+			c = text[i]
+		}
+
+		builder.WriteByte(c)
+	}
+
+	return builder.String(), quoted
+}
@@ -1,5 +1,7 @@
 package scanner
 
+import "unicode/utf8"
+
 const (
 	INIT_POSITION = -2
 	EOF = int32(-1)
@@ -13,17 +15,33 @@ type Line struct {
 	pointer int64
 }
 
-//DecreasePointer decrease the line struct pointer
+//DecreasePointer move the line struct pointer back to the previous char
 func (line *Line) DecreasePointer() {
 	if line.pointer < 0 {
 		return
 	}
 
-	line.pointer--
+	if line.pointer == 0 {
+		line.pointer = INIT_POSITION
+		return
+	}
+
+	_, size := utf8.DecodeLastRuneInString(line.buffer[:min(line.pointer, line.bufsize)])
+	line.pointer -= int64(size)
+}
+
+//charAt return the char starting at the byte position
+//and its size. Invalid UTF-8 bytes are returned one by one
+//as utf8.RuneError.
+func (line *Line) charAt(position int64) (rune, int64) {
+	char, size := utf8.DecodeRuneInString(line.buffer[position:])
+
+	return char, int64(size)
 }
 
 //NextChar return the next char of the line
-//by increasing the pointer by one.
+//by moving the pointer over the current one.
+//The chars are the UTF-8 encoded runes of the line.
 //
 //0 is return if the line is empty or the line size is 0. 
 //
@@ -34,8 +52,9 @@ func (line *Line) NextChar() rune {
 		return RUNE_ERROR
 	}
 
-	if line.pointer >= 0 {
-		line.pointer++
+	if line.pointer >= 0 && line.pointer < line.bufsize {
+		_, size := line.charAt(line.pointer)
+		line.pointer += size
 	}
 
 	if line.pointer == INIT_POSITION {
@@ -47,7 +66,9 @@ func (line *Line) NextChar() rune {
 		return EOF
 	}
 
-	return rune(line.buffer[line.pointer])
+	char, _ := line.charAt(line.pointer)
+
+	return char
 }
 
 //FurtherChar is similar to NextChar, except that
@@ -68,21 +89,34 @@ func (line *Line) FurtherChar() rune {
 		return EOF
 	}
 
-	position++
+	_, size := line.charAt(position)
+	position += size
 
-	return rune(line.buffer[position])
+	if position >= line.bufsize {
+		return EOF
+	}
+
+	char, _ := line.charAt(position)
+
+	return char
 }
 
-//SkipWhiteSpace as it name denote it, skip whitespace
-// and tabulation
+//SkipWhiteSpace as it name denote it, skip whitespace,
+// tabulation and newline
 func (line *Line) SkipWhiteSpace() {
 	if line.buffer == "" || line.bufsize == 0 {
 		return
 	}
 
-	for c := line.FurtherChar(); c != EOF && c == ' ' || c == '\t'; {
-		line.NextChar()
+	for IsBlank(line.NextChar()) {
 	}
+
+	line.DecreasePointer()
+}
+
+//IsBlank report whether the char separates two words
+func IsBlank(char rune) bool {
+	return char == ' ' || char == '\t' || char == '\n'
 }
 
 func CreateLine(text string, pointer int64) Line {
@@ -115,3 +115,24 @@ func TestFurtherChar(t *testing.T) {
 	assert.Equal(t, RUNE_ERROR, got)
   })
 }
+
+func TestLineRunes(t *testing.T) {
+	t.Run("It should return the UTF-8 chars whole", func(t *testing.T) {
+		line := CreateLine("é日x", INIT_POSITION)
+
+		assert.Equal(t, '日', line.FurtherChar())
+		assert.Equal(t, 'é', line.NextChar())
+		assert.Equal(t, '日', line.FurtherChar())
+		assert.Equal(t, '日', line.NextChar())
+		assert.Equal(t, 'x', line.NextChar())
+		assert.Equal(t, EOF, line.NextChar())
+	})
+
+	t.Run("It should move back over a whole char", func(t *testing.T) {
+		line := CreateLine("a日x", 4)
+
+		line.DecreasePointer()
+		assert.Equal(t, int64(1), line.pointer)
+		assert.Equal(t, 'x', line.NextChar())
+	})
+}
@@ -1,14 +1,117 @@
 package scanner
 
-import (
-	"slices"
-	"strings"
+//Kind is the kind of a token, as named by the
+//POSIX Shell Command Language grammar
+type Kind int
+
+const (
+	//END_OF_FILE is the last token of the source.
+	//EOF is already the code returned by Line.NextChar.
+	END_OF_FILE Kind = iota
+	WORD
+	ASSIGNMENT_WORD
+	NAME
+	NEWLINE
+	IO_NUMBER
+	AND_IF    // &&
+	OR_IF     // ||
+	DSEMI     // ;;
+	DLESS     // <<
+	DGREAT    // >>
+	LESSAND   // <&
+	GREATAND  // >&
+	LESSGREAT // <>
+	DLESSDASH // <<-
+	CLOBBER   // >|
+	ANDGREAT  // &>
+	ANDDGREAT // &>>
+	PIPE      // |
+	AMP       // &
+	SEMI      // ;
+	LESS      // <
+	GREAT     // >
+	LPAREN    // (
+	RPAREN    // )
 )
 
+var kindNames = map[Kind]string{
+	END_OF_FILE:     "EOF",
+	WORD:            "WORD",
+	ASSIGNMENT_WORD: "ASSIGNMENT_WORD",
+	NAME:            "NAME",
+	NEWLINE:         "NEWLINE",
+	IO_NUMBER:       "IO_NUMBER",
+	AND_IF:          "AND_IF",
+	OR_IF:           "OR_IF",
+	DSEMI:           "DSEMI",
+	DLESS:           "DLESS",
+	DGREAT:          "DGREAT",
+	LESSAND:         "LESSAND",
+	GREATAND:        "GREATAND",
+	LESSGREAT:       "LESSGREAT",
+	DLESSDASH:       "DLESSDASH",
+	CLOBBER:         "CLOBBER",
+	ANDGREAT:        "ANDGREAT",
+	ANDDGREAT:       "ANDDGREAT",
+	PIPE:            "PIPE",
+	AMP:             "AMP",
+	SEMI:            "SEMI",
+	LESS:            "LESS",
+	GREAT:           "GREAT",
+	LPAREN:          "LPAREN",
+	RPAREN:          "RPAREN",
+}
+
+func (kind Kind) String() string {
+	return kindNames[kind]
+}
+
+//IsWord report whether the kind is one of the word kinds
+func (kind Kind) IsWord() bool {
+	return kind == WORD || kind == ASSIGNMENT_WORD || kind == NAME
+}
+
+//IsRedirect report whether the kind is a redirection operator
+func (kind Kind) IsRedirect() bool {
+	switch kind {
+	case LESS, GREAT, DLESS, DGREAT, LESSAND, GREATAND, LESSGREAT, DLESSDASH, CLOBBER,
+		ANDGREAT, ANDDGREAT:
+		return true
+	}
+
+	return false
+}
+
+//Position locate a token in the source.
+//Lines and columns start at 1.
+type Position struct {
+	Line   int
+	Column int
+}
+
+//Pos return the position itself, so every struct
+//embedding a Position has a Pos method.
+func (pos Position) Pos() Position {
+	return pos
+}
+
+//Heredoc is the content of a here-document
+type Heredoc struct {
+	Delimiter string
+	Body      string
+	//Quoted is true when any part of the delimiter is quoted,
+	//which disable the expansions in the body.
+	Quoted    bool
+	StripTabs bool
+}
+
 type Token struct {
-	text string
-	Len int
+	text        string
+	Len         int
 	isEndOfLine bool
+	kind        Kind
+	pos         Position
+	heredoc     *Heredoc
 }
 
 //Append appends a new char to the token
@@ -17,70 +120,46 @@ func (token *Token) Append(char rune) {
 	token.Len++
 }
 
-//Tokenize create tokens from a line struct
-func Tokenize(line *Line) []Token {
-	var tokens []Token
-
-	for {
-		c := line.NextChar()
-		if c == EOF || c == RUNE_ERROR {
-			break;
-		}
-		token := CreateToken(line)
-		tokens = append(tokens, token)
-	}
+//Text return the text of the token
+func (token Token) Text() string {
+	return token.text
+}
 
-	tokens = append(tokens, Token{
-		"",
-		0,
-		true,
-	})
+//Kind return the kind of the token
+func (token Token) Kind() Kind {
+	return token.kind
+}
 
-	return tokens
+//Pos return the position of the first char of the token
+func (token Token) Pos() Position {
+	return token.pos
 }
 
+//Heredoc return the here-document delimited by the token.
+//It's nil unless the token follow a << or <<- operator,
+//and its body is read with the next newline token.
+func (token Token) Heredoc() *Heredoc {
+	return token.heredoc
+}
 
-func CreateToken(line *Line) (token Token) {
+//Tokenize create tokens from a line struct.
+//The tokenization stop at the first syntax error.
+//The last token is always an end of file token.
+func Tokenize(line *Line) []Token {
+	var tokens []Token
+	lexer := NewLexer(line.buffer)
 
 	for {
-		c := line.NextChar()
-
-		if c == EOF {
+		token, err := lexer.Next()
+		if err != nil || token.kind == END_OF_FILE {
 			break
 		}
-
-		if slices.Contains([]rune{'\'', '"'}, c) {
-			if token.Len == 0 {
-				token.Append(c)
-				continue
-			}
-
-			if (c == '"' && strings.HasPrefix(token.text, "\"")) ||
-			   (c == '\'' && strings.HasPrefix(token.text, "'")) {
-				token.Append(c)
-				if line.FurtherChar() == ' ' {
-					break
-				}
-			}
-		}
-
-		token.Append(c)
+		tokens = append(tokens, token)
 	}
 
-	return
+	return append(tokens, Token{
+		isEndOfLine: true,
+		kind:        END_OF_FILE,
+		pos:         lexer.position(),
+	})
 }
-
-/* func handleQuote(quote rune, nextChar rune, token *Token) int {
-	
-	if token.Len == 0 {
-		token.Append(quote)
-		return 0
-	}
-
-	if strings.HasPrefix(token.text, string(quote)) && nextChar == ' ' {
-		token.Append(quote)
-		return -1
-	}
-
-	return 0
-} */
@@ -0,0 +1,149 @@
+package scanner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func tokensText(tokens []Token) (texts []string) {
+	for _, token := range tokens {
+		texts = append(texts, token.Text())
+	}
+
+	return
+}
+
+func tokensKind(tokens []Token) (kinds []Kind) {
+	for _, token := range tokens {
+		kinds = append(kinds, token.Kind())
+	}
+
+	return
+}
+
+func TestTokenize(t *testing.T) {
+	t.Run("It should split words on blanks", func(t *testing.T) {
+		line := CreateLine("ls  -la\t/tmp\n", INIT_POSITION)
+		tokens := Tokenize(&line)
+
+		assert.Equal(t, []string{"ls", "-la", "/tmp", "\n", ""}, tokensText(tokens))
+		assert.True(t, tokens[len(tokens)-1].isEndOfLine)
+	})
+
+	t.Run("It should keep quoted blanks", func(t *testing.T) {
+		line := CreateLine("echo 'Hello, world' \"a b\"\n", INIT_POSITION)
+		tokens := Tokenize(&line)
+
+		assert.Equal(t, []string{"echo", "'Hello, world'", "\"a b\"", "\n", ""}, tokensText(tokens))
+	})
+
+	t.Run("It should keep escaped blanks", func(t *testing.T) {
+		line := CreateLine("cat my\\ file", INIT_POSITION)
+		tokens := Tokenize(&line)
+
+		assert.Equal(t, []string{"cat", "my\\ file", ""}, tokensText(tokens))
+	})
+
+	t.Run("It should only return the end of file token", func(t *testing.T) {
+		line := CreateLine("  # comment", INIT_POSITION)
+		tokens := Tokenize(&line)
+
+		assert.Len(t, tokens, 1)
+		assert.True(t, tokens[0].isEndOfLine)
+		assert.Equal(t, END_OF_FILE, tokens[0].Kind())
+	})
+}
+
+func TestLexerOperators(t *testing.T) {
+	line := CreateLine("a||b&&c;;d|e&f;g<h>i<<-j<<k>>l<&m>&n<>o>|p(q)&>r&>>s", INIT_POSITION)
+	tokens := Tokenize(&line)
+
+	assert.Equal(t, []Kind{
+		NAME, OR_IF, NAME, AND_IF, NAME, DSEMI, NAME, PIPE, NAME, AMP, NAME, SEMI,
+		NAME, LESS, NAME, GREAT, NAME, DLESSDASH, NAME, DLESS, NAME, DGREAT, NAME,
+		LESSAND, NAME, GREATAND, NAME, LESSGREAT, NAME, CLOBBER, NAME, LPAREN, NAME,
+		RPAREN, ANDGREAT, NAME, ANDDGREAT, NAME, END_OF_FILE,
+	}, tokensKind(tokens))
+}
+
+func TestLexerWords(t *testing.T) {
+	line := CreateLine("FOO=1 ls 2>err 3< in 'a|b' x2", INIT_POSITION)
+	tokens := Tokenize(&line)
+
+	assert.Equal(t, []Kind{
+		ASSIGNMENT_WORD, NAME, IO_NUMBER, GREAT, NAME, IO_NUMBER, LESS, NAME, WORD, NAME, END_OF_FILE,
+	}, tokensKind(tokens))
+	assert.Equal(t, "'a|b'", tokens[8].Text())
+}
+
+func TestLexerPositions(t *testing.T) {
+	line := CreateLine("echo a \\\n  b\n  ls", INIT_POSITION)
+	tokens := Tokenize(&line)
+
+	assert.Equal(t, []Position{
+		{1, 1}, {1, 6}, {2, 3}, {2, 4}, {3, 3}, {3, 5},
+	}, []Position{
+		tokens[0].Pos(), tokens[1].Pos(), tokens[2].Pos(), tokens[3].Pos(), tokens[4].Pos(), tokens[5].Pos(),
+	})
+}
+
+func TestLexerHeredoc(t *testing.T) {
+	lexer := NewLexer("cat <<\"EOF\"\n$HOME\nEOF\n")
+	var delimiter Token
+
+	for {
+		token, err := lexer.Next()
+		assert.NoError(t, err)
+
+		if token.Heredoc() != nil {
+			delimiter = token
+		}
+		if token.Kind() == END_OF_FILE {
+			break
+		}
+	}
+
+	assert.Equal(t, "EOF", delimiter.Heredoc().Delimiter)
+	assert.Equal(t, "$HOME\n", delimiter.Heredoc().Body)
+	assert.True(t, delimiter.Heredoc().Quoted)
+}
+
+func TestLexerExpansions(t *testing.T) {
+	t.Run("It should keep the parameter expansions in the word", func(t *testing.T) {
+		line := CreateLine("echo ${x:-a b} \"${y:-\"}\"}\" ${z#${w}}", INIT_POSITION)
+		tokens := Tokenize(&line)
+
+		assert.Equal(t, []string{"echo", "${x:-a b}", "\"${y:-\"}\"}\"", "${z#${w}}", ""}, tokensText(tokens))
+	})
+
+	t.Run("It should report the unterminated expansions", func(t *testing.T) {
+		_, err := NewLexer("echo ${x").Next()
+		assert.NoError(t, err)
+
+		lexer := NewLexer("${x:-a")
+		_, err = lexer.Next()
+		assert.True(t, IsIncomplete(err))
+	})
+
+	t.Run("It should keep the command substitutions in the word", func(t *testing.T) {
+		line := CreateLine("echo $(echo \")\" a) `echo \\`b\\``x \"$(echo \"c d\")\"", INIT_POSITION)
+		tokens := Tokenize(&line)
+
+		assert.Equal(t, []string{"echo", "$(echo \")\" a)", "`echo \\`b\\``x", "\"$(echo \"c d\")\"", ""}, tokensText(tokens))
+	})
+
+	t.Run("It should report the unterminated command substitutions", func(t *testing.T) {
+		_, err := NewLexer("echo $(ls").Next()
+		assert.NoError(t, err)
+
+		lexer := NewLexer("$(ls")
+		_, err = lexer.Next()
+		assert.True(t, IsIncomplete(err))
+		assert.Contains(t, err.Error(), "matching `)'")
+
+		lexer = NewLexer("`ls")
+		_, err = lexer.Next()
+		assert.True(t, IsIncomplete(err))
+	})
+}
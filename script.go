@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/Aboubakary833/cish/parser"
+)
+
+// USAGE is printed when cish is called with invalid arguments
+const USAGE = "usage: cish [-s] [script [args ...]]\n       cish -c command [name [args ...]]"
+
+// cliOptions are the arguments cish is called with
+type cliOptions struct {
+	// command is the command string given with -c,
+	// and script the path of the script to run
+	command string
+	script  string
+	// stdin is set by -s, or when there's no script, so the
+	// commands are read from the standard input
+	stdin bool
+	// name is the name `$0` expand to, and params
+	// are the positional parameters
+	name   string
+	params []string
+}
+
+// parseArgs parse the arguments cish is called with. The first operand
+// is the script to run, or with -c the name of the command string, and
+// the other operands are the positional parameters.
+func parseArgs(args []string) (cliOptions, error) {
+	opts := cliOptions{name: "cish"}
+	command := false
+	i := 0
+
+	for ; i < len(args); i++ {
+		arg := args[i]
+
+		if arg == "--" || arg == "-" {
+			i++
+			break
+		}
+
+		if len(arg) < 2 || arg[0] != '-' {
+			break
+		}
+
+		for _, flag := range []byte(arg[1:]) {
+			switch flag {
+			case 'c':
+				command = true
+			case 's':
+				opts.stdin = true
+			default:
+				return opts, fmt.Errorf("-%c: invalid option", flag)
+			}
+		}
+	}
+
+	operands := args[i:]
+
+	switch {
+	case command:
+		if len(operands) == 0 {
+			return opts, fmt.Errorf("-c: option requires an argument")
+		}
+		opts.command, operands = operands[0], operands[1:]
+
+		if len(operands) != 0 {
+			opts.name, operands = operands[0], operands[1:]
+		}
+
+	case opts.stdin || len(operands) == 0:
+		opts.stdin = true
+
+	default:
+		opts.script, operands = operands[0], operands[1:]
+		opts.name = opts.script
+	}
+
+	opts.params = operands
+
+	return opts, nil
+}
+
+// byteReader read the standard input one byte at a time, so the input
+// following the command being run is left to the programs reading it too
+type byteReader struct {
+	file *os.File
+}
+
+func (r byteReader) ReadByte() (byte, error) {
+	var buffer [1]byte
+
+	n, err := r.file.Read(buffer[:])
+	if n == 0 {
+		if err == nil {
+			err = io.EOF
+		}
+		return 0, err
+	}
+
+	return buffer[0], nil
+}
+
+// runCommandString run the command string given with -c
+// and return the status the shell exit with
+func (sh *Shell) runCommandString(command string) int {
+	return sh.runSource(bufio.NewReader(strings.NewReader(command)))
+}
+
+// runScriptFile run the commands of the script at path
+// and return the status the shell exit with
+func (sh *Shell) runScriptFile(path string) int {
+	file, err := os.Open(path)
+	if err == nil {
+		defer file.Close()
+
+		if info, statErr := file.Stat(); statErr == nil && info.IsDir() {
+			err = &os.PathError{Op: "open", Path: path, Err: syscall.EISDIR}
+		}
+	}
+
+	if err != nil {
+		var pathErr *os.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err
+		}
+
+		fmt.Fprintf(os.Stderr, "cish: %s: %s\n", path, err.Error())
+		return statusFromError(err)
+	}
+
+	return sh.runSource(bufio.NewReader(file))
+}
+
+// runSource read the commands of a script one complete command at a
+// time and run them, until the end of the script, a syntax error or
+// `exit`. It return the status the shell exit with.
+func (sh *Shell) runSource(source io.ByteReader) int {
+	var buffer strings.Builder
+
+	for !sh.exiting {
+		line, err := readSourceLine(source)
+		buffer.WriteString(line)
+
+		eof := errors.Is(err, io.EOF)
+		if err != nil && !eof {
+			fmt.Fprintf(os.Stderr, "cish: %s\n", err.Error())
+			sh.status.Set(STATUS_FAILURE)
+			break
+		}
+
+		list, unterminated, err := parseSource(buffer.String(), eof)
+		if err != nil {
+			if parser.IsIncomplete(err) && !eof {
+				continue
+			}
+
+			fmt.Fprintf(os.Stderr, "cish: %s\n", err.Error())
+			sh.status.Set(STATUS_MISUSE)
+			break
+		}
+
+		for _, delimiter := range unterminated {
+			fmt.Fprintf(os.Stderr, "cish: warning: here-document delimited by end-of-file (wanted `%s')\n", delimiter)
+		}
+
+		buffer.Reset()
+		sh.runList(list, defaultStreams())
+
+		if eof {
+			break
+		}
+	}
+
+	sh.runExitTrap(defaultStreams())
+
+	return sh.status.Code()
+}
+
+// parseSource parse the commands read from the source. At its end,
+// the here-documents not terminated are ended like by their delimiter,
+// which are returned.
+func parseSource(src string, eof bool) (*parser.List, []string, error) {
+	if eof {
+		return parser.ParseEOF(src)
+	}
+
+	list, err := parser.Parse(src)
+	return list, nil, err
+}
+
+// readSourceLine read a line of the source, with its newline
+func readSourceLine(source io.ByteReader) (string, error) {
+	var line []byte
+
+	for {
+		c, err := source.ReadByte()
+		if err != nil {
+			return string(line), err
+		}
+
+		line = append(line, c)
+		if c == '\n' {
+			return string(line), nil
+		}
+	}
+}
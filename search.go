@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Video attributes highlighting the query in the matched entry
+const (
+	HIGHLIGHT_ON  = "\033[7m"
+	HIGHLIGHT_OFF = "\033[27m"
+)
+
+// historySearch is the state of an incremental search in the history
+type historySearch struct {
+	entries []string
+	query   string
+	// backward is set when the older entries are searched,
+	// with Ctrl-R, rather than the newer ones, with Ctrl-S
+	backward bool
+	// index is the entry matched, or the number of entries
+	// until one is, and failed is set when the query is not found
+	index  int
+	failed bool
+	// lines is the number of lines shown below the mini-prompt
+	lines int
+}
+
+// find look for the query from the entry at start in the
+// direction of the search, skipping the entries equal to
+// skip, and report whether an entry is found
+func (s *historySearch) find(start int, skip string) bool {
+	step := 1
+	if s.backward {
+		step = -1
+	}
+
+	for i := start; i >= 0 && i < len(s.entries); i += step {
+		if strings.Contains(s.entries[i], s.query) && s.entries[i] != skip {
+			s.index, s.failed = i, false
+			return true
+		}
+	}
+
+	s.failed = true
+	return false
+}
+
+// match return the entry matched, or an empty string
+func (s *historySearch) match() string {
+	if s.index >= len(s.entries) {
+		return ""
+	}
+
+	return s.entries[s.index]
+}
+
+// prompt return the mini-prompt, followed by the entry
+// matched with the first occurrence of the query highlighted
+func (s *historySearch) prompt() string {
+	name := "i-search"
+	if s.backward {
+		name = "reverse-" + name
+	}
+	if s.failed {
+		name = "failed " + name
+	}
+
+	match := s.match()
+	if at := strings.Index(match, s.query); at >= 0 && s.query != "" {
+		end := at + len(s.query)
+		match = match[:at] + HIGHLIGHT_ON + match[at:end] + HIGHLIGHT_OFF + match[end:]
+	}
+
+	return fmt.Sprintf("(%s)'%s': %s", name, s.query, strings.ReplaceAll(match, "\n", "\r\n"))
+}
+
+// searchHistory search the history incrementally as the query is typed.
+// Ctrl-R and Ctrl-S cycle to the older and newer matches, and Backspace
+// delete the last char of the query. Enter, Esc, an arrow or another
+// control key accept the match for editing, and Ctrl-G and Ctrl-C
+// restore the line typed. It return the key which ended the search,
+// so Enter run the match and Ctrl-C discard the line.
+func (cmd *Command) searchHistory(backward bool) (rune, error) {
+	if cmd.history == nil {
+		return NULChar, nil
+	}
+
+	draft := lineDraft{cmd.buffer, cmd.quotesOpened, cmd.openedQuote, cmd.shouldEscape}
+	cursorPos := cmd.cursorPos
+	entries := cmd.history.entries
+	search := &historySearch{entries: entries, backward: backward, index: len(entries)}
+
+	// restore show the line typed again, with the cursor where it was
+	restore := func() {
+		cmd.printBuffer(draft.buffer)
+		cmd.quotesOpened, cmd.openedQuote, cmd.shouldEscape = draft.quotesOpened, draft.openedQuote, draft.shouldEscape
+
+		if tail := cmd.buffer[cursorPos:]; !strings.Contains(tail, "\n") {
+			cmd.moveLeft(stringWidth(tail))
+			cmd.cursorPos = cursorPos
+		}
+	}
+
+	cmd.clearShown(strings.Count(cmd.buffer[:cmd.cursorPos], "\n"))
+	cmd.showSearch(search)
+
+	for {
+		key, _, err := cmd.reader.ReadRune()
+		if err != nil {
+			return NULChar, err
+		}
+
+		switch {
+		case key == KeyCtrlR || key == KeyCtrlS:
+			direction := key == KeyCtrlR
+			start := search.index + 1
+			if direction {
+				start = search.index - 1
+			}
+			if direction != search.backward {
+				start = search.index
+			}
+
+			search.backward = direction
+			search.find(start, search.match())
+
+		case key == KeyBackspace:
+			if search.query == "" {
+				break
+			}
+
+			_, size := utf8.DecodeLastRuneInString(search.query)
+			search.query = search.query[:len(search.query)-size]
+			search.index = len(entries)
+			search.find(len(entries)-1, "")
+
+		case key == KeyCtrlG || key == KeyCtrlC:
+			cmd.clearShown(search.lines)
+			restore()
+
+			return key, nil
+
+		case key >= ' ' && !unicode.IsControl(key):
+			search.query += string(key)
+			start := search.index
+			if start == len(entries) {
+				start--
+			}
+			search.find(start, "")
+
+		default:
+			if key == KeyArrow && cmd.reader.Buffered() >= 2 {
+				if next, _ := cmd.reader.Peek(1); next[0] == '[' {
+					cmd.reader.Discard(2)
+				}
+			}
+
+			cmd.clearShown(search.lines)
+
+			if match := search.match(); match != "" {
+				cmd.printBuffer(match)
+				cmd.quotesOpened, cmd.openedQuote, cmd.shouldEscape = false, NULChar, false
+				cmd.draft, cmd.histIndex = &draft, search.index
+			} else {
+				restore()
+			}
+
+			return key, nil
+		}
+
+		cmd.clearShown(search.lines)
+		cmd.showSearch(search)
+	}
+}
+
+// showSearch print the mini-prompt of the search
+func (cmd *Command) showSearch(search *historySearch) {
+	cmd.defaultPrint(search.prompt())
+	search.lines = strings.Count(search.match(), "\n")
+}
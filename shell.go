@@ -0,0 +1,171 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"sync/atomic"
+
+	"github.com/Aboubakary833/cish/parser"
+	"golang.org/x/term"
+)
+
+// Shell options set with `set -o`
+const (
+	// OPTION_ERREXIT exit the shell when a command fail
+	OPTION_ERREXIT   = "errexit"
+	OPTION_FAILGLOB  = "failglob"
+	OPTION_IGNOREEOF = "ignoreeof"
+	OPTION_NOCLOBBER = "noclobber"
+	OPTION_NOGLOB    = "noglob"
+	// OPTION_NOUNSET make the expansion of an unset parameter fail
+	OPTION_NOUNSET  = "nounset"
+	OPTION_NULLGLOB = "nullglob"
+	OPTION_PIPEFAIL = "pipefail"
+	// OPTION_SHAREHISTORY add the history entries of the other
+	// sessions before each command line
+	OPTION_SHAREHISTORY = "sharehistory"
+	// OPTION_XTRACE print the simple commands before running them
+	OPTION_XTRACE = "xtrace"
+)
+
+// optionNames list the options in the `set -o` order
+var optionNames = []string{OPTION_ERREXIT, OPTION_FAILGLOB, OPTION_IGNOREEOF, OPTION_NOCLOBBER, OPTION_NOGLOB,
+	OPTION_NOUNSET, OPTION_NULLGLOB, OPTION_PIPEFAIL, OPTION_SHAREHISTORY, OPTION_XTRACE}
+
+// optionLetters are the options that can be set with a
+// single letter, like `set -C`, in the `$-` order.
+var (
+	optionLetters  = []byte{'e', 'f', 'u', 'x', 'C'}
+	optionByLetter = map[byte]string{
+		'e': OPTION_ERREXIT,
+		'f': OPTION_NOGLOB,
+		'u': OPTION_NOUNSET,
+		'x': OPTION_XTRACE,
+		'C': OPTION_NOCLOBBER,
+	}
+)
+
+// Shell hold the state shared by all the command lines
+// typed during a cish session.
+type Shell struct {
+	// hash map the commands names to their path
+	// so PATH is not searched again for each call
+	hash map[string]hashEntry
+	// hashPath is the PATH value the hash table was filled with
+	hashPath string
+	status   Status
+	options  map[string]bool
+	vars     *Variables
+	// name is the shell or script name, as `$0` expand to
+	name string
+	// params are the positional parameters
+	params []string
+	pid    int
+	// lastPid is the process id of the last asynchronous list
+	lastPid int
+	// onStart is called with the process id of each started
+	// program, or with 0 when a program can't be started
+	onStart func(pid int)
+	// jobs are the background and stopped jobs, and
+	// job is the job whose commands are being run
+	jobs *jobTable
+	job  *Job
+	// jobControl is set when the jobs are run in their own process
+	// groups, which get the terminal when they are in the foreground.
+	// pgid is the process group of the shell.
+	jobControl bool
+	pgid       int
+	// io are the streams of the command being run,
+	// used by the command substitutions
+	io streams
+	// substituted is set when a command substitution is run,
+	// and substStatus is the status of the last one
+	substituted bool
+	substStatus int
+	// loops is the number of loops being run, and breaks
+	// and continues the number of loops left by a
+	// `break` or a `continue` being run
+	loops     int
+	breaks    int
+	continues int
+	// dir is the working directory of a subshell, empty in the shell.
+	// The subshells run in the shell process, whose working directory
+	// is the shell one, so theirs is kept here.
+	dir string
+	// dirStack are the directories pushed by `pushd`,
+	// below the working directory
+	dirStack []string
+	// funcs are the defined functions
+	funcs map[string]*parser.FunctionDefinition
+	// funcDepth is the number of functions being run, and
+	// returning is set while a `return` leave the function
+	funcDepth int
+	returning bool
+	// exiting is set by `exit`, so no other command is run
+	exiting bool
+	// conditions is the number of `if`, `while` or `until`
+	// conditions being run, where a failure don't run the ERR trap
+	conditions int
+	// traps are the commands run on the signals and the pseudo-signals,
+	// by name. An empty command ignore the signal.
+	traps map[string]string
+	// signals are the signals received by the shell,
+	// which is nil in the subshells
+	signals *signalState
+	// trapping is set while a trap is run
+	trapping bool
+	// interrupted is set when Ctrl-C interrupt the command line.
+	// It's shared with the subshells, except the background ones.
+	interrupted *atomic.Bool
+	interactive bool
+	// input is the letter `$-` report for where the commands are
+	// read: c for the -c command string and s for the standard input
+	input     byte
+	sourceFd  int
+	termState *term.State
+}
+
+func newShell(sourceFd int, state *term.State) *Shell {
+	sh := &Shell{
+		hash:        make(map[string]hashEntry),
+		options:     make(map[string]bool),
+		vars:        newVariables(),
+		funcs:       make(map[string]*parser.FunctionDefinition),
+		jobs:        newJobTable(),
+		traps:       make(map[string]string),
+		signals:     newSignalState(),
+		interrupted: &atomic.Bool{},
+		name:        "cish",
+		pid:         os.Getpid(),
+		sourceFd:    sourceFd,
+		termState:   state,
+		io:          defaultStreams(),
+	}
+	sh.vars.importEnviron(os.Environ())
+	sh.initPwd()
+
+	go sh.handleSignals()
+
+	return sh
+}
+
+// subshell return a copy of the shell. Changes made by the
+// commands run in the copy don't affect the shell. The traps
+// are reset in the copy, except those ignoring signals.
+func (sh *Shell) subshell() *Shell {
+	sub := *sh
+	sub.hash = maps.Clone(sh.hash)
+	sub.options = maps.Clone(sh.options)
+	sub.vars = sh.vars.clone()
+	sub.funcs = maps.Clone(sh.funcs)
+	sub.traps = maps.Clone(sh.traps)
+	sub.signals = nil
+
+	if sub.dir == "" {
+		sub.dir, _ = os.Getwd()
+	}
+
+	maps.DeleteFunc(sub.traps, func(_, action string) bool { return action != "" })
+
+	return &sub
+}
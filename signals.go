@@ -0,0 +1,411 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/Aboubakary833/cish/parser"
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// pseudoSignals are the conditions that can be trapped besides the
+// signals: the shell exit, a failed command, each simple command
+// and the return of a function.
+var pseudoSignals = []string{"EXIT", "DEBUG", "ERR", "RETURN"}
+
+// MAX_SIGNAL is the highest signal number listed by `trap -l`
+const MAX_SIGNAL = 31
+
+// signalState are the signals received by the shell. The trapped ones
+// are kept pending until the shell run their trap between two commands.
+type signalState struct {
+	channel chan os.Signal
+	mu      sync.Mutex
+	pending []syscall.Signal
+	// trapped are the signals with a trap
+	trapped map[syscall.Signal]bool
+}
+
+func newSignalState() *signalState {
+	return &signalState{
+		channel: make(chan os.Signal, 8),
+		trapped: make(map[syscall.Signal]bool),
+	}
+}
+
+// trappedSignal return the first trapped signal received
+// whose trap was not run yet, if any
+func (sh *Shell) trappedSignal() (syscall.Signal, bool) {
+	s := sh.signals
+	if s == nil {
+		return 0, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.pending) == 0 {
+		return 0, false
+	}
+
+	return s.pending[0], true
+}
+
+// take return the pending signals and clear them
+func (s *signalState) take() []syscall.Signal {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending := s.pending
+	s.pending = nil
+
+	return pending
+}
+
+// initSignals catch the signals the interactive shell handle itself:
+// Ctrl-C interrupt the command line, the stop signals don't stop
+// the shell, and SIGHUP and SIGTERM leave it with the terminal
+// restored. The programs it start still get their default action.
+func (sh *Shell) initSignals() {
+	for _, sig := range sh.caughtSignals() {
+		sh.applySignal(sig)
+	}
+}
+
+// caughtSignals return the signals the shell catch without a trap
+func (sh *Shell) caughtSignals() []syscall.Signal {
+	var caught []syscall.Signal
+
+	if sh.interactive {
+		caught = append(caught, syscall.SIGINT, syscall.SIGHUP, syscall.SIGTERM)
+	}
+
+	if sh.jobControl {
+		caught = append(caught, syscall.SIGTSTP, syscall.SIGTTIN, syscall.SIGTTOU)
+	}
+
+	return caught
+}
+
+// applySignal set the action of the signal from its trap. Ignored
+// signals stay ignored in the programs the shell start, while the
+// caught ones get back their default action there.
+func (sh *Shell) applySignal(sig syscall.Signal) {
+	s := sh.signals
+	action, trapped := sh.traps[unix.SignalName(sig)]
+
+	s.mu.Lock()
+	s.trapped[sig] = trapped && action != ""
+	s.mu.Unlock()
+
+	switch {
+	case trapped && action == "":
+		signal.Ignore(sig)
+	case trapped || slices.Contains(sh.caughtSignals(), sig):
+		signal.Notify(s.channel, sig)
+	default:
+		signal.Reset(sig)
+	}
+}
+
+// handleSignals receive the signals caught by the shell. The trapped
+// ones are left pending, and make `wait` return.
+func (sh *Shell) handleSignals() {
+	s := sh.signals
+
+	for received := range s.channel {
+		sig := received.(syscall.Signal)
+
+		s.mu.Lock()
+		trapped := s.trapped[sig]
+		if trapped {
+			s.pending = append(s.pending, sig)
+		}
+		s.mu.Unlock()
+
+		switch {
+		case trapped:
+			sh.jobs.mu.Lock()
+			sh.jobs.cond.Broadcast()
+			sh.jobs.mu.Unlock()
+
+		case sig == syscall.SIGINT:
+			sh.interrupted.Store(true)
+
+		case sig == syscall.SIGHUP, sig == syscall.SIGTERM:
+			sh.terminate(sig)
+		}
+	}
+}
+
+// terminate leave the shell killed by the signal. The jobs are hung up
+// on SIGHUP, and the terminal is restored so it's not left in raw mode.
+func (sh *Shell) terminate(sig syscall.Signal) {
+	if sig == syscall.SIGHUP {
+		sh.hangupJobs()
+	}
+
+	if sh.termState != nil {
+		term.Restore(sh.sourceFd, sh.termState)
+	}
+
+	os.Exit(STATUS_SIGNAL + int(sig))
+}
+
+// interrupt interrupt the command line when Ctrl-C is typed, unless
+// SIGINT is trapped, its trap being run instead. With the job control,
+// the shell don't get SIGINT itself, but see the foreground program
+// killed by it.
+func (sh *Shell) interrupt() {
+	if s := sh.signals; s != nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.trapped[syscall.SIGINT] {
+			s.pending = append(s.pending, syscall.SIGINT)
+			return
+		}
+	}
+
+	sh.interrupted.Store(true)
+}
+
+// runTraps run the traps of the signals received since the last call.
+// It's called between the commands, where the shell state is consistent.
+func (sh *Shell) runTraps(io streams) {
+	if sh.signals == nil || sh.jumping() {
+		return
+	}
+
+	for _, sig := range sh.signals.take() {
+		if action := sh.traps[unix.SignalName(sig)]; action != "" {
+			sh.runTrap(action, io)
+		}
+	}
+}
+
+// runPseudoTrap run the trap of the DEBUG, ERR or RETURN condition.
+// These traps are not run by the commands of a trap, nor, like in bash
+// without errtrace and functrace, for the DEBUG and ERR conditions in
+// the functions.
+func (sh *Shell) runPseudoTrap(name string, io streams) {
+	if sh.trapping || (sh.funcDepth != 0 && name != "RETURN") {
+		return
+	}
+
+	if action := sh.traps[name]; action != "" {
+		sh.runTrap(action, io)
+	}
+}
+
+// runExitTrap run the EXIT trap when the shell or a subshell exit.
+// The exit status is the one of the shell, unless the trap call `exit`.
+func (sh *Shell) runExitTrap(io streams) {
+	action := sh.traps["EXIT"]
+	if action == "" {
+		return
+	}
+
+	delete(sh.traps, "EXIT")
+	sh.exiting, sh.returning = false, false
+	sh.breaks, sh.continues = 0, 0
+
+	sh.runTrap(action, io)
+}
+
+// runTrap run the commands of a trap in the current shell.
+// `$?` is restored after, unless the trap call `exit`.
+func (sh *Shell) runTrap(action string, io streams) {
+	list, err := parser.Parse(action)
+	if err != nil {
+		printError(io, err)
+		return
+	}
+
+	status, trapping := sh.status.Code(), sh.trapping
+	sh.trapping = true
+	sh.runList(list, io)
+	sh.trapping = trapping
+
+	if !sh.exiting {
+		sh.status.Set(status)
+	}
+}
+
+// trap set the commands run when the shell receive the signals, or
+// meet the EXIT, ERR, DEBUG or RETURN conditions. With `-`, or when
+// only the signals are given, the default action is restored, and
+// with an empty command the signals are ignored. Without arguments
+// or with -p, the traps are printed, and -l list the signal names.
+// In a subshell, only the traps of the conditions can be changed.
+func (sh *Shell) trap(args []string, io streams) int {
+	flags, operands, err := parseFlags(args, "lp")
+	if err != nil {
+		printError(io, fmt.Errorf("trap: %s", err.Error()))
+		return STATUS_MISUSE
+	}
+
+	switch {
+	case flags['l']:
+		listSignals(io)
+		return STATUS_SUCCESS
+
+	case len(operands) == 0 || flags['p']:
+		return sh.printTraps(operands, io)
+	}
+
+	action, specs := operands[0], operands[1:]
+	if _, err := strconv.ParseUint(action, 10, 0); err == nil || len(operands) == 1 {
+		action, specs = "-", operands
+	}
+
+	status := STATUS_SUCCESS
+
+	for _, spec := range specs {
+		name, err := signalSpec(spec)
+		if err != nil {
+			printError(io, fmt.Errorf("trap: %s", err.Error()))
+			status = STATUS_FAILURE
+			continue
+		}
+
+		// the subshells run in the shell process, so they can't
+		// receive the signals, nor change their action for the
+		// programs they start
+		sig := unix.SignalNum(name)
+		if sig != 0 && sh.signals == nil && !sh.keepsTrap(name, action) {
+			printError(io, fmt.Errorf("trap: %s: cannot be changed in a subshell", name))
+			status = STATUS_FAILURE
+			continue
+		}
+
+		if action == "-" {
+			delete(sh.traps, name)
+		} else {
+			sh.traps[name] = action
+		}
+
+		if sig != 0 && sh.signals != nil {
+			sh.applySignal(sig)
+		}
+	}
+
+	return status
+}
+
+// keepsTrap report whether setting the action of the signal keep its
+// trap as it is: a reset one is reset, or an ignored one ignored.
+func (sh *Shell) keepsTrap(name, action string) bool {
+	current, trapped := sh.traps[name]
+
+	return action == "-" && !trapped || action == "" && trapped && current == ""
+}
+
+// printTraps print the traps of the signals given, or all the traps,
+// as the `trap` commands which set them.
+func (sh *Shell) printTraps(specs []string, io streams) int {
+	names := slices.SortedFunc(maps.Keys(sh.traps), func(a, b string) int {
+		return cmp.Compare(trapOrder(a), trapOrder(b))
+	})
+	status := STATUS_SUCCESS
+
+	if len(specs) != 0 {
+		names = nil
+
+		for _, spec := range specs {
+			name, err := signalSpec(spec)
+			if err != nil {
+				printError(io, fmt.Errorf("trap: %s", err.Error()))
+				status = STATUS_FAILURE
+				continue
+			}
+
+			if _, ok := sh.traps[name]; ok {
+				names = append(names, name)
+			}
+		}
+	}
+
+	for _, name := range names {
+		action := "'" + strings.ReplaceAll(sh.traps[name], "'", `'\''`) + "'"
+		fmt.Fprintf(io.stdout, "trap -- %s %s\n", action, name)
+	}
+
+	return status
+}
+
+// trapOrder order the traps like the signal numbers,
+// EXIT first and the other conditions last.
+func trapOrder(name string) int {
+	if sig := unix.SignalNum(name); sig != 0 {
+		return int(sig)
+	}
+
+	if name == "EXIT" {
+		return 0
+	}
+
+	return MAX_SIGNAL + slices.Index(pseudoSignals, name)
+}
+
+// signalSpec return the name of the signal or condition given by its
+// number or name, with or without the SIG prefix, in any case
+func signalSpec(spec string) (string, error) {
+	if n, err := strconv.Atoi(spec); err == nil {
+		if n == 0 {
+			return "EXIT", nil
+		}
+
+		if name := unix.SignalName(syscall.Signal(n)); name != "" {
+			return name, nil
+		}
+
+		return "", fmt.Errorf("%s: invalid signal specification", spec)
+	}
+
+	name := strings.ToUpper(spec)
+	if slices.Contains(pseudoSignals, name) {
+		return name, nil
+	}
+
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+
+	if unix.SignalNum(name) == 0 {
+		return "", fmt.Errorf("%s: invalid signal specification", spec)
+	}
+
+	return name, nil
+}
+
+// listSignals print the signal numbers and names, five per line
+func listSignals(io streams) {
+	var line []string
+
+	for n := 1; n <= MAX_SIGNAL; n++ {
+		name := unix.SignalName(syscall.Signal(n))
+		if name == "" {
+			continue
+		}
+
+		line = append(line, fmt.Sprintf("%2d) %-10s", n, name))
+		if len(line) == 5 {
+			fmt.Fprintln(io.stdout, strings.TrimRight(strings.Join(line, " "), " "))
+			line = nil
+		}
+	}
+
+	if len(line) != 0 {
+		fmt.Fprintln(io.stdout, strings.TrimRight(strings.Join(line, " "), " "))
+	}
+}
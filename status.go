@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// Exit statuses as defined by POSIX.
+// A command killed by a signal has the status
+// STATUS_SIGNAL plus the signal number.
+const (
+	STATUS_SUCCESS        = 0
+	STATUS_FAILURE        = 1
+	STATUS_MISUSE         = 2
+	STATUS_NOT_EXECUTABLE = 126
+	STATUS_NOT_FOUND      = 127
+	STATUS_SIGNAL         = 128
+)
+
+// Status is the register holding the exit status
+// of the last executed command.
+type Status struct {
+	code int
+}
+
+// Code return the last exit status
+func (status *Status) Code() int {
+	return status.code
+}
+
+// Set record the exit status of a command
+func (status *Status) Set(code int) {
+	status.code = code
+}
+
+// SetFromError record the exit status matching the error
+// returned while running a program.
+func (status *Status) SetFromError(err error) {
+	status.code = statusFromError(err)
+}
+
+// String return the status as `$?` expand to.
+func (status *Status) String() string {
+	return strconv.Itoa(status.code)
+}
+
+// statusFromError convert a program error into an exit status.
+func statusFromError(err error) int {
+	var exitErr *exec.ExitError
+
+	switch {
+	case err == nil:
+		return STATUS_SUCCESS
+
+	case errors.As(err, &exitErr):
+		if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			return STATUS_SIGNAL + int(ws.Signal())
+		}
+		return exitErr.ExitCode()
+
+	case errors.Is(err, exec.ErrNotFound):
+		return STATUS_NOT_FOUND
+
+	case errors.Is(err, syscall.ENOENT):
+		return STATUS_NOT_FOUND
+
+	case errors.Is(err, syscall.EACCES), errors.Is(err, syscall.ENOEXEC),
+		errors.Is(err, syscall.EISDIR), errors.Is(err, exec.ErrDot):
+		return STATUS_NOT_EXECUTABLE
+	}
+
+	return STATUS_FAILURE
+}
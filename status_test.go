@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusFromError(t *testing.T) {
+	t.Run("it should return the program exit code", func(t *testing.T) {
+		err := exec.Command("/bin/sh", "-c", "exit 3").Run()
+
+		assert.Equal(t, 3, statusFromError(err))
+	})
+
+	t.Run("it should add the signal number to 128", func(t *testing.T) {
+		err := exec.Command("/bin/sh", "-c", "kill -TERM $$").Run()
+
+		assert.Equal(t, STATUS_SIGNAL+15, statusFromError(err))
+	})
+
+	t.Run("it should return 127 when the command is not found", func(t *testing.T) {
+		_, err := exec.LookPath("cish-command-that-does-not-exist")
+
+		assert.Equal(t, STATUS_NOT_FOUND, statusFromError(err))
+	})
+
+	t.Run("it should return 126 when the file is not executable", func(t *testing.T) {
+		_, err := exec.LookPath("./status.go")
+
+		assert.Equal(t, STATUS_NOT_EXECUTABLE, statusFromError(err))
+	})
+}
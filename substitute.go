@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Aboubakary833/cish/parser"
+)
+
+// substitute run the commands in a subshell
+// and return their output without the trailing newlines.
+func (sh *Shell) substitute(src string) (string, error) {
+	list, err := parser.Parse(src)
+	if err != nil {
+		return "", err
+	}
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	sub := sh.subshell()
+	subIo := sh.io
+	subIo.stdout = writer
+	done := make(chan int, 1)
+
+	go func() {
+		defer writer.Close()
+		sub.runList(list, subIo)
+		sub.runExitTrap(subIo)
+		done <- sub.status.Code()
+	}()
+
+	output, err := io.ReadAll(reader)
+	sh.substStatus = <-done
+	sh.substituted = true
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(strings.ReplaceAll(string(output), "\x00", ""), "\n"), nil
+}
+
+// unescapeBackquoted return the commands of a backquoted substitution.
+// There, a backslash only escape the dollar sign, the backquote and the
+// backslash, plus the double quote when the substitution is quoted.
+func unescapeBackquoted(text string, quoted bool) string {
+	var builder strings.Builder
+	escaped := "$`\\"
+
+	if quoted {
+		escaped += "\""
+	}
+
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' && i+1 < len(text) && strings.IndexByte(escaped, text[i+1]) >= 0 {
+			i++
+		}
+		builder.WriteByte(text[i])
+	}
+
+	return builder.String()
+}
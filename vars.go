@@ -0,0 +1,274 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Aboubakary833/cish/scanner"
+)
+
+// Variable is a shell variable. A declared variable
+// without value, like after `export NAME`, is not set.
+type Variable struct {
+	Value string
+	// Array are the elements of an array variable,
+	// whose Value is the first element
+	Array    []string
+	Set      bool
+	Exported bool
+	ReadOnly bool
+}
+
+// scope is a level of the variables table. The global scope
+// has no parent, and each function call add a scope on top of it.
+type scope struct {
+	vars   map[string]*Variable
+	parent *scope
+}
+
+// Variables is the table of the shell variables.
+// A variable is looked up from the current scope to the global one,
+// which give the functions local variables a dynamic scoping.
+type Variables struct {
+	current *scope
+}
+
+func newVariables() *Variables {
+	return &Variables{
+		current: &scope{vars: make(map[string]*Variable)},
+	}
+}
+
+// importEnviron declare the environment variables as exported variables
+func (vars *Variables) importEnviron(environ []string) {
+	for _, entry := range environ {
+		name, value, found := strings.Cut(entry, "=")
+
+		if found && scanner.IsName(name) {
+			vars.global().vars[name] = &Variable{Value: value, Set: true, Exported: true}
+		}
+	}
+}
+
+func (vars *Variables) global() *scope {
+	s := vars.current
+	for s.parent != nil {
+		s = s.parent
+	}
+
+	return s
+}
+
+// lookup return the variable and the scope declaring it
+func (vars *Variables) lookup(name string) (*Variable, *scope) {
+	for s := vars.current; s != nil; s = s.parent {
+		if variable, ok := s.vars[name]; ok {
+			return variable, s
+		}
+	}
+
+	return nil, nil
+}
+
+// Get return the value of a variable and whether it's set
+func (vars *Variables) Get(name string) (string, bool) {
+	variable, _ := vars.lookup(name)
+	if variable == nil || !variable.Set {
+		return "", false
+	}
+
+	return variable.Value, true
+}
+
+// Variable return the variable named name, or nil if it's not declared
+func (vars *Variables) Variable(name string) *Variable {
+	variable, _ := vars.lookup(name)
+
+	return variable
+}
+
+// Set assign a value to a variable. Variables which are not
+// declared yet are created in the global scope.
+func (vars *Variables) Set(name, value string) error {
+	variable, _ := vars.lookup(name)
+
+	if variable == nil {
+		variable = &Variable{}
+		vars.global().vars[name] = variable
+	}
+
+	if variable.ReadOnly {
+		return fmt.Errorf("%s: readonly variable", name)
+	}
+
+	variable.Value = value
+	variable.Set = true
+
+	if len(variable.Array) != 0 {
+		variable.Array[0] = value
+	}
+
+	return nil
+}
+
+// SetArray assign the elements to an array variable
+func (vars *Variables) SetArray(name string, values []string) error {
+	if err := vars.Set(name, ""); err != nil {
+		return err
+	}
+
+	variable := vars.Variable(name)
+	variable.Array = slices.Clone(values)
+	if variable.Array == nil {
+		variable.Array = []string{}
+	}
+
+	if len(values) != 0 {
+		variable.Value = values[0]
+	}
+
+	return nil
+}
+
+// Array return the elements of an array variable, or the value
+// of another variable as a single element, and whether it's set.
+func (vars *Variables) Array(name string) ([]string, bool) {
+	variable, _ := vars.lookup(name)
+
+	switch {
+	case variable == nil || !variable.Set:
+		return nil, false
+	case variable.Array != nil:
+		return variable.Array, true
+	}
+
+	return []string{variable.Value}, true
+}
+
+// Declare return the variable named name,
+// creating it unset in the global scope if needed.
+func (vars *Variables) Declare(name string) *Variable {
+	variable, _ := vars.lookup(name)
+
+	if variable == nil {
+		variable = &Variable{}
+		vars.global().vars[name] = variable
+	}
+
+	return variable
+}
+
+// Local return the variable named name in the current scope,
+// creating it unset if needed.
+func (vars *Variables) Local(name string) *Variable {
+	variable, ok := vars.current.vars[name]
+
+	if !ok {
+		variable = &Variable{}
+		vars.current.vars[name] = variable
+	}
+
+	return variable
+}
+
+// Unset remove the variable from the scope declaring it
+func (vars *Variables) Unset(name string) error {
+	variable, s := vars.lookup(name)
+
+	if variable == nil {
+		return nil
+	}
+
+	if variable.ReadOnly {
+		return fmt.Errorf("%s: cannot unset: readonly variable", name)
+	}
+
+	delete(s.vars, name)
+
+	return nil
+}
+
+// push add a scope on top of the current one
+func (vars *Variables) push() {
+	vars.current = &scope{
+		vars:   make(map[string]*Variable),
+		parent: vars.current,
+	}
+}
+
+// pop remove the current scope
+func (vars *Variables) pop() {
+	if vars.current.parent != nil {
+		vars.current = vars.current.parent
+	}
+}
+
+// setTemporary declare the variable in the current scope,
+// hiding the variables of the outer scopes with the same name.
+func (vars *Variables) setTemporary(name, value string) error {
+	if variable := vars.Variable(name); variable != nil && variable.ReadOnly {
+		return fmt.Errorf("%s: readonly variable", name)
+	}
+
+	vars.current.vars[name] = &Variable{Value: value, Set: true, Exported: true}
+
+	return nil
+}
+
+// Names return the sorted names of the visible variables
+func (vars *Variables) Names() []string {
+	seen := make(map[string]bool)
+	var names []string
+
+	for s := vars.current; s != nil; s = s.parent {
+		for name := range s.vars {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// Environ return the exported variables in the "NAME=value"
+// form expected by the programs. Arrays are not exported.
+func (vars *Variables) Environ() []string {
+	var environ []string
+
+	for _, name := range vars.Names() {
+		if variable := vars.Variable(name); variable.Exported && variable.Set && variable.Array == nil {
+			environ = append(environ, name+"="+variable.Value)
+		}
+	}
+
+	return environ
+}
+
+// clone return a deep copy of the variables table
+func (vars *Variables) clone() *Variables {
+	var clone func(s *scope) *scope
+
+	clone = func(s *scope) *scope {
+		if s == nil {
+			return nil
+		}
+
+		copied := &scope{
+			vars:   make(map[string]*Variable, len(s.vars)),
+			parent: clone(s.parent),
+		}
+		for name, variable := range s.vars {
+			v := *variable
+			v.Array = slices.Clone(variable.Array)
+			copied.vars[name] = &v
+		}
+
+		return copied
+	}
+
+	return &Variables{current: clone(vars.current)}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVariables(t *testing.T) {
+	t.Run("it should set and get variables", func(t *testing.T) {
+		vars := newVariables()
+		vars.Set("x", "1")
+
+		value, ok := vars.Get("x")
+		assert.True(t, ok)
+		assert.Equal(t, "1", value)
+
+		_, ok = vars.Get("y")
+		assert.False(t, ok)
+	})
+
+	t.Run("it should not change readonly variables", func(t *testing.T) {
+		vars := newVariables()
+		vars.Set("x", "1")
+		vars.Variable("x").ReadOnly = true
+
+		assert.EqualError(t, vars.Set("x", "2"), "x: readonly variable")
+		assert.EqualError(t, vars.Unset("x"), "x: cannot unset: readonly variable")
+	})
+
+	t.Run("it should hide variables in the inner scopes", func(t *testing.T) {
+		vars := newVariables()
+		vars.Set("x", "global")
+
+		vars.push()
+		vars.setTemporary("x", "local")
+		vars.Set("y", "new")
+		value, _ := vars.Get("x")
+		assert.Equal(t, "local", value)
+		vars.pop()
+
+		value, _ = vars.Get("x")
+		assert.Equal(t, "global", value)
+		value, _ = vars.Get("y")
+		assert.Equal(t, "new", value)
+	})
+
+	t.Run("it should only pass exported variables", func(t *testing.T) {
+		vars := newVariables()
+		vars.importEnviron([]string{"HOME=/root", "PATH=/bin"})
+		vars.Set("x", "1")
+		vars.Declare("y").Exported = true
+
+		assert.Equal(t, []string{"HOME=/root", "PATH=/bin"}, vars.Environ())
+	})
+}